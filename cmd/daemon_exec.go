@@ -1,17 +1,20 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"strings"
+	"os"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
 
 	"github.com/noksa/helm-in-pod/internal"
 	"github.com/noksa/helm-in-pod/internal/cmdoptions"
 	"github.com/noksa/helm-in-pod/internal/hipconsts"
+	"github.com/noksa/helm-in-pod/internal/hipreport"
 	"github.com/noksa/helm-in-pod/internal/logz"
 )
 
@@ -26,94 +29,127 @@ func newDaemonExecCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			if len(args) == 0 {
+			if len(args) == 0 && opts.Script == "" {
 				return fmt.Errorf("specify command to run")
 			}
-			logz.Host().Debug().Msgf("Looking for %s daemon", color.CyanString(opts.Name))
-			pod, err := internal.Pod().GetDaemonPod(opts.Name)
+			if err := validateCopyExclude(&opts.ExecOptions); err != nil {
+				return err
+			}
+			if err := loadEnvFiles(&opts.ExecOptions); err != nil {
+				return err
+			}
+			if err := validateSecretEnv(&opts.ExecOptions); err != nil {
+				return err
+			}
+			redactSensitiveEnv(&opts.ExecOptions)
+			cmdToUse, err := commandToRun(opts.ExecOptions, args, os.Stdin)
 			if err != nil {
 				return err
 			}
-			logz.Host().Info().Msgf("Found %s daemon", color.CyanString(pod.Name))
+			return writeReport(internal.Pod(), opts.ExecOptions, runDaemonExec(cmd.Context(), opts, cmdToUse))
+		},
+	}
+	execCmd.Flags().StringVar(&opts.Name, "name", "", "Daemon name (required)")
+	execCmd.Flags().BoolVar(&opts.UpdateAllRepos, "update-all-repos", false, "Update all helm repositories without copying them")
+	execCmd.Flags().StringSliceVar(&opts.Clean, "clean", []string{}, "Paths to delete in the pod before copying files")
+	_ = execCmd.Flags().MarkDeprecated("clean", "--copy now deletes files removed on the host from the pod")
+	addRuntimeFlags(execCmd, &opts.ExecOptions, false)
+	addStdinFlag(execCmd, &opts.ExecOptions)
+	addScriptFlags(execCmd, &opts.ExecOptions)
+	addReportFlag(execCmd, &opts.ExecOptions)
+	return execCmd
+}
 
-			homeDirectory := pod.Annotations[hipconsts.AnnotationHomeDirectory]
-			if homeDirectory == "" {
-				return fmt.Errorf("daemon pod missing home-directory annotation")
-			}
+// runDaemonExec syncs repositories and files into the daemon pod, runs command
+// in it and copies the --copy-from mappings back to the host.
+func runDaemonExec(ctx context.Context, opts cmdoptions.DaemonOptions, command string) error {
+	logz.Host().Debug().Msgf("Looking for %s daemon", color.CyanString(opts.Name))
+	pod, err := internal.Pod().GetDaemonPod(opts.Name)
+	if err != nil {
+		return err
+	}
+	logz.Host().Info().Msgf("Found %s daemon", color.CyanString(pod.Name))
+	internal.Pod().Report().SetPod(pod)
 
-			helmFound := pod.Annotations[hipconsts.AnnotationHelmFound] == "true"
-			isHelm4 := pod.Annotations[hipconsts.AnnotationHelm4] == "true"
+	homeDirectory := pod.Annotations[hipconsts.AnnotationHomeDirectory]
+	if homeDirectory == "" {
+		return fmt.Errorf("daemon pod missing home-directory annotation")
+	}
 
-			if helmFound && (opts.CopyRepo || len(opts.UpdateRepo) > 0 || opts.UpdateAllRepos) {
-				if opts.CopyAttempts < 1 {
-					return fmt.Errorf("copy-attempts value can't be less 1")
-				}
-				if opts.UpdateRepoAttempts < 1 {
-					return fmt.Errorf("update-repo-attempts value can't be less 1")
-				}
+	internal.Pod().Report().SetBootInfo(hipreport.BootInfo{Home: homeDirectory})
 
-				switch {
-				case opts.CopyRepo:
-					err = internal.Pod().SyncHelmRepositories(pod, opts.ExecOptions, homeDirectory, isHelm4)
-					if err != nil {
-						return err
-					}
-				case opts.UpdateAllRepos:
-					// Update all repos without copying
-					opts.UpdateRepo = []string{}
-					err = internal.Pod().UpdateHelmRepositories(pod, opts.ExecOptions, isHelm4)
-					if err != nil {
-						return err
-					}
-				case len(opts.UpdateRepo) > 0:
-					err = internal.Pod().UpdateHelmRepositories(pod, opts.ExecOptions, isHelm4)
-					if err != nil {
-						return err
-					}
-				}
-			}
+	helmFound := pod.Annotations[hipconsts.AnnotationHelmFound] == "true"
+	isHelm4 := pod.Annotations[hipconsts.AnnotationHelm4] == "true"
 
-			if len(opts.Files) > 0 {
-				opts.ParseFileMappings()
-				err = internal.Pod().CopyUserFiles(pod, opts.ExecOptions, expand, opts.Clean)
-				if err != nil {
-					return err
-				}
-			}
+	if helmFound && (opts.CopyRepo || len(opts.UpdateRepo) > 0 || opts.UpdateAllRepos) {
+		if opts.CopyAttempts < 1 {
+			return fmt.Errorf("copy-attempts value can't be less 1")
+		}
+		if opts.CopyTimeout <= 0 {
+			return fmt.Errorf("copy-timeout value must be positive")
+		}
+		if opts.UpdateRepoAttempts < 1 {
+			return fmt.Errorf("update-repo-attempts value can't be less 1")
+		}
 
-			cmdToUse := strings.Join(args, " ")
-			timeout := viper.GetDuration("timeout")
-			if timeout == 0 {
-				timeout = time.Hour * 2
+		switch {
+		case opts.CopyRepo:
+			err = internal.Pod().SyncHelmRepositories(pod, opts.ExecOptions, homeDirectory, isHelm4)
+			if err != nil {
+				return err
 			}
-			execErr := internal.Pod().ExecuteCommandInDaemon(cmd.Context(), pod, cmdToUse, homeDirectory, timeout, opts.ExecOptions)
-
-			// Copy files from pod to host after command execution
-			if len(opts.CopyFrom) > 0 {
-				copyFromMap, parseErr := parseCopyFromMappings(opts.CopyFrom)
-				if parseErr != nil {
-					if execErr != nil {
-						return execErr
-					}
-					return parseErr
-				}
-				for podPath, hostPath := range copyFromMap {
-					expanded, expandErr := expand(hostPath)
-					if expandErr != nil {
-						return expandErr
-					}
-					if copyErr := internal.Pod().CopyFileFromPod(pod, podPath, expanded, opts.CopyAttempts); copyErr != nil {
-						return copyErr
-					}
-				}
+		case opts.UpdateAllRepos:
+			// Update all repos without copying
+			opts.UpdateRepo = []string{}
+			err = internal.Pod().UpdateHelmRepositories(pod, opts.ExecOptions, isHelm4)
+			if err != nil {
+				return err
+			}
+		case len(opts.UpdateRepo) > 0:
+			err = internal.Pod().UpdateHelmRepositories(pod, opts.ExecOptions, isHelm4)
+			if err != nil {
+				return err
 			}
+		}
+	}
+
+	if len(opts.Files) > 0 {
+		opts.ParseFileMappings()
+		err = internal.Pod().SyncUserFiles(pod, opts.ExecOptions, expand, homeDirectory, opts.Clean)
+		if err != nil {
+			return err
+		}
+	}
+
+	timeout := viper.GetDuration("timeout")
+	if timeout == 0 {
+		timeout = time.Hour * 2
+	}
+	execErr := internal.Pod().ExecuteCommandInDaemon(ctx, pod, command, homeDirectory, timeout, opts.ExecOptions)
+	return copyFromDaemon(pod, opts, execErr)
+}
 
+// copyFromDaemon copies the --copy-from mappings to the host after the command
+// finished with execErr and returns the result of the run.
+func copyFromDaemon(pod *corev1.Pod, opts cmdoptions.DaemonOptions, execErr error) error {
+	if len(opts.CopyFrom) == 0 {
+		return execErr
+	}
+	copyFromMap, parseErr := parseCopyFromMappings(opts.CopyFrom)
+	if parseErr != nil {
+		if execErr != nil {
 			return execErr
-		},
+		}
+		return parseErr
 	}
-	execCmd.Flags().StringVar(&opts.Name, "name", "", "Daemon name (required)")
-	execCmd.Flags().BoolVar(&opts.UpdateAllRepos, "update-all-repos", false, "Update all helm repositories without copying them")
-	execCmd.Flags().StringSliceVar(&opts.Clean, "clean", []string{}, "Paths to delete in the pod before copying files")
-	addRuntimeFlags(execCmd, &opts.ExecOptions, false)
-	return execCmd
+	for podPath, hostPath := range copyFromMap {
+		expanded, expandErr := expand(hostPath)
+		if expandErr != nil {
+			return expandErr
+		}
+		if copyErr := internal.Pod().CopyFileFromPod(pod, podPath, expanded, opts.CopyAttempts, opts.CopyTimeout); copyErr != nil {
+			return copyErr
+		}
+	}
+	return execErr
 }
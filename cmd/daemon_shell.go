@@ -5,12 +5,13 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/noksa/helm-in-pod/internal"
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
 	"github.com/noksa/helm-in-pod/internal/logz"
 )
 
 func newDaemonShellCmd() *cobra.Command {
 	var name string
-	var shell string
+	shellOpts := cmdoptions.ShellOptions{}
 	shellCmd := &cobra.Command{
 		Use:   "shell",
 		Short: "Open an interactive shell in a daemon pod",
@@ -31,10 +32,10 @@ All Helm repositories and configurations are already set up and ready to use.`,
 			logz.Host().Info().Msgf("Opening interactive shell in %s daemon", color.CyanString(pod.Name))
 			logz.Host().Info().Msg("Type 'exit' or press Ctrl+D to close the shell")
 
-			return internal.Pod().OpenInteractiveShell(cmd.Context(), pod, shell)
+			return internal.Pod().OpenInteractiveShell(cmd.Context(), pod, shellOpts)
 		},
 	}
 	shellCmd.Flags().StringVar(&name, "name", "", "Daemon name (required)")
-	shellCmd.Flags().StringVar(&shell, "shell", "sh", "Shell to use (sh, bash, zsh, etc.)")
+	addShellFlags(shellCmd, &shellOpts)
 	return shellCmd
 }
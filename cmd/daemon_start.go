@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
@@ -55,8 +56,14 @@ Use 'daemon exec' to run commands and 'daemon stop' to tear down the pod.`,
 			}
 
 			opts.ParseFileMappings()
+			opts.Daemon = true
 
-			err = internal.Namespace().PrepareNs()
+			err = runPreflight(internal.Namespace(), opts.ExecOptions, os.Stderr)
+			if err != nil {
+				return err
+			}
+
+			err = internal.Namespace().PrepareNs(opts.ExecOptions)
 			if err != nil {
 				return err
 			}
@@ -92,9 +99,11 @@ Use 'daemon exec' to run commands and 'daemon stop' to tear down the pod.`,
 				}
 			}
 
-			err = internal.Pod().CopyUserFiles(pod, opts.ExecOptions, expand, nil)
-			if err != nil {
-				return err
+			if len(opts.FilesAsMap) > 0 {
+				err = internal.Pod().SyncUserFiles(pod, opts.ExecOptions, expand, userInfo.HomeDirectory, nil)
+				if err != nil {
+					return err
+				}
 			}
 
 			// Annotate pod with user info and helm version
@@ -118,6 +127,7 @@ Use 'daemon exec' to run commands and 'daemon stop' to tear down the pod.`,
 	startCmd.Flags().StringVar(&opts.Name, "name", "", "Daemon name (required)")
 	startCmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Force recreate daemon pod if it already exists")
 	addExecOptionsFlags(startCmd, &opts.ExecOptions)
+	addDeleteTimeoutFlag(startCmd, &opts.DeleteTimeout)
 
 	return startCmd
 }
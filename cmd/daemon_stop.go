@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"time"
+
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
@@ -11,6 +13,7 @@ import (
 
 func newDaemonStopCmd() *cobra.Command {
 	var name string
+	var deleteTimeout time.Duration
 	stopCmd := &cobra.Command{
 		Use:   "stop",
 		Short: "Stop and delete a daemon pod",
@@ -28,9 +31,10 @@ func newDaemonStopCmd() *cobra.Command {
 				}
 				return err
 			}
-			return internal.Pod().DeleteDaemonPod(name)
+			return internal.Pod().DeleteDaemonPod(name, deleteTimeout)
 		},
 	}
 	stopCmd.Flags().StringVar(&name, "name", "", "Daemon name (required)")
+	addDeleteTimeoutFlag(stopCmd, &deleteTimeout)
 	return stopCmd
 }
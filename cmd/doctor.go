@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/noksa/helm-in-pod/internal"
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipaccess"
+	"github.com/noksa/helm-in-pod/internal/logz"
+)
+
+func newDoctorCmd() *cobra.Command {
+	opts := cmdoptions.ExecOptions{}
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that the current user has all permissions the plugin needs",
+		Long: `Run the same SelfSubjectAccessReview checks as the exec and daemon start preflight
+and print every required permission with its status. Nothing is created in the cluster.
+
+Pass the same RBAC flags as for exec/daemon start to check a specific flow.`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateRBACFlags(&opts)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logz.Host().Info().Msgf("Checking permissions for %v plugin namespace in %v RBAC mode",
+				color.CyanString(internal.Pod().Namespace()), color.CyanString(opts.RBACMode))
+			results, err := internal.Namespace().CheckAccess(opts)
+			if err != nil {
+				return err
+			}
+			renderAccessTable(os.Stdout, results)
+			missing := hipaccess.Missing(results)
+			if len(missing) > 0 {
+				return fmt.Errorf("missing %d of %d required permissions", len(missing), len(results))
+			}
+			logz.Host().Info().Msg(color.GreenString("All required permissions are granted"))
+			return nil
+		},
+	}
+	addRBACFlags(doctorCmd, &opts)
+	doctorCmd.Flags().BoolVar(&opts.CreatePDB, "create-pdb", true, "Include PodDisruptionBudget permissions in the check")
+	doctorCmd.Flags().BoolVar(&opts.AsJob, "as-job", false, "Include Job permissions needed for exec --as-job in the check")
+	doctorCmd.Flags().BoolVar(&opts.Daemon, "daemon", false, "Include permissions needed for daemon start in the check")
+	doctorCmd.Flags().BoolVar(&opts.PassthroughCredentials, "passthrough-credentials", false, "Include permissions needed for credential passthrough in the check")
+	return doctorCmd
+}
@@ -1,18 +1,26 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/user"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
 
 	"github.com/noksa/helm-in-pod/internal"
 	"github.com/noksa/helm-in-pod/internal/cmdoptions"
 	"github.com/noksa/helm-in-pod/internal/helmtar"
+	"github.com/noksa/helm-in-pod/internal/hiperrors"
+	"github.com/noksa/helm-in-pod/internal/hipns"
+	"github.com/noksa/helm-in-pod/internal/hippod"
+	"github.com/noksa/helm-in-pod/internal/hipreport"
 	"github.com/noksa/helm-in-pod/internal/logz"
 )
 
@@ -29,109 +37,197 @@ The pod is deleted after the command completes, even on failure.`,
 	}
 	opts := cmdoptions.ExecOptions{}
 	addExecOptionsFlags(execCmd, &opts)
-	execCmd.RunE = func(cmd *cobra.Command, args []string) (returnErr error) {
-		if len(args) == 0 {
+	addPodScriptWaitFlags(execCmd, &opts)
+	execCmd.Flags().BoolVar(&opts.Attach, "attach", false, "Attach to the command instead of streaming pod logs, so its stdout goes to stdout and its stderr to stderr (e.g. to redirect 'helm template' output to a file)")
+	addStdinFlag(execCmd, &opts)
+	addScriptFlags(execCmd, &opts)
+	addFanOutFlags(execCmd, &opts)
+	addJobFlags(execCmd, &opts)
+	addReportFlag(execCmd, &opts)
+	execCmd.Flags().BoolVar(&opts.Detach, "detach", false, "Start the command, print its operation ID and return without waiting. The pod is kept; follow it with 'helm in-pod logs', 'wait' or 'result'")
+	execCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && opts.Script == "" {
 			return fmt.Errorf("specify command to run. Run `helm in-pod exec --help` to check available options")
 		}
+		cmdToUse, err := commandToRun(opts, args, os.Stdin)
+		if err != nil {
+			return err
+		}
 		if opts.CopyAttempts < 1 {
 			return fmt.Errorf("copy-attempts value can't be less 1")
 		}
 		if opts.UpdateRepoAttempts < 1 {
 			return fmt.Errorf("update-repo-attempts value can't be less 1")
 		}
+		if opts.JobTTLSeconds < 0 || opts.JobBackoffLimit < 0 {
+			return fmt.Errorf("job-ttl-seconds and job-backoff-limit values can't be negative")
+		}
 
 		timeout := viper.GetDuration("timeout")
 		opts.Timeout = timeout + time.Minute*10
 
+		// stdin can only be forwarded through an attached exec session
+		if opts.Stdin {
+			opts.Attach = true
+		}
+		if err := validateDetach(opts); err != nil {
+			return err
+		}
+
 		// Handle dry-run: print pod spec and exit
 		if opts.DryRun {
 			return internal.Pod().PrintPodSpecYAML(opts, false)
 		}
 
-		defer func() {
-			cleanupErr := internal.Pod().DeleteHelmPods(opts, cmdoptions.PurgeOptions{All: false})
-			if cleanupErr != nil && returnErr == nil {
-				returnErr = cleanupErr
-			}
-		}()
+		if len(opts.Contexts) > 0 || opts.AllContexts {
+			return runExecFanOut(cmd.Context(), opts, cmdToUse)
+		}
+		err = runExecInPod(cmd.Context(), internal.Namespace(), internal.Pod(), opts, cmdToUse, os.Stderr)
+		return writeReport(internal.Pod(), opts, err)
+	}
+	return execCmd
+}
 
-		// Parse file mappings
-		opts.ParseFileMappings()
+// runExecInPod runs command in a one-shot pod created by pm and deletes the pod
+// afterwards. Preflight failures are reported to stderr.
+func runExecInPod(ctx context.Context, nsm *hipns.Manager, pm *hippod.Manager, opts cmdoptions.ExecOptions, command string, stderr io.Writer) (returnErr error) {
+	if err := runPreflight(nsm, opts, stderr); err != nil {
+		return err
+	}
 
-		// Prepare namespace and create pod
-		err := internal.Namespace().PrepareNs()
-		if err != nil {
+	detached := false
+	defer func() {
+		// A detached pod keeps running the command after the client exits
+		if detached {
+			return
+		}
+		stopCleanup := pm.Report().Start(hipreport.PhaseCleanup)
+		cleanupErr := pm.DeleteHelmPods(opts, cmdoptions.PurgeOptions{All: false})
+		stopCleanup()
+		if cleanupErr != nil && returnErr == nil {
+			returnErr = cleanupErr
+		}
+	}()
+
+	pod, err := createAndPrepareHelmPod(nsm, pm, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.Detach {
+		if err := pm.StartCommand(pod, command, opts); err != nil {
 			return err
 		}
+		detached = true
+		printDetachedOperation(pm.OperationID(), pod.Name)
+		return nil
+	}
 
-		pod, err := internal.Pod().CreateHelmPod(opts)
+	execErr := pm.ExecuteCommand(ctx, pod, command, opts)
+	// The Job controller replaces a pod whose command failed until the backoff limit is reached
+	var exitErr *hiperrors.ExitCodeError
+	for attempt := int32(0); opts.AsJob && attempt < opts.JobBackoffLimit && errors.As(execErr, &exitErr); attempt++ {
+		if len(opts.CopyFrom) > 0 {
+			pm.SignalCopyDone(pod)
+		}
+		pod, err = pm.NextJobPod(pod, opts)
 		if err != nil {
 			return err
 		}
+		if err = prepareHelmPod(pm, pod, opts); err != nil {
+			return err
+		}
+		execErr = pm.ExecuteCommand(ctx, pod, command, opts)
+	}
 
-		cmdToUse := strings.Join(args, " ")
+	// Copy files from pod to host (even if command failed, user may want artifacts)
+	if len(opts.CopyFrom) > 0 {
+		copyErr := copyFromHelmPod(pm, pod, opts)
+		// Signal the pod that copy is done so it can exit
+		pm.SignalCopyDone(pod)
+		if copyErr != nil && execErr == nil {
+			return copyErr
+		}
+	}
 
-		bundle := make([]helmtar.BundleEntry, 0, len(opts.FilesAsMap))
-		for src, dest := range opts.FilesAsMap {
-			expandedSrc, expandErr := expand(src)
-			if expandErr != nil {
-				return expandErr
-			}
-			bundle = append(bundle, helmtar.BundleEntry{SrcPath: expandedSrc, DestPath: dest})
+	return execErr
+}
+
+// createAndPrepareHelmPod creates a one-shot pod and copies files and Helm
+// repositories into it. The caller is responsible for deleting the pod.
+func createAndPrepareHelmPod(nsm *hipns.Manager, pm *hippod.Manager, opts cmdoptions.ExecOptions) (*corev1.Pod, error) {
+	// Prepare namespace and create pod
+	stopNamespace := pm.Report().Start(hipreport.PhaseNamespace)
+	err := nsm.PrepareNs(opts)
+	stopNamespace()
+	if err != nil {
+		return nil, err
+	}
+
+	pod, err := pm.CreateHelmPod(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := prepareHelmPod(pm, pod, opts); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// prepareHelmPod copies files and Helm repositories into a ready pod.
+func prepareHelmPod(pm *hippod.Manager, pod *corev1.Pod, opts cmdoptions.ExecOptions) error {
+	// Parse file mappings
+	opts.ParseFileMappings()
+
+	bundle := make([]helmtar.BundleEntry, 0, len(opts.FilesAsMap))
+	for src, dest := range opts.FilesAsMap {
+		expandedSrc, expandErr := expand(src)
+		if expandErr != nil {
+			return expandErr
 		}
+		bundle = append(bundle, helmtar.BundleEntry{SrcPath: expandedSrc, DestPath: dest, Excludes: opts.CopyExclude})
+	}
 
-		bootInfo, err := internal.Pod().CopyFilesBundleWithBootInfo(pod, bundle, nil, opts.CopyAttempts)
+	bootInfo, err := pm.CopyFilesBundleWithBootInfo(pod, bundle, nil, opts.CopyAttempts, opts.CopyTimeout)
+	if err != nil {
+		return err
+	}
+
+	if !bootInfo.HelmFound {
+		logz.Pod().Warn().Msg("helm is not installed in the image, all helm prerequisites will be skipped. If the passed command contains helm calls, it will fail")
+	}
+
+	if opts.CopyRepo && bootInfo.HelmFound {
+		err = pm.SyncHelmRepositories(pod, opts, bootInfo.HomeDirectory, bootInfo.IsHelm4)
 		if err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		if !bootInfo.HelmFound {
-			logz.Pod().Warn().Msg("helm is not installed in the image, all helm prerequisites will be skipped. If the passed command contains helm calls, it will fail")
+// copyFromHelmPod copies every --copy-from mapping to the host. All mappings are
+// attempted and the first error is returned.
+func copyFromHelmPod(pm *hippod.Manager, pod *corev1.Pod, opts cmdoptions.ExecOptions) error {
+	copyFromMap, err := parseCopyFromMappings(opts.CopyFrom)
+	if err != nil {
+		return err
+	}
+	var copyErrors []error
+	for podPath, hostPath := range copyFromMap {
+		expanded, expandErr := expand(hostPath)
+		if expandErr != nil {
+			copyErrors = append(copyErrors, expandErr)
+			continue
 		}
-
-		if opts.CopyRepo && bootInfo.HelmFound {
-			err = internal.Pod().SyncHelmRepositories(pod, opts, bootInfo.HomeDirectory, bootInfo.IsHelm4)
-			if err != nil {
-				return err
-			}
+		if copyErr := pm.CopyFileFromPod(pod, podPath, expanded, opts.CopyAttempts, opts.CopyTimeout); copyErr != nil {
+			copyErrors = append(copyErrors, copyErr)
 		}
-
-		execErr := internal.Pod().ExecuteCommand(cmd.Context(), pod, cmdToUse, opts)
-
-		// Copy files from pod to host (even if command failed, user may want artifacts)
-		if len(opts.CopyFrom) > 0 {
-			copyFromMap, parseErr := parseCopyFromMappings(opts.CopyFrom)
-			if parseErr != nil {
-				internal.Pod().SignalCopyDone(pod)
-				if execErr != nil {
-					return execErr
-				}
-				return parseErr
-			}
-			var copyErrors []error
-			for podPath, hostPath := range copyFromMap {
-				expanded, expandErr := expand(hostPath)
-				if expandErr != nil {
-					copyErrors = append(copyErrors, expandErr)
-					continue
-				}
-				if copyErr := internal.Pod().CopyFileFromPod(pod, podPath, expanded, opts.CopyAttempts); copyErr != nil {
-					copyErrors = append(copyErrors, copyErr)
-				}
-			}
-			// Signal the pod that copy is done so it can exit
-			internal.Pod().SignalCopyDone(pod)
-			if len(copyErrors) > 0 {
-				if execErr != nil {
-					return execErr
-				}
-				return copyErrors[0]
-			}
-		}
-
-		return execErr
 	}
-	return execCmd
+	if len(copyErrors) > 0 {
+		return copyErrors[0]
+	}
+	return nil
 }
 
 func expand(path string) (string, error) {
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/noksa/helm-in-pod/internal"
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hiperrors"
+	"github.com/noksa/helm-in-pod/internal/hiptrace"
+	"github.com/noksa/helm-in-pod/internal/logz"
+)
+
+// addFanOutFlags registers the flags that run exec in several kube contexts.
+func addFanOutFlags(cmd *cobra.Command, opts *cmdoptions.ExecOptions) {
+	cmd.Flags().StringSliceVar(&opts.Contexts, "contexts", []string{}, "Run the command in each of these kube contexts concurrently. Output lines are prefixed with the context name")
+	cmd.Flags().BoolVar(&opts.AllContexts, "all-contexts", false, "Run the command in every context of the kubeconfig concurrently")
+	cmd.Flags().IntVar(&opts.Parallelism, "parallelism", 4, "Maximum number of contexts to run at the same time with --contexts or --all-contexts")
+}
+
+// contextResult is the outcome of the command in one kube context.
+type contextResult struct {
+	Context  string
+	Duration time.Duration
+	Err      error
+}
+
+// ExitCode returns the command's exit code, 1 if it failed without one.
+func (r contextResult) ExitCode() int32 {
+	if r.Err == nil {
+		return 0
+	}
+	var exitErr *hiperrors.ExitCodeError
+	if errors.As(r.Err, &exitErr) {
+		return exitErr.Code
+	}
+	return 1
+}
+
+// fanOutContexts returns the deduplicated contexts to run in.
+func fanOutContexts(opts cmdoptions.ExecOptions) ([]string, error) {
+	if opts.AllContexts && len(opts.Contexts) > 0 {
+		return nil, fmt.Errorf("--contexts and --all-contexts can't be used together")
+	}
+	if opts.Parallelism < 1 {
+		return nil, fmt.Errorf("parallelism value can't be less 1")
+	}
+	if opts.Stdin {
+		return nil, fmt.Errorf("--stdin can't be used with --contexts or --all-contexts")
+	}
+	if opts.Report != "" {
+		return nil, fmt.Errorf("--report can't be used with --contexts or --all-contexts")
+	}
+	if opts.AllContexts {
+		contexts, err := internal.KubeContexts()
+		if err != nil {
+			return nil, err
+		}
+		if len(contexts) == 0 {
+			return nil, fmt.Errorf("no contexts found in kubeconfig")
+		}
+		return contexts, nil
+	}
+	contexts := make([]string, 0, len(opts.Contexts))
+	for _, c := range opts.Contexts {
+		if c != "" && !slices.Contains(contexts, c) {
+			contexts = append(contexts, c)
+		}
+	}
+	if len(contexts) == 0 {
+		return nil, fmt.Errorf("--contexts requires at least one context")
+	}
+	return contexts, nil
+}
+
+// runExecFanOut runs command in a one-shot pod in every context, at most
+// opts.Parallelism at a time, prints a summary table and returns the worst exit code.
+func runExecFanOut(ctx context.Context, opts cmdoptions.ExecOptions, command string) error {
+	contexts, err := fanOutContexts(opts)
+	if err != nil {
+		return err
+	}
+	logz.Host().Info().Msgf("Running the command in %v contexts, %v at a time", len(contexts), opts.Parallelism)
+
+	// Shared by all prefixed writers so lines of different contexts don't interleave
+	outputMu := &sync.Mutex{}
+	results := make([]contextResult, len(contexts))
+	sem := make(chan struct{}, opts.Parallelism)
+	wg := sync.WaitGroup{}
+	for i, kubeContext := range contexts {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = runExecInContext(ctx, kubeContext, opts, command, outputMu)
+		})
+	}
+	wg.Wait()
+
+	renderFanOutSummary(os.Stderr, results)
+	return fanOutError(results)
+}
+
+func runExecInContext(ctx context.Context, kubeContext string, opts cmdoptions.ExecOptions, command string, outputMu *sync.Mutex) contextResult {
+	start := time.Now()
+	prefix := fmt.Sprintf("[%s] ", color.BlueString(kubeContext))
+	stdout := newPrefixWriter(os.Stdout, prefix, outputMu)
+	stderr := newPrefixWriter(os.Stderr, prefix, outputMu)
+	defer func() {
+		stdout.Flush()
+		stderr.Flush()
+	}()
+
+	ctx, span := hiptrace.Start(ctx, "context "+kubeContext, attribute.String("k8s.context", kubeContext))
+	nsm, pm, err := internal.NewContextManagers(ctx, kubeContext, internal.Pod().Namespace(), stdout, stderr)
+	if err == nil {
+		err = runExecInPod(ctx, nsm, pm, opts, command, stderr)
+	}
+	hiptrace.End(span, err)
+	if err != nil {
+		logz.Host().Debug().Msgf("Context %v failed: %v", kubeContext, err)
+	}
+	return contextResult{Context: kubeContext, Duration: time.Since(start), Err: err}
+}
+
+// fanOutError returns an *hiperrors.ExitCodeError with the highest exit code, or nil
+// if the command succeeded in every context.
+func fanOutError(results []contextResult) error {
+	var worst int32
+	for _, r := range results {
+		worst = max(worst, r.ExitCode())
+	}
+	if worst == 0 {
+		return nil
+	}
+	return &hiperrors.ExitCodeError{Code: worst}
+}
+
+func renderFanOutSummary(w io.Writer, results []contextResult) {
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		result := color.GreenString("succeeded")
+		var exitErr *hiperrors.ExitCodeError
+		switch {
+		case r.Err == nil:
+		case errors.As(r.Err, &exitErr):
+			result = color.RedString("failed")
+		default:
+			result = color.RedString("error: %v", r.Err)
+		}
+		rows = append(rows, []string{r.Context, strconv.Itoa(int(r.ExitCode())), r.Duration.Round(time.Second).String(), result})
+	}
+	table := cyberTable(w)
+	table.Header([]string{"CONTEXT", "EXIT CODE", "DURATION", "RESULT"})
+	_ = table.Bulk(rows)
+	_ = table.Render()
+}
+
+// prefixWriter prefixes every line written to it. Complete lines are written
+// while holding mu, which is shared between writers to keep lines intact.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func newPrefixWriter(w io.Writer, prefix string, mu *sync.Mutex) *prefixWriter {
+	return &prefixWriter{w: w, prefix: prefix, mu: mu}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if _, err := fmt.Fprintf(p.w, "%s%s", p.prefix, p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes a trailing line that did not end with a newline.
+func (p *prefixWriter) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.buf) > 0 {
+		_, _ = fmt.Fprintf(p.w, "%s%s\n", p.prefix, p.buf)
+		p.buf = nil
+	}
+}
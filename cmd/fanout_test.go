@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hiperrors"
+)
+
+var _ = Describe("Fan-out", func() {
+	Describe("fanOutContexts", func() {
+		It("should deduplicate contexts and keep their order", func() {
+			contexts, err := fanOutContexts(cmdoptions.ExecOptions{Contexts: []string{"eu", "us", "eu", ""}, Parallelism: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contexts).To(Equal([]string{"eu", "us"}))
+		})
+
+		It("should reject --contexts together with --all-contexts", func() {
+			_, err := fanOutContexts(cmdoptions.ExecOptions{Contexts: []string{"eu"}, AllContexts: true, Parallelism: 1})
+			Expect(err).To(MatchError(ContainSubstring("can't be used together")))
+		})
+
+		It("should reject a parallelism below 1", func() {
+			_, err := fanOutContexts(cmdoptions.ExecOptions{Contexts: []string{"eu"}})
+			Expect(err).To(MatchError(ContainSubstring("parallelism")))
+		})
+
+		It("should reject --stdin", func() {
+			_, err := fanOutContexts(cmdoptions.ExecOptions{Contexts: []string{"eu"}, Parallelism: 1, Stdin: true})
+			Expect(err).To(MatchError(ContainSubstring("--stdin")))
+		})
+
+		It("should reject --report", func() {
+			_, err := fanOutContexts(cmdoptions.ExecOptions{Contexts: []string{"eu"}, Parallelism: 1, Report: "report.json"})
+			Expect(err).To(MatchError(ContainSubstring("--report")))
+		})
+	})
+
+	Describe("fanOutError", func() {
+		It("should return nil when every context succeeded", func() {
+			Expect(fanOutError([]contextResult{{Context: "a"}, {Context: "b"}})).To(Succeed())
+		})
+
+		It("should return the worst exit code", func() {
+			err := fanOutError([]contextResult{
+				{Context: "a", Err: &hiperrors.ExitCodeError{Code: 2}},
+				{Context: "b", Err: fmt.Errorf("connection refused")},
+				{Context: "c"},
+			})
+			Expect(err).To(Equal(&hiperrors.ExitCodeError{Code: 2}))
+		})
+
+		It("should count errors without an exit code as 1", func() {
+			Expect(contextResult{Err: fmt.Errorf("boom")}.ExitCode()).To(Equal(int32(1)))
+			Expect(fanOutError([]contextResult{{Err: fmt.Errorf("boom")}})).To(Equal(&hiperrors.ExitCodeError{Code: 1}))
+		})
+	})
+
+	Describe("renderFanOutSummary", func() {
+		It("should list every context with its exit code", func() {
+			buf := &bytes.Buffer{}
+			renderFanOutSummary(buf, []contextResult{
+				{Context: "eu-west", Err: &hiperrors.ExitCodeError{Code: 3}},
+				{Context: "us-east"},
+			})
+			Expect(buf.String()).To(ContainSubstring("eu-west"))
+			Expect(buf.String()).To(ContainSubstring("us-east"))
+			Expect(buf.String()).To(ContainSubstring("3"))
+		})
+	})
+
+	Describe("prefixWriter", func() {
+		It("should prefix complete lines and flush the rest", func() {
+			buf := &bytes.Buffer{}
+			w := newPrefixWriter(buf, "[eu] ", &sync.Mutex{})
+			_, _ = w.Write([]byte("first\nsec"))
+			Expect(buf.String()).To(Equal("[eu] first\n"))
+			_, _ = w.Write([]byte("ond\nthird"))
+			w.Flush()
+			Expect(buf.String()).To(Equal("[eu] first\n[eu] second\n[eu] third\n"))
+		})
+	})
+})
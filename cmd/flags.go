@@ -2,13 +2,20 @@ package cmd
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/helmtar"
 	"github.com/noksa/helm-in-pod/internal/hipconsts"
+	"github.com/noksa/helm-in-pod/internal/hipenv"
+	"github.com/noksa/helm-in-pod/internal/hipredact"
 )
 
 func getDaemonName(name string) (string, error) {
@@ -21,12 +28,112 @@ func getDaemonName(name string) (string, error) {
 	return name, nil
 }
 
+// getPluginNamespace resolves the namespace for plugin pods: --plugin-namespace,
+// then HELM_IN_POD_NAMESPACE, then the default helm-in-pod namespace.
+func getPluginNamespace(namespace string) string {
+	if namespace == "" {
+		namespace = os.Getenv(hipconsts.EnvNamespace)
+	}
+	if namespace == "" {
+		namespace = hipconsts.HelmInPodNamespace
+	}
+	return namespace
+}
+
 func addExecOptionsFlags(cmd *cobra.Command, opts *cmdoptions.ExecOptions) {
 	addPodCreationFlags(cmd, opts)
 	addRuntimeFlags(cmd, opts, true)
+	addPhaseTimeoutFlags(cmd, opts)
 	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
-		return validateResourceFlags(cmd, opts)
+		if err := validateResourceFlags(cmd, opts); err != nil {
+			return err
+		}
+		if err := validatePhaseTimeouts(cmd, opts); err != nil {
+			return err
+		}
+		if err := validateCopyExclude(opts); err != nil {
+			return err
+		}
+		if err := loadEnvFiles(opts); err != nil {
+			return err
+		}
+		if err := validateSecretEnv(opts); err != nil {
+			return err
+		}
+		redactSensitiveEnv(opts)
+		return validateRBACFlags(opts)
+	}
+}
+
+// addPhaseTimeoutFlags registers the timeouts of the phases of the pod's lifecycle.
+func addPhaseTimeoutFlags(cmd *cobra.Command, opts *cmdoptions.ExecOptions) {
+	cmd.Flags().DurationVar(&opts.StartupTimeout, "startup-timeout", hipconsts.DefaultStartupTimeout, "How long to wait for the pod to be scheduled, pull its image and become ready")
+	cmd.Flags().DurationVar(&opts.CompletionTimeout, "completion-timeout", hipconsts.DefaultCompletionTimeout, "How long to wait for the pod to terminate after the command when its result can't be read from the pod")
+	cmd.Flags().DurationVar(&opts.GracePeriod, "grace-period", hipconsts.DefaultGracePeriod, "Termination grace period of the pod: how long Kubernetes waits after SIGTERM before it kills the pod")
+}
+
+// addPodScriptWaitFlags registers the waits of the pod script around a one-shot
+// command. Daemon pods don't run the command through the pod script.
+func addPodScriptWaitFlags(cmd *cobra.Command, opts *cmdoptions.ExecOptions) {
+	cmd.Flags().DurationVar(&opts.TerminateTimeout, "terminate-timeout", hipconsts.DefaultTerminateTimeout, "How long the pod waits for the command to exit after it is interrupted or timed out. Must be shorter than --grace-period")
+	cmd.Flags().DurationVar(&opts.CopyFromWait, "copy-from-wait", hipconsts.DefaultCopyFromWait, "How long the pod is kept alive after the command for --copy-from to finish")
+	cmd.Flags().DurationVar(&opts.ReadWait, "read-wait", hipconsts.DefaultReadWait, "How long the pod is kept alive after the command for the plugin to read its output and result")
+}
+
+func addDeleteTimeoutFlag(cmd *cobra.Command, timeout *time.Duration) {
+	cmd.Flags().DurationVar(timeout, "delete-timeout", hipconsts.DefaultDeleteTimeout, "How long to wait for a daemon pod to be deleted")
+}
+
+// validatePhaseTimeouts checks that the phase timeouts registered on cmd are
+// positive and that the pod script gives up on the command before Kubernetes
+// kills the pod.
+func validatePhaseTimeouts(cmd *cobra.Command, opts *cmdoptions.ExecOptions) error {
+	timeouts := map[string]time.Duration{
+		"startup-timeout":    opts.StartupTimeout,
+		"copy-timeout":       opts.CopyTimeout,
+		"completion-timeout": opts.CompletionTimeout,
+		"delete-timeout":     opts.DeleteTimeout,
+		"grace-period":       opts.GracePeriod,
+		"terminate-timeout":  opts.TerminateTimeout,
+		"copy-from-wait":     opts.CopyFromWait,
+		"read-wait":          opts.ReadWait,
+	}
+	for _, name := range slices.Sorted(maps.Keys(timeouts)) {
+		if cmd.Flags().Lookup(name) != nil && timeouts[name] <= 0 {
+			return fmt.Errorf("%s value must be positive", name)
+		}
+	}
+	if cmd.Flags().Lookup("terminate-timeout") != nil && opts.TerminateTimeout >= opts.GracePeriod {
+		return fmt.Errorf("terminate-timeout (%v) must be shorter than grace-period (%v)", opts.TerminateTimeout, opts.GracePeriod)
+	}
+	return nil
+}
+
+// validateRBACFlags resolves the default RBAC mode and checks that the mode
+// is consistent with --rbac-namespaces and --service-account.
+func validateRBACFlags(opts *cmdoptions.ExecOptions) error {
+	if opts.RBACMode == "" {
+		opts.RBACMode = hipconsts.RBACModeCluster
+		if opts.ServiceAccount != "" || opts.PassthroughCredentials {
+			opts.RBACMode = hipconsts.RBACModeNone
+		}
+	}
+	switch opts.RBACMode {
+	case hipconsts.RBACModeCluster, hipconsts.RBACModeNone:
+		if len(opts.RBACNamespaces) > 0 {
+			return fmt.Errorf("--rbac-namespaces can only be used with --rbac-mode=%s", hipconsts.RBACModeNamespaced)
+		}
+	case hipconsts.RBACModeNamespaced:
+		if len(opts.RBACNamespaces) == 0 {
+			return fmt.Errorf("--rbac-mode=%s requires --rbac-namespaces", hipconsts.RBACModeNamespaced)
+		}
+	default:
+		return fmt.Errorf("invalid --rbac-mode %q, must be one of: %s, %s, %s", opts.RBACMode, hipconsts.RBACModeCluster, hipconsts.RBACModeNamespaced, hipconsts.RBACModeNone)
+	}
+	if opts.RBACMode == hipconsts.RBACModeNone && opts.ServiceAccount == "" && !opts.PassthroughCredentials {
+		return fmt.Errorf("--rbac-mode=%s requires --service-account or --passthrough-credentials", hipconsts.RBACModeNone)
 	}
+	return nil
 }
 
 func validateResourceFlags(cmd *cobra.Command, opts *cmdoptions.ExecOptions) error {
@@ -97,22 +204,127 @@ func addPodCreationFlags(cmd *cobra.Command, opts *cmdoptions.ExecOptions) {
 	cmd.Flags().StringVar(&opts.PullPolicy, "pull-policy", "IfNotPresent", "Image pull policy for the pod")
 	cmd.Flags().StringVarP(&opts.Image, "image", "i", "docker.io/noksa/kubectl-helm:v1.34.5-v4.1.1", "Docker image to use for the pod")
 	cmd.Flags().StringSliceVar(&opts.Volumes, "volume", []string{}, "Mount volumes in the pod. Format: type:name:mountPath[:ro]. Types: pvc, secret, configmap, hostpath. Examples: 'pvc:my-claim:/data', 'secret:my-secret:/etc/creds:ro', 'configmap:my-cm:/etc/config', 'hostpath:/var/log:/host-logs:ro'")
-	cmd.Flags().StringVar(&opts.ServiceAccount, "service-account", "", "Service account to use in the pod (default: helm-in-pod)")
+	addRBACFlags(cmd, opts)
+	cmd.Flags().BoolVar(&opts.PassthroughCredentials, "passthrough-credentials", false, "Run commands in the pod with your own kubeconfig credentials (bearer token, exec plugin token or client certificate) instead of the service account token. Credentials are delivered in a short-lived Secret removed together with the pod. Implies --rbac-mode=none unless set explicitly")
+	cmd.Flags().BoolVar(&opts.Preflight, "preflight", true, "Check with SelfSubjectAccessReview that all required permissions are granted before creating anything")
 	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Print the pod spec as YAML without creating the pod")
 	cmd.Flags().Int64Var(&opts.ActiveDeadlineSeconds, "active-deadline-seconds", 0, "Maximum duration in seconds the pod is allowed to run. The pod will be terminated by Kubernetes once this deadline is exceeded, regardless of whether the client is still connected. Useful to avoid orphaned pods in CI/CD pipelines. 0 means no deadline (default)")
 }
 
+// addJobFlags registers the flags that run the one-shot pod through a Job.
+func addJobFlags(cmd *cobra.Command, opts *cmdoptions.ExecOptions) {
+	cmd.Flags().BoolVar(&opts.AsJob, "as-job", false, "Run the pod through a batch/v1 Job, so Kubernetes cleans it up after --job-ttl-seconds even if the client dies and retries failed commands up to --job-backoff-limit times. --active-deadline-seconds is applied to the Job")
+	cmd.Flags().Int32Var(&opts.JobTTLSeconds, "job-ttl-seconds", 600, "Seconds after the Job finishes before Kubernetes deletes it together with its pods, with --as-job")
+	cmd.Flags().Int32Var(&opts.JobBackoffLimit, "job-backoff-limit", 0, "Number of times a failed command is retried in a new pod of the Job, with --as-job")
+}
+
+// addRBACFlags registers flags that decide which service account the pod uses
+// and which bindings are created for it.
+func addRBACFlags(cmd *cobra.Command, opts *cmdoptions.ExecOptions) {
+	cmd.Flags().StringVar(&opts.ServiceAccount, "service-account", "", "Service account to use in the pod (default: helm-in-pod)")
+	cmd.Flags().StringVar(&opts.RBACMode, "rbac-mode", "", "How the pod's service account gets permissions: 'cluster' binds --rbac-cluster-role cluster-wide, 'namespaced' creates RoleBindings in --rbac-namespaces only, 'none' creates no bindings (default: none when --service-account or --passthrough-credentials is set, otherwise cluster)")
+	cmd.Flags().StringVar(&opts.RBACClusterRole, "rbac-cluster-role", hipconsts.DefaultRBACClusterRole, "Existing ClusterRole to bind in 'cluster' and 'namespaced' RBAC modes")
+	cmd.Flags().StringSliceVar(&opts.RBACNamespaces, "rbac-namespaces", []string{}, "Target namespaces to create RoleBindings in for 'namespaced' RBAC mode")
+}
+
 func addRuntimeFlags(cmd *cobra.Command, opts *cmdoptions.ExecOptions, copyRepoDefault bool) {
 	cmd.Flags().StringToStringVarP(&opts.Env, "env", "e", map[string]string{}, "Environment variables to set in the pod before running the command")
-	cmd.Flags().StringSliceVarP(&opts.SubstEnv, "subst-env", "s", []string{}, "Forward environment variables from the host to the pod by name (values are resolved from the host). Example: -s HELM_DRIVER,HELM_DRIVER_SQL_CONNECTION_STRING")
+	cmd.Flags().StringSliceVarP(&opts.SubstEnv, "subst-env", "s", []string{}, "Forward environment variables from the host to the pod (values are resolved from the host). Accepts names, glob patterns and HOST_NAME=POD_NAME renames. Example: -s HELM_DRIVER,'CI_*',AWS_PROFILE=PROFILE")
+	cmd.Flags().StringSliceVar(&opts.SubstEnvExclude, "subst-env-exclude", []string{}, "Glob patterns of host variables that --subst-env patterns must not forward. Example: --subst-env-exclude '*_TOKEN'")
+	cmd.Flags().StringSliceVar(&opts.EnvFiles, "env-file", []string{}, "Read environment variables from a dotenv file on the host. Later files override earlier ones, --env overrides them all. Repeatable")
+	cmd.Flags().StringSliceVar(&opts.EnvFrom, "env-from", []string{}, "Set environment variables from the keys of a Secret or ConfigMap in the plugin namespace. Format: type:name[:prefix]. Types: secret, configmap. Examples: 'secret:deploy-creds', 'configmap:settings:APP_'. Repeatable")
+	cmd.Flags().StringToStringVar(&opts.SecretEnv, "secret-env", map[string]string{}, "Sensitive environment variables to set in the pod. Values are delivered through a per-operation Secret instead of the pod spec and masked in logs")
+	cmd.Flags().StringSliceVar(&opts.SecretSubstEnv, "secret-subst-env", []string{}, "Forward sensitive environment variables from the host to the pod by name, delivered like --secret-env. Example: --secret-subst-env HELM_DRIVER_SQL_CONNECTION_STRING")
 	cmd.Flags().BoolVar(&opts.CopyRepo, "copy-repo", copyRepoDefault, "Copy Helm repositories from the host to the pod")
 	cmd.Flags().StringSliceVar(&opts.UpdateRepo, "update-repo", []string{}, "Helm repository aliases to update in the pod after copying. Requires --copy-repo. If specified without values, all repositories are updated")
 	cmd.Flags().StringSliceVarP(&opts.Files, "copy", "c", []string{}, "Copy files/directories from host to pod. Format: /host/path:/pod/path. Repeatable")
+	cmd.Flags().StringSliceVar(&opts.CopyExclude, "copy-exclude", []string{}, "Patterns of files under copied directories not to copy, in .helmignore syntax, in addition to the directories' .hipignore files. Example: --copy-exclude .git/,node_modules/,'*.tgz'")
 	cmd.Flags().IntVar(&opts.CopyAttempts, "copy-attempts", 3, "Retry count for file copy operations (default: 3)")
+	cmd.Flags().DurationVar(&opts.CopyTimeout, "copy-timeout", hipconsts.DefaultCopyTimeout, "Timeout of each attempt to copy files to or from the pod")
 	cmd.Flags().IntVar(&opts.UpdateRepoAttempts, "update-repo-attempts", 3, "Retry count for Helm repo update operations (default: 3)")
 	cmd.Flags().StringSliceVar(&opts.CopyFrom, "copy-from", []string{}, "Copy files/directories from pod to host after execution. Format: /pod/path:/host/path. Repeatable")
 }
 
+// validateCopyExclude checks the --copy-exclude patterns before anything is created.
+func validateCopyExclude(opts *cmdoptions.ExecOptions) error {
+	_, err := helmtar.ParseExcludes(opts.CopyExclude)
+	return err
+}
+
+// loadEnvFiles adds the variables of the --env-file files to Env. Later files
+// override earlier ones and --env overrides them all.
+func loadEnvFiles(opts *cmdoptions.ExecOptions) error {
+	if len(opts.EnvFiles) == 0 {
+		return nil
+	}
+	env := map[string]string{}
+	for _, path := range opts.EnvFiles {
+		fileEnv, err := hipenv.ReadDotenvFile(path)
+		if err != nil {
+			return err
+		}
+		maps.Copy(env, fileEnv)
+	}
+	maps.Copy(env, opts.Env)
+	opts.Env = env
+	return nil
+}
+
+// validateSecretEnv checks the --subst-env specs, and that secret variables can
+// be stored as Secret keys and are not set in plain text as well.
+func validateSecretEnv(opts *cmdoptions.ExecOptions) error {
+	forwarded, err := opts.ForwardedEnv()
+	if err != nil {
+		return err
+	}
+	plain := slices.Collect(maps.Keys(opts.Env))
+	for _, env := range forwarded {
+		plain = append(plain, env.Name)
+	}
+	for _, name := range slices.Sorted(maps.Keys(opts.SecretEnvValues())) {
+		if errs := validation.IsConfigMapKey(name); len(errs) > 0 {
+			return fmt.Errorf("invalid secret environment variable name %q: %s", name, strings.Join(errs, "; "))
+		}
+		if slices.Contains(plain, name) {
+			return fmt.Errorf("%s is set by both --env/--env-file/--subst-env and --secret-env/--secret-subst-env", name)
+		}
+	}
+	return nil
+}
+
+// redactSensitiveEnv masks the values of secret variables and of --env and
+// --subst-env variables with sensitive names, e.g. HELM_DRIVER_SQL_CONNECTION_STRING.
+func redactSensitiveEnv(opts *cmdoptions.ExecOptions) {
+	hipredact.AddEnv(opts.Env)
+	// Forwarded variables are validated by validateSecretEnv
+	forwarded, _ := opts.ForwardedEnv()
+	for _, env := range forwarded {
+		if hipredact.IsSensitiveName(env.Name) || hipredact.IsSensitiveName(env.HostName) {
+			hipredact.AddValues(env.Value)
+		}
+	}
+	for _, value := range opts.SecretEnvValues() {
+		hipredact.AddValues(value)
+	}
+}
+
+// addShellFlags registers the flags shared by shell and daemon shell.
+func addShellFlags(cmd *cobra.Command, opts *cmdoptions.ShellOptions) {
+	cmd.Flags().StringVar(&opts.Shell, "shell", "sh", "Shell to use (sh, bash, zsh, etc.), optionally with arguments")
+	cmd.Flags().BoolVar(&opts.Login, "login", true, "Start the shell as a login shell (-l) so profile files are read")
+}
+
+// addStdinFlag registers --stdin. The -i shorthand is not used because it
+// belongs to --image.
+func addStdinFlag(cmd *cobra.Command, opts *cmdoptions.ExecOptions) {
+	cmd.Flags().BoolVar(&opts.Stdin, "stdin", false, "Forward stdin from the host to the command until EOF (e.g. 'cat values.yaml | helm in-pod exec --stdin -- \"helm upgrade x y -f -\"')")
+}
+
+// addReportFlag registers --report.
+func addReportFlag(cmd *cobra.Command, opts *cmdoptions.ExecOptions) {
+	cmd.Flags().StringVar(&opts.Report, "report", "", "Write a JSON report with the pod, boot info, exit code, bytes transferred, retried attempts and the time spent in each phase to this file")
+}
+
 // parseCopyFromMappings parses --copy-from flag values into a map of pod_path -> host_path.
 func parseCopyFromMappings(copyFrom []string) (map[string]string, error) {
 	result := map[string]string{}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+)
+
+var _ = Describe("validateCopyExclude", func() {
+	It("should accept .helmignore patterns", func() {
+		opts := &cmdoptions.ExecOptions{CopyExclude: []string{".git/", "node_modules/", "*.tgz", "/build"}}
+		Expect(validateCopyExclude(opts)).To(Succeed())
+	})
+
+	It("should reject patterns .helmignore doesn't support", func() {
+		opts := &cmdoptions.ExecOptions{CopyExclude: []string{"**/*.tgz"}}
+		Expect(validateCopyExclude(opts)).To(MatchError(ContainSubstring("invalid copy exclude patterns")))
+	})
+})
@@ -72,3 +72,34 @@ var _ = Describe("getDaemonName", func() {
 		})
 	})
 })
+
+var _ = Describe("getPluginNamespace", func() {
+	var originalEnv string
+
+	BeforeEach(func() {
+		originalEnv = os.Getenv(hipconsts.EnvNamespace)
+	})
+
+	AfterEach(func() {
+		if originalEnv != "" {
+			_ = os.Setenv(hipconsts.EnvNamespace, originalEnv)
+		} else {
+			_ = os.Unsetenv(hipconsts.EnvNamespace)
+		}
+	})
+
+	It("should return the provided namespace", func() {
+		_ = os.Setenv(hipconsts.EnvNamespace, "env-ns")
+		Expect(getPluginNamespace("team-a")).To(Equal("team-a"))
+	})
+
+	It("should use environment variable when namespace is not provided", func() {
+		_ = os.Setenv(hipconsts.EnvNamespace, "env-ns")
+		Expect(getPluginNamespace("")).To(Equal("env-ns"))
+	})
+
+	It("should fall back to the default namespace", func() {
+		_ = os.Unsetenv(hipconsts.EnvNamespace)
+		Expect(getPluginNamespace("")).To(Equal(hipconsts.HelmInPodNamespace))
+	})
+})
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipredact"
+)
+
+var _ = Describe("validateSecretEnv", func() {
+	var opts *cmdoptions.ExecOptions
+
+	BeforeEach(func() {
+		opts = &cmdoptions.ExecOptions{
+			Env:       map[string]string{"HELM_DRIVER": "sql"},
+			SubstEnv:  []string{"HELM_NAMESPACE"},
+			SecretEnv: map[string]string{"DB_PASSWORD": "hunter22"},
+		}
+	})
+
+	It("should accept secret variables", func() {
+		opts.SecretSubstEnv = []string{"HELM_DRIVER_SQL_CONNECTION_STRING"}
+		Expect(validateSecretEnv(opts)).To(Succeed())
+	})
+
+	It("should reject names that can't be Secret keys", func() {
+		opts.SecretEnv["MY VAR"] = "value"
+		Expect(validateSecretEnv(opts)).To(MatchError(ContainSubstring(`invalid secret environment variable name "MY VAR"`)))
+	})
+
+	It("should reject variables set in plain text as well", func() {
+		opts.SecretSubstEnv = []string{"HELM_NAMESPACE"}
+		Expect(validateSecretEnv(opts)).To(MatchError(ContainSubstring("HELM_NAMESPACE is set by both")))
+	})
+
+	It("should reject secret variables forwarded under another name", func() {
+		opts.SubstEnv = []string{"LOCAL_PASSWORD=DB_PASSWORD"}
+		Expect(validateSecretEnv(opts)).To(MatchError(ContainSubstring("DB_PASSWORD is set by both")))
+	})
+
+	It("should reject invalid --subst-env specs", func() {
+		opts.SubstEnv = []string{"HIP_["}
+		Expect(validateSecretEnv(opts)).To(MatchError(ContainSubstring("invalid --subst-env")))
+	})
+})
+
+var _ = Describe("redactSensitiveEnv", func() {
+	AfterEach(func() {
+		hipredact.Reset()
+	})
+
+	It("should mask secret values regardless of their names", func() {
+		GinkgoT().Setenv("HIP_TEST_SECRET_SUBST", "from-the-host")
+		redactSensitiveEnv(&cmdoptions.ExecOptions{
+			Env:            map[string]string{"PLAIN": "plain-value"},
+			SecretEnv:      map[string]string{"PLAIN_LOOKING": "hunter22"},
+			SecretSubstEnv: []string{"HIP_TEST_SECRET_SUBST"},
+		})
+		Expect(hipredact.String("hunter22 from-the-host plain-value")).To(Equal("*** *** plain-value"))
+	})
+
+	It("should mask forwarded values with a sensitive host or pod name", func() {
+		GinkgoT().Setenv("HIP_TEST_CI_TOKEN", "token-value")
+		GinkgoT().Setenv("HIP_TEST_PLAIN", "plain-value")
+		redactSensitiveEnv(&cmdoptions.ExecOptions{
+			SubstEnv: []string{"HIP_TEST_CI_TOKEN=REGISTRY_AUTH", "HIP_TEST_PLAIN"},
+		})
+		Expect(hipredact.String("token-value plain-value")).To(Equal("*** plain-value"))
+	})
+})
+
+var _ = Describe("loadEnvFiles", func() {
+	It("should merge the files with --env taking precedence", func() {
+		dir := GinkgoT().TempDir()
+		first := filepath.Join(dir, "base.env")
+		second := filepath.Join(dir, "prod.env")
+		Expect(os.WriteFile(first, []byte("HELM_DRIVER=secret\nREGION=us\nLEVEL=base\n"), 0o600)).To(Succeed())
+		Expect(os.WriteFile(second, []byte("REGION=eu\nLEVEL='prod'\n"), 0o600)).To(Succeed())
+		opts := &cmdoptions.ExecOptions{
+			EnvFiles: []string{first, second},
+			Env:      map[string]string{"LEVEL": "flag"},
+		}
+		Expect(loadEnvFiles(opts)).To(Succeed())
+		Expect(opts.Env).To(Equal(map[string]string{"HELM_DRIVER": "secret", "REGION": "eu", "LEVEL": "flag"}))
+	})
+
+	It("should fail for an invalid file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "broken.env")
+		Expect(os.WriteFile(path, []byte("broken"), 0o600)).To(Succeed())
+		Expect(loadEnvFiles(&cmdoptions.ExecOptions{EnvFiles: []string{path}})).To(MatchError(ContainSubstring("invalid env file")))
+	})
+})
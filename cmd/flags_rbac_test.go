@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+)
+
+var _ = Describe("validateRBACFlags", func() {
+	var opts *cmdoptions.ExecOptions
+
+	BeforeEach(func() {
+		opts = &cmdoptions.ExecOptions{}
+	})
+
+	Context("when --rbac-mode is not set", func() {
+		It("should default to cluster mode", func() {
+			Expect(validateRBACFlags(opts)).To(Succeed())
+			Expect(opts.RBACMode).To(Equal(hipconsts.RBACModeCluster))
+		})
+
+		It("should default to none mode when --service-account is set", func() {
+			opts.ServiceAccount = "deployer"
+			Expect(validateRBACFlags(opts)).To(Succeed())
+			Expect(opts.RBACMode).To(Equal(hipconsts.RBACModeNone))
+		})
+	})
+
+	Context("namespaced mode", func() {
+		It("should require --rbac-namespaces", func() {
+			opts.RBACMode = hipconsts.RBACModeNamespaced
+			err := validateRBACFlags(opts)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("requires --rbac-namespaces"))
+		})
+
+		It("should accept target namespaces", func() {
+			opts.RBACMode = hipconsts.RBACModeNamespaced
+			opts.RBACNamespaces = []string{"team-a", "team-b"}
+			Expect(validateRBACFlags(opts)).To(Succeed())
+		})
+
+		It("should allow binding a custom service account", func() {
+			opts.RBACMode = hipconsts.RBACModeNamespaced
+			opts.RBACNamespaces = []string{"team-a"}
+			opts.ServiceAccount = "deployer"
+			Expect(validateRBACFlags(opts)).To(Succeed())
+			Expect(opts.RBACMode).To(Equal(hipconsts.RBACModeNamespaced))
+		})
+	})
+
+	Context("none mode", func() {
+		It("should require --service-account", func() {
+			opts.RBACMode = hipconsts.RBACModeNone
+			err := validateRBACFlags(opts)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("requires --service-account"))
+		})
+
+		It("should be the default with --passthrough-credentials", func() {
+			opts.PassthroughCredentials = true
+			Expect(validateRBACFlags(opts)).To(Succeed())
+			Expect(opts.RBACMode).To(Equal(hipconsts.RBACModeNone))
+		})
+	})
+
+	Context("invalid combinations", func() {
+		It("should reject unknown modes", func() {
+			opts.RBACMode = "admin"
+			err := validateRBACFlags(opts)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("invalid --rbac-mode"))
+		})
+
+		It("should reject --rbac-namespaces outside namespaced mode", func() {
+			opts.RBACMode = hipconsts.RBACModeCluster
+			opts.RBACNamespaces = []string{"team-a"}
+			err := validateRBACFlags(opts)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("--rbac-namespaces can only be used"))
+		})
+	})
+})
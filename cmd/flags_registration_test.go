@@ -30,6 +30,8 @@ var _ = Describe("Flag Registration", func() {
 				"host-network", "tolerations", "node-selector",
 				"image-pull-secret", "pull-policy", "image",
 				"volume", "service-account", "dry-run",
+				"rbac-mode", "rbac-cluster-role", "rbac-namespaces",
+				"preflight", "passthrough-credentials",
 			}
 			for _, name := range flags {
 				Expect(execCmd.Flags().Lookup(name)).NotTo(BeNil(), "flag --%s should be registered", name)
@@ -38,8 +40,8 @@ var _ = Describe("Flag Registration", func() {
 
 		It("should register all runtime flags", func() {
 			flags := []string{
-				"env", "subst-env", "copy-repo", "update-repo",
-				"copy", "copy-attempts", "update-repo-attempts",
+				"env", "subst-env", "subst-env-exclude", "env-file", "env-from", "secret-env", "secret-subst-env", "copy-repo", "update-repo",
+				"copy", "copy-exclude", "copy-attempts", "update-repo-attempts",
 				"copy-from",
 			}
 			for _, name := range flags {
@@ -80,6 +82,15 @@ var _ = Describe("Flag Registration", func() {
 			Expect(opts.HostNetwork).To(BeFalse())
 		})
 
+		It("should have correct default for --rbac-cluster-role", func() {
+			Expect(opts.RBACMode).To(BeEmpty())
+			Expect(opts.RBACClusterRole).To(Equal("cluster-admin"))
+		})
+
+		It("should enable --preflight by default", func() {
+			Expect(opts.Preflight).To(BeTrue())
+		})
+
 		It("should mark --cpu as deprecated", func() {
 			f := execCmd.Flags().Lookup("cpu")
 			Expect(f.Deprecated).NotTo(BeEmpty())
@@ -111,6 +122,107 @@ var _ = Describe("Flag Registration", func() {
 		})
 	})
 
+	Context("exec command", func() {
+		It("should register --attach defaulting to false", func() {
+			execCmd := newExecCmd()
+			Expect(execCmd.Flags().Lookup("attach")).NotTo(BeNil())
+			Expect(execCmd.Flags().Lookup("attach").DefValue).To(Equal("false"))
+		})
+
+		It("should register --stdin without a shorthand", func() {
+			execCmd := newExecCmd()
+			Expect(execCmd.Flags().Lookup("stdin")).NotTo(BeNil())
+			Expect(execCmd.Flags().Lookup("stdin").DefValue).To(Equal("false"))
+			Expect(execCmd.Flags().Lookup("stdin").Shorthand).To(BeEmpty())
+			Expect(execCmd.Flags().Lookup("image").Shorthand).To(Equal("i"))
+		})
+
+		It("should register fan-out flags", func() {
+			execCmd := newExecCmd()
+			Expect(execCmd.Flags().Lookup("contexts")).NotTo(BeNil())
+			Expect(execCmd.Flags().Lookup("all-contexts").DefValue).To(Equal("false"))
+			Expect(execCmd.Flags().Lookup("parallelism").DefValue).To(Equal("4"))
+		})
+
+		It("should register job flags", func() {
+			execCmd := newExecCmd()
+			Expect(execCmd.Flags().Lookup("as-job").DefValue).To(Equal("false"))
+			Expect(execCmd.Flags().Lookup("job-ttl-seconds").DefValue).To(Equal("600"))
+			Expect(execCmd.Flags().Lookup("job-backoff-limit").DefValue).To(Equal("0"))
+		})
+
+		It("should register --detach", func() {
+			execCmd := newExecCmd()
+			Expect(execCmd.Flags().Lookup("detach").DefValue).To(Equal("false"))
+		})
+
+		It("should register --report for exec and daemon exec", func() {
+			Expect(newExecCmd().Flags().Lookup("report").DefValue).To(BeEmpty())
+			Expect(newDaemonExecCmd().Flags().Lookup("report").DefValue).To(BeEmpty())
+		})
+
+		It("should register --script and --interpreter", func() {
+			execCmd := newExecCmd()
+			Expect(execCmd.Flags().Lookup("script")).NotTo(BeNil())
+			Expect(execCmd.Flags().Lookup("interpreter").DefValue).To(Equal("sh"))
+		})
+
+		It("should register the pod script waits but not --delete-timeout", func() {
+			execCmd := newExecCmd()
+			for _, name := range []string{"terminate-timeout", "copy-from-wait", "read-wait"} {
+				Expect(execCmd.Flags().Lookup(name)).NotTo(BeNil(), "flag --%s should be registered", name)
+			}
+			Expect(execCmd.Flags().Lookup("delete-timeout")).To(BeNil())
+		})
+	})
+
+	Context("operation commands", func() {
+		It("should register --follow for logs", func() {
+			flag := newLogsCmd().Flags().Lookup("follow")
+			Expect(flag).NotTo(BeNil())
+			Expect(flag.Shorthand).To(Equal("f"))
+		})
+
+		It("should register --delete for wait and result", func() {
+			Expect(newWaitCmd().Flags().Lookup("delete").DefValue).To(Equal("false"))
+			Expect(newResultCmd().Flags().Lookup("delete").DefValue).To(Equal("false"))
+		})
+	})
+
+	Context("shell command", func() {
+		var shellCmd *cobra.Command
+
+		BeforeEach(func() {
+			shellCmd = newShellCmd()
+		})
+
+		It("should register pod creation and runtime flags", func() {
+			flags := []string{
+				"image", "volume", "copy", "copy-repo", "update-repo",
+				"service-account", "rbac-mode", "preflight", "dry-run", "copy-from",
+			}
+			for _, name := range flags {
+				Expect(shellCmd.Flags().Lookup(name)).NotTo(BeNil(), "flag --%s should be registered", name)
+			}
+		})
+
+		It("should default --shell to sh", func() {
+			Expect(shellCmd.Flags().Lookup("shell").DefValue).To(Equal("sh"))
+		})
+
+		It("should not register exec-only flags", func() {
+			Expect(shellCmd.Flags().Lookup("attach")).To(BeNil())
+			Expect(shellCmd.Flags().Lookup("stdin")).To(BeNil())
+			for _, name := range []string{"terminate-timeout", "copy-from-wait", "read-wait", "delete-timeout"} {
+				Expect(shellCmd.Flags().Lookup(name)).To(BeNil(), "flag --%s should not be registered", name)
+			}
+		})
+
+		It("should reject positional arguments", func() {
+			Expect(shellCmd.Args(shellCmd, []string{"helm"})).To(HaveOccurred())
+		})
+	})
+
 	Context("daemon exec runtime flags", func() {
 		It("should default --copy-repo to false for daemon exec", func() {
 			opts := &cmdoptions.ExecOptions{}
@@ -266,6 +378,13 @@ var _ = Describe("Flag Registration", func() {
 		It("should register daemon-specific flags", func() {
 			Expect(startCmd.Flags().Lookup("name")).NotTo(BeNil())
 			Expect(startCmd.Flags().Lookup("force")).NotTo(BeNil())
+			Expect(startCmd.Flags().Lookup("delete-timeout")).NotTo(BeNil())
+		})
+
+		It("should not register the pod script waits", func() {
+			for _, name := range []string{"terminate-timeout", "copy-from-wait", "read-wait"} {
+				Expect(startCmd.Flags().Lookup(name)).To(BeNil(), "flag --%s should not be registered", name)
+			}
 		})
 
 		It("should inherit pod creation flags", func() {
@@ -286,8 +405,8 @@ var _ = Describe("Flag Registration", func() {
 
 		It("should inherit runtime flags", func() {
 			flags := []string{
-				"env", "subst-env", "copy-repo", "update-repo",
-				"copy", "copy-attempts", "update-repo-attempts",
+				"env", "subst-env", "subst-env-exclude", "env-file", "env-from", "secret-env", "secret-subst-env", "copy-repo", "update-repo",
+				"copy", "copy-exclude", "copy-attempts", "update-repo-attempts",
 				"copy-from",
 			}
 			for _, name := range flags {
@@ -325,6 +444,7 @@ var _ = Describe("Flag Registration", func() {
 			Expect(startCmd.Flags().Lookup("update-all-repos")).To(BeNil())
 			Expect(startCmd.Flags().Lookup("clean")).To(BeNil())
 			Expect(startCmd.Flags().Lookup("shell")).To(BeNil())
+			Expect(startCmd.Flags().Lookup("stdin")).To(BeNil())
 		})
 	})
 
@@ -339,12 +459,15 @@ var _ = Describe("Flag Registration", func() {
 			Expect(execCmd.Flags().Lookup("name")).NotTo(BeNil())
 			Expect(execCmd.Flags().Lookup("update-all-repos")).NotTo(BeNil())
 			Expect(execCmd.Flags().Lookup("clean")).NotTo(BeNil())
+			Expect(execCmd.Flags().Lookup("stdin")).NotTo(BeNil())
+			Expect(execCmd.Flags().Lookup("script")).NotTo(BeNil())
+			Expect(execCmd.Flags().Lookup("interpreter")).NotTo(BeNil())
 		})
 
 		It("should inherit runtime flags", func() {
 			flags := []string{
-				"env", "subst-env", "copy-repo", "update-repo",
-				"copy", "copy-attempts", "update-repo-attempts",
+				"env", "subst-env", "subst-env-exclude", "env-file", "env-from", "secret-env", "secret-subst-env", "copy-repo", "update-repo",
+				"copy", "copy-exclude", "copy-attempts", "update-repo-attempts",
 				"copy-from",
 			}
 			for _, name := range flags {
@@ -408,9 +531,10 @@ var _ = Describe("Flag Registration", func() {
 	})
 
 	Context("daemon stop command flags", func() {
-		It("should register only --name", func() {
+		It("should register only --name and --delete-timeout", func() {
 			stopCmd := newDaemonStopCmd()
 			Expect(stopCmd.Flags().Lookup("name")).NotTo(BeNil())
+			Expect(stopCmd.Flags().Lookup("delete-timeout")).NotTo(BeNil())
 			Expect(stopCmd.Flags().Lookup("force")).To(BeNil())
 		})
 	})
@@ -452,6 +576,25 @@ var _ = Describe("Flag Registration", func() {
 		})
 	})
 
+	Context("doctor command flags", func() {
+		var doctorCmd *cobra.Command
+
+		BeforeEach(func() {
+			doctorCmd = newDoctorCmd()
+		})
+
+		It("should register RBAC flags", func() {
+			for _, name := range []string{"service-account", "rbac-mode", "rbac-cluster-role", "rbac-namespaces", "create-pdb", "passthrough-credentials", "as-job", "daemon"} {
+				Expect(doctorCmd.Flags().Lookup(name)).NotTo(BeNil(), "flag --%s should be registered", name)
+			}
+		})
+
+		It("should not register pod runtime flags", func() {
+			Expect(doctorCmd.Flags().Lookup("image")).To(BeNil())
+			Expect(doctorCmd.Flags().Lookup("preflight")).To(BeNil())
+		})
+	})
+
 	Context("root command persistent flags", func() {
 		var rootCmd *cobra.Command
 
@@ -464,6 +607,16 @@ var _ = Describe("Flag Registration", func() {
 			Expect(rootCmd.PersistentFlags().Lookup("timeout")).NotTo(BeNil())
 		})
 
+		It("should register --profile as a persistent flag", func() {
+			Expect(rootCmd.PersistentFlags().Lookup("profile")).NotTo(BeNil())
+			Expect(rootCmd.PersistentFlags().Lookup("profile").DefValue).To(Equal(""))
+		})
+
+		It("should register --plugin-namespace as a persistent flag", func() {
+			Expect(rootCmd.PersistentFlags().Lookup("plugin-namespace")).NotTo(BeNil())
+			Expect(rootCmd.PersistentFlags().Lookup("plugin-namespace").DefValue).To(Equal(""))
+		})
+
 		It("should default --verbose-logs to false", func() {
 			Expect(rootCmd.PersistentFlags().Lookup("verbose-logs").DefValue).To(Equal("false"))
 		})
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/cobra"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+)
+
+var _ = Describe("phase timeout flags", func() {
+	var (
+		execCmd *cobra.Command
+		opts    *cmdoptions.ExecOptions
+	)
+
+	BeforeEach(func() {
+		opts = &cmdoptions.ExecOptions{}
+		execCmd = &cobra.Command{}
+		addExecOptionsFlags(execCmd, opts)
+		addPodScriptWaitFlags(execCmd, opts)
+	})
+
+	It("should default to the built-in timeouts", func() {
+		Expect(opts.StartupTimeout).To(Equal(hipconsts.DefaultStartupTimeout))
+		Expect(opts.CopyTimeout).To(Equal(hipconsts.DefaultCopyTimeout))
+		Expect(opts.CompletionTimeout).To(Equal(hipconsts.DefaultCompletionTimeout))
+		Expect(opts.GracePeriod).To(Equal(hipconsts.DefaultGracePeriod))
+		Expect(opts.TerminateTimeout).To(Equal(hipconsts.DefaultTerminateTimeout))
+		Expect(opts.CopyFromWait).To(Equal(hipconsts.DefaultCopyFromWait))
+		Expect(opts.ReadWait).To(Equal(hipconsts.DefaultReadWait))
+		Expect(validatePhaseTimeouts(execCmd, opts)).To(Succeed())
+	})
+
+	It("should parse durations", func() {
+		Expect(execCmd.Flags().Set("startup-timeout", "15m")).To(Succeed())
+		Expect(execCmd.Flags().Set("copy-from-wait", "30s")).To(Succeed())
+		Expect(opts.StartupTimeout).To(Equal(15 * time.Minute))
+		Expect(opts.CopyFromWait).To(Equal(30 * time.Second))
+		Expect(execCmd.Flags().Set("read-wait", "2m")).To(Succeed())
+		Expect(opts.ReadWait).To(Equal(2 * time.Minute))
+	})
+
+	It("should reject a timeout that is not positive", func() {
+		Expect(execCmd.Flags().Set("copy-timeout", "0s")).To(Succeed())
+		Expect(validatePhaseTimeouts(execCmd, opts)).To(MatchError("copy-timeout value must be positive"))
+	})
+
+	It("should reject a terminate timeout that is not shorter than the grace period", func() {
+		Expect(execCmd.Flags().Set("grace-period", "2m")).To(Succeed())
+		Expect(validatePhaseTimeouts(execCmd, opts)).To(MatchError(ContainSubstring("terminate-timeout (3m0s) must be shorter than grace-period (2m0s)")))
+
+		Expect(execCmd.Flags().Set("terminate-timeout", "90s")).To(Succeed())
+		Expect(validatePhaseTimeouts(execCmd, opts)).To(Succeed())
+	})
+
+	It("should not validate the pod script waits of commands without them", func() {
+		shellCmd := &cobra.Command{}
+		shellOpts := &cmdoptions.ExecOptions{}
+		addExecOptionsFlags(shellCmd, shellOpts)
+		Expect(shellCmd.Flags().Set("grace-period", "1m")).To(Succeed())
+		Expect(validatePhaseTimeouts(shellCmd, shellOpts)).To(Succeed())
+	})
+
+	It("should register --copy-timeout for daemon exec and --delete-timeout for daemon start and stop", func() {
+		Expect(newDaemonExecCmd().Flags().Lookup("copy-timeout")).NotTo(BeNil())
+		Expect(newDaemonStartCmd().Flags().Lookup("delete-timeout")).NotTo(BeNil())
+		Expect(newDaemonStopCmd().Flags().Lookup("delete-timeout")).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/noksa/helm-in-pod/internal"
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hiperrors"
+	"github.com/noksa/helm-in-pod/internal/hippod"
+	"github.com/noksa/helm-in-pod/internal/logz"
+)
+
+// validateDetach rejects options that need the client to stay connected to the command.
+func validateDetach(opts cmdoptions.ExecOptions) error {
+	if !opts.Detach {
+		return nil
+	}
+	switch {
+	case opts.Attach || opts.Stdin:
+		return fmt.Errorf("--detach can't be used with --attach or --stdin")
+	case len(opts.CopyFrom) > 0:
+		return fmt.Errorf("--detach can't be used with --copy-from")
+	case len(opts.Contexts) > 0 || opts.AllContexts:
+		return fmt.Errorf("--detach can't be used with --contexts or --all-contexts")
+	case opts.JobBackoffLimit > 0:
+		return fmt.Errorf("--detach can't be used with --job-backoff-limit, retried commands need a connected client")
+	case opts.Report != "":
+		return fmt.Errorf("--detach can't be used with --report, the command's result is not known when exec returns")
+	}
+	return nil
+}
+
+// printDetachedOperation prints the operation ID to stdout, so scripts can capture it,
+// and logs how to follow the operation.
+func printDetachedOperation(operationID, podName string) {
+	logz.Host().Info().Msgf("Command is running in %v pod as operation %v", color.MagentaString(podName), color.CyanString(operationID))
+	logz.Host().Info().Msgf("Follow it with `helm in-pod logs %[1]v --follow`, `helm in-pod wait %[1]v` or `helm in-pod result %[1]v`", operationID)
+	fmt.Println(operationID)
+}
+
+func newLogsCmd() *cobra.Command {
+	var follow bool
+	logsCmd := &cobra.Command{
+		Use:   "logs <operation-id>",
+		Short: "Print the output of a command started with exec --detach",
+		Long: `Print the output of a command started with exec --detach.
+
+With --follow the output is streamed until the command finishes and the exit code
+of the command is returned.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := internal.Pod().StreamOperationLogs(cmd.Context(), args[0], follow); err != nil || !follow {
+				return err
+			}
+			result, err := internal.Pod().WaitForOperation(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			logOperationResult(result)
+			return result.Err()
+		},
+	}
+	logsCmd.Flags().BoolVarP(&follow, "follow", "f", false, "Stream the output until the command finishes and exit with its exit code")
+	return logsCmd
+}
+
+func newWaitCmd() *cobra.Command {
+	var deletePods bool
+	waitCmd := &cobra.Command{
+		Use:   "wait <operation-id>",
+		Short: "Wait for a command started with exec --detach and exit with its exit code",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := internal.Pod().WaitForOperation(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			logOperationResult(result)
+			return finishOperation(result, deletePods)
+		},
+	}
+	addDeleteOperationFlag(waitCmd, &deletePods)
+	return waitCmd
+}
+
+func newResultCmd() *cobra.Command {
+	var deletePods bool
+	resultCmd := &cobra.Command{
+		Use:   "result <operation-id>",
+		Short: "Show the result of a command started with exec --detach and exit with its exit code",
+		Long: `Show the result of a command started with exec --detach and exit with its exit code.
+
+Fails without waiting if the command is still running.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := internal.Pod().GetOperationResult(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			renderOperationResult(result)
+			return finishOperation(result, deletePods)
+		},
+	}
+	addDeleteOperationFlag(resultCmd, &deletePods)
+	return resultCmd
+}
+
+func addDeleteOperationFlag(cmd *cobra.Command, deletePods *bool) {
+	cmd.Flags().BoolVar(deletePods, "delete", false, "Delete the operation's pod once the command has finished")
+}
+
+// finishOperation deletes the pods of a finished operation if requested and
+// returns the command's result as an error.
+func finishOperation(result *hippod.OperationResult, deletePods bool) error {
+	if !deletePods || !result.Finished() {
+		return result.Err()
+	}
+	return errors.Join(result.Err(), internal.Pod().DeleteOperation(result.OperationID))
+}
+
+func logOperationResult(result *hippod.OperationResult) {
+	if result.ExitCode == hiperrors.ExitCodeUnknown {
+		logz.Host().Info().Msgf("Operation %v finished in %v phase", color.CyanString(result.OperationID), colorOperationPhase(result.Phase))
+		return
+	}
+	logz.Host().Info().Msgf("Operation %v finished with exit code %v", color.CyanString(result.OperationID), result.ExitCode)
+}
+
+// colorOperationPhase colors a phase like colorPhase, except that a succeeded
+// command is green. A succeeded daemon pod means the daemon is gone.
+func colorOperationPhase(phase corev1.PodPhase) string {
+	if phase == corev1.PodSucceeded {
+		return color.GreenString(string(phase))
+	}
+	return colorPhase(string(phase))
+}
+
+func renderOperationResult(result *hippod.OperationResult) {
+	exitCode := "-"
+	if result.ExitCode != hiperrors.ExitCodeUnknown {
+		exitCode = strconv.Itoa(int(result.ExitCode))
+	}
+	rows := [][]string{
+		{"Operation", color.CyanString(result.OperationID)},
+		{"Pod", result.PodName},
+		{"Phase", colorOperationPhase(result.Phase)},
+		{"Exit Code", exitCode},
+	}
+	if result.Reason != "" {
+		rows = append(rows, []string{"Reason", result.Reason})
+	}
+	table := cyberTable(os.Stdout)
+	table.Header([]string{"PROPERTY", "VALUE"})
+	_ = table.Bulk(rows)
+	_ = table.Render()
+}
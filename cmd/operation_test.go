@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+)
+
+var _ = Describe("validateDetach", func() {
+	It("should accept a plain detached exec", func() {
+		Expect(validateDetach(cmdoptions.ExecOptions{Detach: true, AsJob: true})).To(Succeed())
+	})
+
+	It("should ignore options when not detached", func() {
+		Expect(validateDetach(cmdoptions.ExecOptions{Attach: true, CopyFrom: []string{"/tmp/a:./a"}})).To(Succeed())
+	})
+
+	DescribeTable("should reject options that need a connected client",
+		func(opts cmdoptions.ExecOptions, flag string) {
+			opts.Detach = true
+			Expect(validateDetach(opts)).To(MatchError(ContainSubstring(flag)))
+		},
+		Entry("attach", cmdoptions.ExecOptions{Attach: true}, "--attach"),
+		Entry("stdin", cmdoptions.ExecOptions{Stdin: true}, "--stdin"),
+		Entry("copy-from", cmdoptions.ExecOptions{CopyFrom: []string{"/tmp/a:./a"}}, "--copy-from"),
+		Entry("contexts", cmdoptions.ExecOptions{Contexts: []string{"eu"}}, "--contexts"),
+		Entry("job retries", cmdoptions.ExecOptions{AsJob: true, JobBackoffLimit: 1}, "--job-backoff-limit"),
+		Entry("report", cmdoptions.ExecOptions{Report: "report.json"}, "--report"),
+	)
+})
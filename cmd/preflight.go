@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fatih/color"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipaccess"
+	"github.com/noksa/helm-in-pod/internal/hipns"
+	"github.com/noksa/helm-in-pod/internal/logz"
+)
+
+// runPreflight fails before any object is created if the current user lacks
+// a permission the flow needs. Missing permissions are printed to w as a table.
+func runPreflight(nsm *hipns.Manager, opts cmdoptions.ExecOptions, w io.Writer) error {
+	if !opts.Preflight {
+		return nil
+	}
+	logz.Host().Debug().Msg("Checking required permissions")
+	results, err := nsm.CheckAccess(opts)
+	if err != nil {
+		return err
+	}
+	missing := hipaccess.Missing(results)
+	if len(missing) == 0 {
+		logz.Host().Debug().Msgf("All %v required permissions are granted", len(results))
+		return nil
+	}
+	logz.Host().Error().Msg("Missing permissions:")
+	renderAccessTable(w, missing)
+	return fmt.Errorf("missing %d of %d required permissions, nothing was created. Run `helm in-pod doctor` to see all checks or pass --preflight=false to skip them", len(missing), len(results))
+}
+
+func renderAccessTable(w io.Writer, results []hipaccess.Result) {
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		status := color.GreenString("allowed")
+		if !r.Allowed {
+			status = color.RedString("denied")
+		}
+		namespace := r.Namespace
+		if namespace == "" {
+			namespace = "(cluster)"
+		}
+		resource := r.ResourceString()
+		if r.Name != "" {
+			resource = fmt.Sprintf("%s/%s", resource, r.Name)
+		}
+		rows = append(rows, []string{r.Verb, resource, namespace, status})
+	}
+	table := cyberTable(w)
+	table.Header([]string{"VERB", "RESOURCE", "NAMESPACE", "STATUS"})
+	_ = table.Bulk(rows)
+	_ = table.Render()
+}
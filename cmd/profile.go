@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/noksa/helm-in-pod/internal"
+	"github.com/noksa/helm-in-pod/internal/hipconfig"
+	"github.com/noksa/helm-in-pod/internal/logz"
+)
+
+// profileIgnoredFlags cannot be set from a profile. --timeout is consumed by
+// internal.RunCommand before the command tree is executed.
+var profileIgnoredFlags = map[string]bool{
+	"profile": true,
+	"timeout": true,
+	"help":    true,
+}
+
+// loadProfile reads the configuration file and resolves the profile to use.
+// An explicit --profile wins; otherwise a profile mapped to the current kube context is used.
+func loadProfile(name string) (string, hipconfig.Profile, error) {
+	path, err := hipconfig.DefaultPath()
+	if err != nil {
+		return "", nil, err
+	}
+	cfg, err := hipconfig.Load(path)
+	if err != nil {
+		return "", nil, err
+	}
+	kubeContext := ""
+	if name == "" && len(cfg.Contexts) > 0 {
+		kubeContext, err = internal.CurrentKubeContext()
+		if err != nil {
+			logz.Host().Debug().Msgf("Could not determine current kube context: %v", err)
+		}
+	}
+	return cfg.Resolve(name, kubeContext)
+}
+
+// applyProfile sets every flag of cmd that is present in the profile and was not
+// passed explicitly on the command line. Keys for flags the command does not
+// have are skipped, so one profile can be shared by exec and daemon commands.
+func applyProfile(cmd *cobra.Command, profile hipconfig.Profile) error {
+	keys := make([]string, 0, len(profile))
+	for k := range profile {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if profileIgnoredFlags[key] {
+			return fmt.Errorf("--%s can't be set from a profile", key)
+		}
+		flag := cmd.Flags().Lookup(key)
+		if flag == nil {
+			logz.Host().Debug().Msgf("Profile key %v is not a flag of %v command, skipping", color.CyanString(key), cmd.Name())
+			continue
+		}
+		if flag.Changed {
+			continue
+		}
+		values, err := hipconfig.FlagValues(profile[key])
+		if err != nil {
+			return fmt.Errorf("invalid profile value for %q: %w", key, err)
+		}
+		for _, v := range values {
+			if err := cmd.Flags().Set(key, v); err != nil {
+				return fmt.Errorf("invalid profile value for %q: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
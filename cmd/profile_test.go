@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/cobra"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipconfig"
+	"github.com/noksa/helm-in-pod/internal/hipredact"
+	"github.com/noksa/helm-in-pod/internal/logz"
+)
+
+var _ = Describe("applyProfile", func() {
+	var (
+		execCmd *cobra.Command
+		opts    *cmdoptions.ExecOptions
+	)
+
+	BeforeEach(func() {
+		opts = &cmdoptions.ExecOptions{}
+		execCmd = &cobra.Command{}
+		addExecOptionsFlags(execCmd, opts)
+	})
+
+	It("should populate pod creation and runtime flags from the profile", func() {
+		err := applyProfile(execCmd, hipconfig.Profile{
+			"image":           "profile-image:v1",
+			"tolerations":     []any{"::Exists", "key=::Exists"},
+			"node-selector":   map[string]any{"disktype": "ssd"},
+			"create-pdb":      false,
+			"copy-attempts":   float64(7),
+			"service-account": "deployer",
+			"volume":          []any{"pvc:data:/data"},
+			"subst-env":       []any{"HELM_DRIVER"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Image).To(Equal("profile-image:v1"))
+		Expect(opts.Tolerations).To(Equal([]string{"::Exists", "key=::Exists"}))
+		Expect(opts.NodeSelector).To(HaveKeyWithValue("disktype", "ssd"))
+		Expect(opts.CreatePDB).To(BeFalse())
+		Expect(opts.CopyAttempts).To(Equal(7))
+		Expect(opts.ServiceAccount).To(Equal("deployer"))
+		Expect(opts.Volumes).To(Equal([]string{"pvc:data:/data"}))
+		Expect(opts.SubstEnv).To(Equal([]string{"HELM_DRIVER"}))
+	})
+
+	It("should let explicit flags override profile values", func() {
+		Expect(execCmd.Flags().Set("image", "cli-image:v2")).To(Succeed())
+		Expect(execCmd.Flags().Set("tolerations", "a=:NoSchedule:Exists")).To(Succeed())
+		err := applyProfile(execCmd, hipconfig.Profile{
+			"image":       "profile-image:v1",
+			"tolerations": []any{"::Exists"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Image).To(Equal("cli-image:v2"))
+		Expect(opts.Tolerations).To(Equal([]string{"a=:NoSchedule:Exists"}))
+	})
+
+	It("should feed resource flags into validateResourceFlags", func() {
+		Expect(applyProfile(execCmd, hipconfig.Profile{"cpu-request": "250m"})).To(Succeed())
+		Expect(validateResourceFlags(execCmd, opts)).To(Succeed())
+		Expect(opts.CpuRequest).To(Equal("250m"))
+		Expect(opts.CpuLimit).To(BeEmpty())
+		Expect(opts.MemoryRequest).To(Equal("500Mi"))
+	})
+
+	It("should skip keys that are not flags of the command", func() {
+		daemonExec := newDaemonExecCmd()
+		err := applyProfile(daemonExec, hipconfig.Profile{
+			"image": "profile-image:v1",
+			"env":   map[string]any{"FOO": "bar"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(daemonExec.Flags().Lookup("env").Value.String()).To(Equal("[FOO=bar]"))
+	})
+
+	It("should reject flags that can't come from a profile", func() {
+		err := applyProfile(execCmd, hipconfig.Profile{"timeout": "1h"})
+		Expect(err).To(MatchError(ContainSubstring("--timeout can't be set from a profile")))
+	})
+
+	It("should report invalid values", func() {
+		err := applyProfile(execCmd, hipconfig.Profile{"copy-attempts": "many"})
+		Expect(err).To(MatchError(ContainSubstring(`invalid profile value for "copy-attempts"`)))
+	})
+})
+
+var _ = Describe("root command profile", func() {
+	var configPath string
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		configPath = filepath.Join(dir, "config.yaml")
+		GinkgoT().Setenv(hipconfig.EnvConfigPath, configPath)
+		// Stops the command at the Kubernetes client, after the flags were read
+		GinkgoT().Setenv("KUBECONFIG", filepath.Join(dir, "missing-kubeconfig"))
+		GinkgoT().Setenv("KUBERNETES_SERVICE_HOST", "")
+		DeferCleanup(func() {
+			hipredact.Reset()
+			Expect(logz.Configure(GinkgoWriter, logz.FormatConsole, true)).To(Succeed())
+		})
+	})
+
+	run := func(profile string) error {
+		Expect(os.WriteFile(configPath, []byte(profile), 0o600)).To(Succeed())
+		rootCmd := newRootCmd()
+		rootCmd.SetArgs([]string{"--profile", "ci", "purge"})
+		rootCmd.SetOut(GinkgoWriter)
+		rootCmd.SetErr(GinkgoWriter)
+		return rootCmd.Execute()
+	}
+
+	It("should mask the redact patterns of the profile", func() {
+		err := run("profiles:\n  ci:\n    redact: ['internal-[a-z]+']\n")
+		Expect(err).To(MatchError(ContainSubstring("could not initialize Kubernetes client")))
+		Expect(hipredact.String("host internal-secret")).To(Equal("host ***"))
+	})
+
+	It("should apply the log format of the profile", func() {
+		err := run("profiles:\n  ci:\n    log-format: xml\n")
+		Expect(err).To(MatchError(ContainSubstring("xml")))
+	})
+})
@@ -15,10 +15,10 @@ func newPurgeCmd() *cobra.Command {
 		Short: "Remove leftover pods and cluster resources created by the plugin",
 	}
 	opts := cmdoptions.PurgeOptions{}
-	purgeCmd.Flags().BoolVar(&opts.All, "all", false, "Remove all pods in the helm-in-pod namespace (regardless of host), associated PDBs, and the ClusterRoleBinding")
+	purgeCmd.Flags().BoolVar(&opts.All, "all", false, "Remove all pods in the plugin namespace (regardless of host), associated PDBs and Secrets, and the ClusterRoleBindings/RoleBindings created by any RBAC mode")
 	purgeCmd.RunE = func(cmd *cobra.Command, args []string) error {
 		return errors.Join(
-			internal.Namespace().DeleteClusterRoleBinding(),
+			internal.Namespace().DeleteRBAC(),
 			internal.Pod().DeleteHelmPods(cmdoptions.ExecOptions{}, opts),
 		)
 	}
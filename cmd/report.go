@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hippod"
+	"github.com/noksa/helm-in-pod/internal/logz"
+)
+
+// writeReport writes the report of pm's run to opts.Report, if set. runErr is
+// the result of the run; it is returned unless the report can't be written.
+func writeReport(pm *hippod.Manager, opts cmdoptions.ExecOptions, runErr error) error {
+	if opts.Report == "" {
+		return runErr
+	}
+	err := pm.Report().Write(opts.Report, runErr)
+	if err == nil {
+		logz.Host().Debug().Msgf("Report has been written to %v", opts.Report)
+		return runErr
+	}
+	if runErr != nil {
+		logz.Host().Warn().Msgf("Failed to write report: %v", err)
+		return runErr
+	}
+	return fmt.Errorf("failed to write report: %w", err)
+}
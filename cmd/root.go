@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
@@ -11,6 +13,9 @@ import (
 
 	"github.com/noksa/helm-in-pod/internal"
 	"github.com/noksa/helm-in-pod/internal/helpers"
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+	"github.com/noksa/helm-in-pod/internal/hipredact"
+	"github.com/noksa/helm-in-pod/internal/hiptrace"
 	"github.com/noksa/helm-in-pod/internal/logz"
 )
 
@@ -21,23 +26,71 @@ func newRootCmd() *cobra.Command {
 	}
 	rootCmd.AddCommand(
 		newExecCmd(),
+		newShellCmd(),
+		newLogsCmd(),
+		newWaitCmd(),
+		newResultCmd(),
 		newPurgeCmd(),
+		newDoctorCmd(),
 		newDaemonCmd())
 
 	startTime := time.Now()
 	var debug bool
+	var profile string
+	var pluginNamespace string
+	var logFormat string
+	var noColor bool
+	var logCommandOutput bool
+	var otelEndpoint string
+	var otelFile string
+	var redact []string
 	rootCmd.PersistentFlags().BoolVar(&debug, "verbose-logs", false, "Enable debug logs")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Named profile from the config file ($HELM_IN_POD_CONFIG or ~/.config/helm-in-pod/config.yaml). Explicit flags override profile values. If not set, a profile mapped to the current kube context is used")
+	rootCmd.PersistentFlags().StringVar(&pluginNamespace, "plugin-namespace", "", fmt.Sprintf("Namespace for plugin pods, service account and PDBs (default: $%s or %s)", hipconsts.EnvNamespace, hipconsts.HelmInPodNamespace))
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logz.FormatConsole, fmt.Sprintf("Format of the plugin logs: %s. json and logfmt records carry source, operation, pod and phase fields and have no colors", strings.Join(logz.Formats(), ", ")))
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colors in logs and tables")
+	rootCmd.PersistentFlags().BoolVar(&logCommandOutput, "log-command-output", false, "Write every line of the command's output as a log record with source=pod and a stream field instead of writing it raw to stdout and stderr")
+	rootCmd.PersistentFlags().StringVar(&otelEndpoint, "otel-endpoint", "", "OTLP/HTTP endpoint to send traces to, e.g. http://localhost:4318. The standard OTEL_EXPORTER_OTLP_* variables are used when not set")
+	rootCmd.PersistentFlags().StringVar(&otelFile, "otel-file", "", "Write traces as JSON lines to this file instead of sending them to an OTLP endpoint")
+	rootCmd.PersistentFlags().StringArrayVar(&redact, "redact", []string{}, "Regular expression whose matches are masked in logs, command output and --dry-run output, in addition to passwords in URLs, tokens and values of sensitive variables. If it has capture groups, only the groups are masked. Repeatable")
 	rootCmd.PersistentFlags().Duration("timeout", time.Second*0, "Gracefully terminate the command after this duration (default: 2h at runtime). For exec and daemon start, 10 extra minutes are added internally for pod operations")
 	_ = viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
 	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		// The profile is applied before any flag is read, e.g. --redact and --log-format
+		profileName := ""
+		if !helpers.IsCompletionCmd(cmd) {
+			name, profileValues, err := loadProfile(profile)
+			if err != nil {
+				return err
+			}
+			if name != "" {
+				if err := applyProfile(cmd, profileValues); err != nil {
+					return err
+				}
+			}
+			profileName = name
+		}
+		if err := logz.Configure(os.Stderr, logFormat, noColor); err != nil {
+			return err
+		}
+		if err := hipredact.AddPatterns(redact...); err != nil {
+			return err
+		}
+		if !helpers.IsCompletionCmd(cmd) {
+			logz.Host().Info().Msgf("Running %v command", color.CyanString(cmd.Name()))
+			if profileName != "" {
+				logz.Host().Info().Msgf("Using %v profile", color.CyanString(profileName))
+			}
+		}
 		if debug {
 			logz.Host().Info().Msg("Setting log level to debug")
 			zerolog.SetGlobalLevel(zerolog.DebugLevel)
 		}
-		if !helpers.IsCompletionCmd(cmd) {
-			logz.Host().Info().Msgf("Running %v command", color.CyanString(cmd.Name()))
+		if err := hiptrace.Init(cmd.Context(), otelEndpoint, otelFile); err != nil {
+			return err
 		}
-		if err := internal.InitManagers(); err != nil {
+		cmd.SetContext(hiptrace.StartRoot(cmd.Context(), cmd.CommandPath()))
+		if err := internal.InitManagers(cmd.Context(), getPluginNamespace(pluginNamespace), logCommandOutput); err != nil {
 			return fmt.Errorf("could not initialize Kubernetes client: %w", err)
 		}
 		return nil
@@ -54,5 +107,8 @@ func newRootCmd() *cobra.Command {
 func ExecuteRoot() error {
 	rootCmd := newRootCmd()
 	rootCmd.SilenceUsage = true
-	return internal.RunCommand(rootCmd)
+	rootCmd.SetErr(hipredact.NewRecordWriter(os.Stderr))
+	err := internal.RunCommand(rootCmd)
+	hiptrace.Finish(err)
+	return err
 }
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/helpers"
+)
+
+const scriptHeredocMarker = "HIP_SCRIPT_EOF"
+
+// addScriptFlags registers --script and --interpreter for exec and daemon exec.
+func addScriptFlags(cmd *cobra.Command, opts *cmdoptions.ExecOptions) {
+	cmd.Flags().StringVar(&opts.Script, "script", "", "Run a script file instead of a command, '-' reads the script from stdin. Arguments after -- are passed to the script as $1, $2, ...")
+	cmd.Flags().StringVar(&opts.Interpreter, "interpreter", "sh", "Interpreter for --script: sh or bash")
+}
+
+// commandToRun returns the command for the wrapped script: the joined arguments,
+// or with --script a command that writes the script to a temporary file in the
+// pod and runs it with the arguments.
+func commandToRun(opts cmdoptions.ExecOptions, args []string, stdin io.Reader) (string, error) {
+	if opts.Script == "" {
+		if len(args) == 0 {
+			return "", fmt.Errorf("specify command to run or --script")
+		}
+		return strings.Join(args, " "), nil
+	}
+	if opts.Interpreter != "sh" && opts.Interpreter != "bash" {
+		return "", fmt.Errorf("invalid --interpreter %q, must be sh or bash", opts.Interpreter)
+	}
+	var content []byte
+	var err error
+	if opts.Script == "-" {
+		if opts.Stdin {
+			return "", fmt.Errorf("--script - reads the script from stdin and can't be combined with --stdin")
+		}
+		content, err = io.ReadAll(stdin)
+	} else {
+		var path string
+		path, err = expand(opts.Script)
+		if err == nil {
+			content, err = os.ReadFile(filepath.Clean(path))
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read script: %w", err)
+	}
+	return scriptCommand(string(content), opts.Interpreter, args), nil
+}
+
+// scriptCommand embeds content in a quoted heredoc so it is written to the pod
+// verbatim, then runs it with interpreter. stdin is left untouched for --stdin.
+// The script file is deleted on exit, as it may contain secrets.
+func scriptCommand(content, interpreter string, args []string) string {
+	marker := scriptHeredocMarker
+	for strings.Contains(content, marker) {
+		marker += "_"
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	b := &strings.Builder{}
+	b.WriteString("HIP_SCRIPT=\"$(mktemp)\"\n")
+	b.WriteString("trap 'rm -f \"${HIP_SCRIPT}\"' EXIT\n")
+	fmt.Fprintf(b, "cat > \"${HIP_SCRIPT}\" <<'%s'\n%s%s\n", marker, content, marker)
+	fmt.Fprintf(b, "%s \"${HIP_SCRIPT}\"", interpreter)
+	if len(args) > 0 {
+		b.WriteString(" " + helpers.ShellQuoteAll(args))
+	}
+	return b.String()
+}
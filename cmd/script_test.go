@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+)
+
+var _ = Describe("commandToRun", func() {
+	It("should join the arguments without --script", func() {
+		command, err := commandToRun(cmdoptions.ExecOptions{}, []string{"helm", "list", "-A"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(command).To(Equal("helm list -A"))
+	})
+
+	It("should require a command or --script", func() {
+		_, err := commandToRun(cmdoptions.ExecOptions{}, nil, nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject an unknown interpreter", func() {
+		_, err := commandToRun(cmdoptions.ExecOptions{Script: "-", Interpreter: "python"}, nil, strings.NewReader(""))
+		Expect(err).To(MatchError(ContainSubstring("must be sh or bash")))
+	})
+
+	It("should not read the script from stdin together with --stdin", func() {
+		_, err := commandToRun(cmdoptions.ExecOptions{Script: "-", Interpreter: "sh", Stdin: true}, nil, strings.NewReader(""))
+		Expect(err).To(MatchError(ContainSubstring("--stdin")))
+	})
+
+	It("should fail for a missing script file", func() {
+		_, err := commandToRun(cmdoptions.ExecOptions{Script: "/nonexistent/deploy.sh", Interpreter: "sh"}, nil, nil)
+		Expect(err).To(MatchError(ContainSubstring("failed to read script")))
+	})
+
+	It("should run a script file with its arguments", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "deploy.sh")
+		Expect(os.WriteFile(path, []byte("echo \"$# $1 $2\"\necho 'done'"), 0o600)).To(Succeed())
+		command, err := commandToRun(cmdoptions.ExecOptions{Script: path, Interpreter: "sh"}, []string{"it's", "a b"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		out, err := exec.Command("sh", "-eu", "-c", command).CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+		Expect(string(out)).To(Equal("2 it's a b\ndone\n"))
+	})
+
+	It("should read the script from stdin and keep it verbatim", func() {
+		script := "echo \"$HOME\" > /dev/null\necho '" + scriptHeredocMarker + "'\nexit 3\n"
+		command, err := commandToRun(cmdoptions.ExecOptions{Script: "-", Interpreter: "sh"}, nil, strings.NewReader(script))
+		Expect(err).NotTo(HaveOccurred())
+
+		out, err := exec.Command("sh", "-eu", "-c", command).CombinedOutput()
+		Expect(string(out)).To(Equal(scriptHeredocMarker + "\n"))
+		var exitErr *exec.ExitError
+		Expect(err).To(BeAssignableToTypeOf(exitErr))
+		Expect(err.(*exec.ExitError).ExitCode()).To(Equal(3))
+	})
+
+	It("should delete the script file and keep the exit code", func() {
+		tmpDir := GinkgoT().TempDir()
+		command, err := commandToRun(cmdoptions.ExecOptions{Script: "-", Interpreter: "sh"}, nil, strings.NewReader("exit 4\n"))
+		Expect(err).NotTo(HaveOccurred())
+
+		cmd := exec.Command("sh", "-eu", "-c", command)
+		cmd.Env = append(os.Environ(), "TMPDIR="+tmpDir)
+		err = cmd.Run()
+		var exitErr *exec.ExitError
+		Expect(err).To(BeAssignableToTypeOf(exitErr))
+		Expect(err.(*exec.ExitError).ExitCode()).To(Equal(4))
+		Expect(os.ReadDir(tmpDir)).To(BeEmpty())
+	})
+})
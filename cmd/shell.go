@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/noksa/helm-in-pod/internal"
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/logz"
+)
+
+func newShellCmd() *cobra.Command {
+	shellOpts := cmdoptions.ShellOptions{}
+	shellCmd := &cobra.Command{
+		Use:   "shell",
+		Short: "Open an interactive shell in a one-shot pod",
+		Long: `Create a temporary pod in the cluster and open an interactive shell in it.
+
+The pod is prepared like for exec: files are copied and Helm repositories are synced
+from the host. The pod is deleted when the shell exits or the command is interrupted.`,
+		Args: cobra.NoArgs,
+	}
+	opts := cmdoptions.ExecOptions{}
+	addExecOptionsFlags(shellCmd, &opts)
+	addShellFlags(shellCmd, &shellOpts)
+	shellCmd.RunE = func(cmd *cobra.Command, args []string) (returnErr error) {
+		if opts.CopyAttempts < 1 {
+			return fmt.Errorf("copy-attempts value can't be less 1")
+		}
+		if opts.UpdateRepoAttempts < 1 {
+			return fmt.Errorf("update-repo-attempts value can't be less 1")
+		}
+
+		timeout := viper.GetDuration("timeout")
+		opts.Timeout = timeout + time.Minute*10
+		// The pod waits for the session instead of running a command
+		opts.Attach = true
+
+		if opts.DryRun {
+			return internal.Pod().PrintPodSpecYAML(opts, false)
+		}
+
+		if err := runPreflight(internal.Namespace(), opts, os.Stderr); err != nil {
+			return err
+		}
+
+		defer func() {
+			cleanupErr := internal.Pod().DeleteHelmPods(opts, cmdoptions.PurgeOptions{All: false})
+			if cleanupErr != nil && returnErr == nil {
+				returnErr = cleanupErr
+			}
+		}()
+
+		pod, err := createAndPrepareHelmPod(internal.Namespace(), internal.Pod(), opts)
+		if err != nil {
+			return err
+		}
+		// CreateHelmPod only handles interrupts while the pod is being created,
+		// keep destroying the pod on SIGINT/SIGTERM until the session ends
+		defer internal.Pod().HandleInterrupts(opts)()
+
+		logz.Host().Info().Msgf("Opening interactive shell in %s pod", color.CyanString(pod.Name))
+		logz.Host().Info().Msg("Type 'exit' or press Ctrl+D to close the shell, the pod is deleted afterwards")
+		shellErr := internal.Pod().OpenShellInHelmPod(cmd.Context(), pod, shellOpts, opts)
+
+		if len(opts.CopyFrom) > 0 {
+			if copyErr := copyFromHelmPod(internal.Pod(), pod, opts); copyErr != nil && shellErr == nil {
+				return copyErr
+			}
+		}
+		return shellErr
+	}
+	return shellCmd
+}
@@ -214,6 +214,66 @@ var _ = Describe("Copy Flag", func() {
 			Expect(output).To(ContainSubstring("v2"))
 			Expect(output).NotTo(ContainSubstring("stale.txt"), "stale file should be cleaned up")
 		})
+
+		It("should only sync changes and delete files removed on the host", func() {
+			srcDir := filepath.Join(tmpDir, "sync-chart")
+			Expect(os.MkdirAll(filepath.Join(srcDir, "templates"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(srcDir, "Chart.yaml"), []byte("name: v1"), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(srcDir, "templates", "old.yaml"), []byte("old"), 0644)).To(Succeed())
+			copyFlag := fmt.Sprintf("%s:/tmp/sync-chart", srcDir)
+
+			cmd := exec.Command("helm", "in-pod", "daemon", "exec",
+				"--name", daemonName,
+				"-n", testNS,
+				"--copy", copyFlag,
+				"--", "cat /tmp/sync-chart/templates/old.yaml")
+			output, exitCode := RunWithExitCode(cmd)
+			Expect(exitCode).To(Equal(0), "output: %s", output)
+			Expect(output).To(ContainSubstring("old"))
+
+			cmd = exec.Command("helm", "in-pod", "daemon", "exec",
+				"--name", daemonName,
+				"-n", testNS,
+				"--copy", copyFlag,
+				"--", "true")
+			output, exitCode = RunWithExitCode(cmd)
+			Expect(exitCode).To(Equal(0), "output: %s", output)
+			Expect(output).To(ContainSubstring("Files are up to date"))
+
+			Expect(os.WriteFile(filepath.Join(srcDir, "Chart.yaml"), []byte("name: v2"), 0644)).To(Succeed())
+			Expect(os.Remove(filepath.Join(srcDir, "templates", "old.yaml"))).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(srcDir, "templates", "new.yaml"), []byte("new"), 0644)).To(Succeed())
+
+			cmd = exec.Command("helm", "in-pod", "daemon", "exec",
+				"--name", daemonName,
+				"-n", testNS,
+				"--copy", copyFlag,
+				"--", "sh -c 'cat /tmp/sync-chart/Chart.yaml && ls /tmp/sync-chart/templates/'")
+			output, exitCode = RunWithExitCode(cmd)
+			Expect(exitCode).To(Equal(0), "output: %s", output)
+			Expect(output).To(ContainSubstring("2 changed, 1 removed"))
+			Expect(output).To(ContainSubstring("name: v2"))
+			Expect(output).To(ContainSubstring("new.yaml"))
+			Expect(output).NotTo(ContainSubstring("old.yaml"))
+
+			// A file changed by a command in the pod is sent again
+			cmd = exec.Command("helm", "in-pod", "daemon", "exec",
+				"--name", daemonName,
+				"-n", testNS,
+				"--", "sh -c 'echo drifted > /tmp/sync-chart/Chart.yaml'")
+			output, exitCode = RunWithExitCode(cmd)
+			Expect(exitCode).To(Equal(0), "output: %s", output)
+
+			cmd = exec.Command("helm", "in-pod", "daemon", "exec",
+				"--name", daemonName,
+				"-n", testNS,
+				"--copy", copyFlag,
+				"--", "cat /tmp/sync-chart/Chart.yaml")
+			output, exitCode = RunWithExitCode(cmd)
+			Expect(exitCode).To(Equal(0), "output: %s", output)
+			Expect(output).To(ContainSubstring("1 changed, 0 removed"))
+			Expect(output).To(ContainSubstring("name: v2"))
+		})
 	})
 
 	Context("copy with large file", func() {
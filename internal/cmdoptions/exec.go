@@ -1,8 +1,13 @@
 package cmdoptions
 
 import (
+	"fmt"
+	"maps"
+	"os"
 	"strings"
 	"time"
+
+	"github.com/noksa/helm-in-pod/internal/hipenv"
 )
 
 type ExecOptions struct {
@@ -40,6 +45,98 @@ type ExecOptions struct {
 	DryRun                bool
 	CopyFrom              []string
 	ActiveDeadlineSeconds int64
+	// RBACMode is one of cluster, namespaced or none.
+	// Resolved by the command, defaults to none when ServiceAccount is set.
+	RBACMode        string
+	RBACClusterRole string
+	RBACNamespaces  []string
+	Preflight       bool
+	// PassthroughCredentials runs the pod with the caller's kubeconfig credentials
+	// instead of the service account token.
+	PassthroughCredentials bool
+	// Attach runs the command in an exec session attached from the host instead of
+	// streaming pod logs, so stdout and stderr are kept separate.
+	Attach bool
+	// Stdin forwards the host's stdin to the command until EOF. Implies Attach for exec.
+	Stdin bool
+	// Script is a script file to run instead of a command, "-" for stdin.
+	Script string
+	// Interpreter runs Script, sh or bash.
+	Interpreter string
+	// Contexts are kube contexts to run the command in concurrently.
+	Contexts []string
+	// AllContexts runs the command in every context of the kubeconfig.
+	AllContexts bool
+	// Parallelism bounds how many contexts run at the same time.
+	Parallelism int
+	// AsJob runs the pod through a batch/v1 Job instead of creating it directly.
+	AsJob bool
+	// JobTTLSeconds is the Job's ttlSecondsAfterFinished.
+	JobTTLSeconds int32
+	// JobBackoffLimit is how many times the Job retries a failed command.
+	JobBackoffLimit int32
+	// SubstEnvExclude are glob patterns of host variables SubstEnv patterns don't forward.
+	SubstEnvExclude []string
+	// EnvFiles are dotenv files whose variables are added to Env.
+	EnvFiles []string
+	// EnvFrom are Secrets and ConfigMaps whose keys become environment
+	// variables, in format type:name[:prefix].
+	EnvFrom []string
+	// SecretEnv are environment variables delivered through a per-operation
+	// Secret instead of the pod spec.
+	SecretEnv map[string]string
+	// SecretSubstEnv are host environment variables delivered like SecretEnv.
+	SecretSubstEnv []string
+	// CopyExclude are patterns in .helmignore syntax of files under copied
+	// directories that are not copied.
+	CopyExclude []string
+	// Detach starts the command and returns without waiting for it or deleting the pod.
+	Detach bool
+	// Daemon marks the options of a daemon pod, which is annotated after it is created.
+	Daemon bool
+
+	// Phase timeouts bound the phases of the pod's lifecycle.
+
+	// StartupTimeout bounds the wait for the pod to be created and become ready.
+	StartupTimeout time.Duration
+	// CopyTimeout bounds each copy to or from the pod.
+	CopyTimeout time.Duration
+	// CompletionTimeout bounds the wait for the pod to terminate after the command.
+	CompletionTimeout time.Duration
+	// DeleteTimeout bounds the wait for a daemon pod to be deleted.
+	DeleteTimeout time.Duration
+	// GracePeriod is the pod's terminationGracePeriodSeconds.
+	GracePeriod time.Duration
+	// TerminateTimeout is how long the pod script waits for the command to exit
+	// after the pod is signalled. Shorter than GracePeriod.
+	TerminateTimeout time.Duration
+	// CopyFromWait is how long the pod waits for --copy-from to finish.
+	CopyFromWait time.Duration
+	// ReadWait is how long the pod waits for the host to read the command's
+	// output and result after the command.
+	ReadWait time.Duration
+	// Report is the path of the JSON run report, empty to not write one.
+	Report string
+}
+
+// ForwardedEnv returns the host variables SubstEnv forwards to the pod.
+func (o *ExecOptions) ForwardedEnv() ([]hipenv.Forwarded, error) {
+	forwarded, err := hipenv.Forward(o.SubstEnv, o.SubstEnvExclude, os.Environ())
+	if err != nil {
+		return nil, fmt.Errorf("invalid --subst-env: %w", err)
+	}
+	return forwarded, nil
+}
+
+// SecretEnvValues returns SecretEnv together with the SecretSubstEnv variables
+// resolved from the host.
+func (o *ExecOptions) SecretEnvValues() map[string]string {
+	env := make(map[string]string, len(o.SecretEnv)+len(o.SecretSubstEnv))
+	maps.Copy(env, o.SecretEnv)
+	for _, name := range o.SecretSubstEnv {
+		env[name] = os.Getenv(name)
+	}
+	return env
 }
 
 // ParseFileMappings parses the Files slice into FilesAsMap.
@@ -0,0 +1,8 @@
+package cmdoptions
+
+type ShellOptions struct {
+	// Shell is the shell binary in the pod, optionally followed by arguments.
+	Shell string
+	// Login starts the shell as a login shell so profile files are read.
+	Login bool
+}
@@ -0,0 +1,133 @@
+package helmtar
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"helm.sh/helm/v4/pkg/ignore"
+
+	"github.com/noksa/helm-in-pod/internal/logz"
+)
+
+// IgnoreFile lists files of a copied directory that are not copied, in the
+// syntax of .helmignore. It is read from the root of every copied directory.
+const IgnoreFile = ".hipignore"
+
+// ParseExcludes parses exclude patterns in the syntax of .helmignore.
+func ParseExcludes(patterns []string) (*ignore.Rules, error) {
+	rules, err := ignore.Parse(strings.NewReader(strings.Join(patterns, "\n")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid copy exclude patterns: %w", err)
+	}
+	return rules, nil
+}
+
+// Filter skips the files of a copied directory that are matched by the exclude
+// patterns, by the directory's .hipignore file or, if the directory is a chart,
+// by its .helmignore file. It counts what it skips.
+type Filter struct {
+	dir   string
+	rules []*ignore.Rules
+	files int
+	size  int64
+}
+
+// NewFilter returns the filter of the copied directory dir.
+func NewFilter(dir string, excludes []string) (*Filter, error) {
+	f := &Filter{dir: dir}
+	if len(excludes) > 0 {
+		rules, err := ParseExcludes(excludes)
+		if err != nil {
+			return nil, err
+		}
+		f.rules = append(f.rules, rules)
+	}
+	ignoreFiles := []string{IgnoreFile}
+	if isFile(filepath.Join(dir, "Chart.yaml")) {
+		ignoreFiles = append(ignoreFiles, ignore.HelmIgnore)
+	}
+	for _, name := range ignoreFiles {
+		path := filepath.Join(dir, name)
+		if !isFile(path) {
+			continue
+		}
+		rules, err := ignore.ParseFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", path, err)
+		}
+		f.rules = append(f.rules, rules)
+	}
+	return f, nil
+}
+
+// Skip reports whether the file at path in the directory is excluded. fi
+// describes the file without following symlinks, like in filepath.Walk.
+// Skipped directories must not be walked.
+func (f *Filter) Skip(path string, fi fs.FileInfo) bool {
+	if f == nil || len(f.rules) == 0 {
+		return false
+	}
+	rel, err := filepath.Rel(f.dir, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, rules := range f.rules {
+		if rules.Ignore(rel, fi) {
+			f.count(path, fi)
+			logz.HostPod().Debug().Msgf("%v is excluded", color.CyanString(path))
+			return true
+		}
+	}
+	return false
+}
+
+// count adds the files and size of the skipped path.
+func (f *Filter) count(path string, fi fs.FileInfo) {
+	if !fi.IsDir() {
+		f.files++
+		f.size += fi.Size()
+		return
+	}
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			f.files++
+			f.size += info.Size()
+		}
+		return nil
+	})
+}
+
+// Report logs how many files were excluded and their total size.
+func (f *Filter) Report() {
+	if f == nil || f.files == 0 {
+		return
+	}
+	logz.HostPod().Debug().Msgf("Excluded %d files (%v) from %v", f.files, formatSize(f.size), color.CyanString(f.dir))
+}
+
+// formatSize formats n bytes with a binary unit, e.g. 1.5 MiB.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func isFile(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular()
+}
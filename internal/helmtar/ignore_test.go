@@ -0,0 +1,98 @@
+package helmtar
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Filter", func() {
+	var srcDir string
+
+	write := func(name, content string) {
+		path := filepath.Join(srcDir, name)
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	compress := func(excludes ...string) map[string]string {
+		var buf bytes.Buffer
+		Expect(CompressMulti([]BundleEntry{{SrcPath: srcDir, DestPath: "/dest", Excludes: excludes}}, &buf)).To(Succeed())
+		files, err := extractTarGz(&buf)
+		Expect(err).NotTo(HaveOccurred())
+		return files
+	}
+
+	BeforeEach(func() {
+		srcDir = GinkgoT().TempDir()
+		write("values.yaml", "replicas: 1")
+		write(".git/HEAD", "ref: refs/heads/main")
+		write("node_modules/pkg/index.js", "module.exports = {}")
+		write("build/chart.tgz", "binary")
+	})
+
+	It("should skip files matched by --copy-exclude patterns", func() {
+		files := compress(".git/", "node_modules/", "*.tgz")
+		Expect(files).To(HaveKey("/dest/values.yaml"))
+		Expect(files).To(HaveKey("/dest/build"))
+		Expect(files).NotTo(HaveKey("/dest/build/chart.tgz"))
+		Expect(files).NotTo(HaveKey("/dest/.git"))
+		Expect(files).NotTo(HaveKey("/dest/.git/HEAD"))
+		Expect(files).NotTo(HaveKey("/dest/node_modules/pkg/index.js"))
+	})
+
+	It("should honor the .hipignore file", func() {
+		write(IgnoreFile, "# local junk\n.git/\nbuild/\n")
+		files := compress()
+		Expect(files).To(HaveKey("/dest/values.yaml"))
+		Expect(files).To(HaveKey("/dest/node_modules/pkg/index.js"))
+		Expect(files).NotTo(HaveKey("/dest/.git/HEAD"))
+		Expect(files).NotTo(HaveKey("/dest/build"))
+	})
+
+	It("should honor .helmignore in charts only", func() {
+		write(".helmignore", "node_modules/\n")
+		Expect(compress()).To(HaveKey("/dest/node_modules/pkg/index.js"))
+
+		write("Chart.yaml", "name: chart")
+		Expect(compress()).NotTo(HaveKey("/dest/node_modules/pkg/index.js"))
+	})
+
+	It("should count the excluded files and their size", func() {
+		filter, err := NewFilter(srcDir, []string{"node_modules/", "*.tgz"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(filepath.Walk(srcDir, func(file string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if filter.Skip(file, fi) && fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		})).To(Succeed())
+		Expect(filter.files).To(Equal(2))
+		Expect(filter.size).To(Equal(int64(len("module.exports = {}") + len("binary"))))
+	})
+
+	It("should reject invalid patterns", func() {
+		_, err := NewFilter(srcDir, []string{"**/*.tgz"})
+		Expect(err).To(MatchError(ContainSubstring("invalid copy exclude patterns")))
+	})
+
+	It("should reject an invalid .hipignore file", func() {
+		write(IgnoreFile, "**\n")
+		_, err := NewFilter(srcDir, nil)
+		Expect(err).To(MatchError(ContainSubstring(IgnoreFile)))
+	})
+})
+
+var _ = Describe("formatSize", func() {
+	It("should format sizes with binary units", func() {
+		Expect(formatSize(512)).To(Equal("512 B"))
+		Expect(formatSize(1536)).To(Equal("1.5 KiB"))
+		Expect(formatSize(3 * 1024 * 1024)).To(Equal("3.0 MiB"))
+	})
+})
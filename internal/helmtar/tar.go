@@ -18,6 +18,9 @@ import (
 type BundleEntry struct {
 	SrcPath  string // local path (file or directory)
 	DestPath string // absolute path inside the pod
+	// Excludes are patterns in .helmignore syntax of files under a directory
+	// SrcPath that are not copied, in addition to its .hipignore file
+	Excludes []string
 }
 
 // CompressMulti packs multiple (src, dest) pairs into a single gzip-compressed tar stream.
@@ -27,7 +30,7 @@ func CompressMulti(entries []BundleEntry, buf io.Writer) error {
 	tw := tar.NewWriter(zr)
 
 	for _, e := range entries {
-		if err := addToTar(tw, e.SrcPath, e.DestPath); err != nil {
+		if err := addToTar(tw, e.SrcPath, e.DestPath, e.Excludes); err != nil {
 			return err
 		}
 	}
@@ -38,19 +41,78 @@ func CompressMulti(entries []BundleEntry, buf io.Writer) error {
 	return zr.Close()
 }
 
+// CompressFiles packs regular files into a gzip-compressed tar stream like
+// CompressMulti. Every SrcPath must be a file; symlinks are followed, so the pod
+// receives the content of their targets.
+func CompressFiles(files []BundleEntry, buf io.Writer) error {
+	zr := gzip.NewWriter(buf)
+	tw := tar.NewWriter(zr)
+
+	for _, f := range files {
+		if err := addFileToTar(tw, f.SrcPath, f.DestPath); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zr.Close()
+}
+
+// addFileToTar adds the regular file src to the tar writer as destPath.
+func addFileToTar(tw *tar.Writer, src string, destPath string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", src)
+	}
+	header, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(destPath)
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
 // addToTar walks the source path and adds all files/directories to the tar writer
-// with the correct destination path inside the pod.
-func addToTar(tw *tar.Writer, src string, destPath string) error {
+// with the correct destination path inside the pod. Files of a directory
+// excluded by its Filter are skipped.
+func addToTar(tw *tar.Writer, src string, destPath string, excludes []string) error {
 	stat, err := os.Stat(src)
 	if err != nil {
 		return err
 	}
 	isDir := stat.IsDir()
+	var filter *Filter
+	if isDir {
+		if filter, err = NewFilter(src, excludes); err != nil {
+			return err
+		}
+		defer filter.Report()
+	}
 
 	return filepath.Walk(src, func(file string, fi os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if filter.Skip(file, fi) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 
 		header, err := tar.FileInfoHeader(fi, file)
 		if err != nil {
@@ -162,3 +162,48 @@ var _ = Describe("CompressMulti", func() {
 		})
 	})
 })
+
+var _ = Describe("CompressFiles", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		tmpDir = GinkgoT().TempDir()
+	})
+
+	It("should pack files under their destination paths", func() {
+		first := filepath.Join(tmpDir, "a.txt")
+		second := filepath.Join(tmpDir, "b.txt")
+		Expect(os.WriteFile(first, []byte("a"), 0644)).To(Succeed())
+		Expect(os.WriteFile(second, []byte("b"), 0644)).To(Succeed())
+
+		var buf bytes.Buffer
+		Expect(CompressFiles([]BundleEntry{
+			{SrcPath: first, DestPath: "/dest/a.txt"},
+			{SrcPath: second, DestPath: "/dest/nested/b.txt"},
+		}, &buf)).To(Succeed())
+
+		files, err := extractTarGz(&buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(Equal(map[string]string{"/dest/a.txt": "a", "/dest/nested/b.txt": "b"}))
+	})
+
+	It("should pack the target of a symlink", func() {
+		target := filepath.Join(tmpDir, "target.txt")
+		link := filepath.Join(tmpDir, "link.txt")
+		Expect(os.WriteFile(target, []byte("content"), 0644)).To(Succeed())
+		Expect(os.Symlink(target, link)).To(Succeed())
+
+		var buf bytes.Buffer
+		Expect(CompressFiles([]BundleEntry{{SrcPath: link, DestPath: "/dest/link.txt"}}, &buf)).To(Succeed())
+
+		files, err := extractTarGz(&buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveKeyWithValue("/dest/link.txt", "content"))
+	})
+
+	It("should reject directories", func() {
+		var buf bytes.Buffer
+		err := CompressFiles([]BundleEntry{{SrcPath: tmpDir, DestPath: "/dest"}}, &buf)
+		Expect(err).To(MatchError(ContainSubstring("is not a regular file")))
+	})
+})
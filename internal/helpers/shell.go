@@ -0,0 +1,17 @@
+package helpers
+
+import "strings"
+
+// ShellQuote quotes s for POSIX shells.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ShellQuoteAll quotes every element of args for POSIX shells and joins them with spaces.
+func ShellQuoteAll(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, ShellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,22 @@
+package helpers
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ShellQuote", func() {
+	It("should quote plain strings", func() {
+		Expect(ShellQuote("/tmp/my chart")).To(Equal(`'/tmp/my chart'`))
+	})
+
+	It("should escape single quotes", func() {
+		Expect(ShellQuote("it's")).To(Equal(`'it'\''s'`))
+	})
+})
+
+var _ = Describe("ShellQuoteAll", func() {
+	It("should quote and join all arguments", func() {
+		Expect(ShellQuoteAll([]string{"/tmp/a", "$HOME"})).To(Equal(`'/tmp/a' '$HOME'`))
+	})
+})
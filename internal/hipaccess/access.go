@@ -0,0 +1,161 @@
+// Package hipaccess checks with SelfSubjectAccessReview that the current user
+// has every permission a plugin flow needs before anything is created.
+package hipaccess
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+)
+
+// Check is a single permission required by a flow.
+type Check struct {
+	Verb        string
+	Group       string
+	Resource    string
+	Subresource string
+	// Namespace is empty for cluster-scoped resources.
+	Namespace string
+	Name      string
+}
+
+// ResourceString returns the resource in kubectl notation, e.g. pods/exec or poddisruptionbudgets.policy.
+func (c Check) ResourceString() string {
+	r := c.Resource
+	if c.Group != "" {
+		r = fmt.Sprintf("%s.%s", r, c.Group)
+	}
+	if c.Subresource != "" {
+		r = fmt.Sprintf("%s/%s", r, c.Subresource)
+	}
+	return r
+}
+
+// Result is the outcome of a Check.
+type Result struct {
+	Check
+	Allowed bool
+	Reason  string
+}
+
+// Checks returns the permissions needed to run a pod in the plugin namespace
+// with the given options. namespaceExists controls whether creating the namespace is required.
+func Checks(namespace string, namespaceExists bool, opts cmdoptions.ExecOptions) []Check {
+	checks := []Check{{Verb: "get", Resource: "namespaces", Name: namespace}}
+	if !namespaceExists {
+		checks = append(checks, Check{Verb: "create", Resource: "namespaces"})
+	}
+
+	if opts.ServiceAccount == "" {
+		for _, verb := range []string{"get", "create"} {
+			checks = append(checks, Check{Verb: verb, Resource: "serviceaccounts", Namespace: namespace})
+		}
+	}
+	if opts.RBACMode != hipconsts.RBACModeNone {
+		switch opts.RBACMode {
+		case hipconsts.RBACModeNamespaced:
+			for _, ns := range opts.RBACNamespaces {
+				for _, verb := range []string{"get", "create", "delete"} {
+					checks = append(checks, Check{Verb: verb, Group: "rbac.authorization.k8s.io", Resource: "rolebindings", Namespace: ns})
+				}
+			}
+		default:
+			for _, verb := range []string{"get", "create", "delete"} {
+				checks = append(checks, Check{Verb: verb, Group: "rbac.authorization.k8s.io", Resource: "clusterrolebindings"})
+			}
+			// Binding a ClusterRole requires bind on it unless the user already holds all its permissions
+			clusterRole := opts.RBACClusterRole
+			if clusterRole == "" {
+				clusterRole = hipconsts.DefaultRBACClusterRole
+			}
+			checks = append(checks, Check{Verb: "bind", Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Name: clusterRole})
+		}
+	}
+
+	podVerbs := []string{"create", "get", "list", "delete"}
+	// Daemon and detached pods are annotated after they are created
+	if opts.Daemon || opts.Detach {
+		podVerbs = append(podVerbs, "update")
+	}
+	for _, verb := range podVerbs {
+		checks = append(checks, Check{Verb: verb, Resource: "pods", Namespace: namespace})
+	}
+	checks = append(checks,
+		Check{Verb: "create", Resource: "pods", Subresource: "exec", Namespace: namespace},
+		Check{Verb: "get", Resource: "pods", Subresource: "log", Namespace: namespace},
+	)
+
+	// Passed-through credentials and secret env are delivered through per-operation Secrets
+	if opts.PassthroughCredentials || len(opts.SecretEnvValues()) > 0 {
+		for _, verb := range []string{"create", "update", "deletecollection"} {
+			checks = append(checks, Check{Verb: verb, Resource: "secrets", Namespace: namespace})
+		}
+	}
+
+	// --env-from sources are read with the user's credentials before the pod is created
+	for _, source := range opts.EnvFrom {
+		kind, rest, _ := strings.Cut(source, ":")
+		name, _, _ := strings.Cut(rest, ":")
+		switch strings.ToLower(kind) {
+		case "secret":
+			checks = append(checks, Check{Verb: "get", Resource: "secrets", Namespace: namespace, Name: name})
+		case "configmap":
+			checks = append(checks, Check{Verb: "get", Resource: "configmaps", Namespace: namespace, Name: name})
+		}
+	}
+
+	if opts.AsJob {
+		for _, verb := range []string{"create", "get", "list", "delete"} {
+			checks = append(checks, Check{Verb: verb, Group: "batch", Resource: "jobs", Namespace: namespace})
+		}
+	}
+
+	if opts.CreatePDB {
+		for _, verb := range []string{"create", "deletecollection"} {
+			checks = append(checks, Check{Verb: verb, Group: "policy", Resource: "poddisruptionbudgets", Namespace: namespace})
+		}
+	}
+	return checks
+}
+
+// Run submits a SelfSubjectAccessReview for every check.
+func Run(ctx context.Context, cs kubernetes.Interface, checks []Check) ([]Result, error) {
+	results := make([]Result, 0, len(checks))
+	for _, c := range checks {
+		review, err := cs.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   c.Namespace,
+					Verb:        c.Verb,
+					Group:       c.Group,
+					Resource:    c.Resource,
+					Subresource: c.Subresource,
+					Name:        c.Name,
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to check %s %s: %w", c.Verb, c.ResourceString(), err)
+		}
+		results = append(results, Result{Check: c, Allowed: review.Status.Allowed, Reason: review.Status.Reason})
+	}
+	return results, nil
+}
+
+// Missing returns the results that are not allowed.
+func Missing(results []Result) []Result {
+	var missing []Result
+	for _, r := range results {
+		if !r.Allowed {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
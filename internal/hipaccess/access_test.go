@@ -0,0 +1,157 @@
+package hipaccess
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+)
+
+func resources(checks []Check) []string {
+	result := make([]string, 0, len(checks))
+	for _, c := range checks {
+		result = append(result, c.Verb+" "+c.ResourceString()+" "+c.Namespace)
+	}
+	return result
+}
+
+var _ = Describe("Checks", func() {
+	It("should check cluster mode objects for the default service account", func() {
+		checks := resources(Checks("helm-in-pod", true, cmdoptions.ExecOptions{RBACMode: hipconsts.RBACModeCluster, CreatePDB: true}))
+		Expect(checks).To(ContainElements(
+			"get namespaces ",
+			"create serviceaccounts helm-in-pod",
+			"create clusterrolebindings.rbac.authorization.k8s.io ",
+			"create pods helm-in-pod",
+			"create pods/exec helm-in-pod",
+			"get pods/log helm-in-pod",
+			"create poddisruptionbudgets.policy helm-in-pod",
+		))
+		Expect(checks).NotTo(ContainElement("create namespaces "))
+	})
+
+	It("should check bind on the bound ClusterRole in cluster mode", func() {
+		checks := Checks("helm-in-pod", true, cmdoptions.ExecOptions{RBACMode: hipconsts.RBACModeCluster})
+		Expect(checks).To(ContainElement(Check{Verb: "bind", Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Name: hipconsts.DefaultRBACClusterRole}))
+
+		checks = Checks("helm-in-pod", true, cmdoptions.ExecOptions{RBACMode: hipconsts.RBACModeCluster, RBACClusterRole: "edit"})
+		Expect(checks).To(ContainElement(Check{Verb: "bind", Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Name: "edit"}))
+
+		checks = Checks("helm-in-pod", true, cmdoptions.ExecOptions{RBACMode: hipconsts.RBACModeNone})
+		Expect(resources(checks)).NotTo(ContainElement("bind clusterroles.rbac.authorization.k8s.io "))
+	})
+
+	It("should require namespace creation when the namespace is missing", func() {
+		checks := resources(Checks("team-a", false, cmdoptions.ExecOptions{RBACMode: hipconsts.RBACModeCluster}))
+		Expect(checks).To(ContainElement("create namespaces "))
+	})
+
+	It("should check rolebindings in every target namespace in namespaced mode", func() {
+		checks := resources(Checks("helm-in-pod", true, cmdoptions.ExecOptions{
+			RBACMode:       hipconsts.RBACModeNamespaced,
+			RBACNamespaces: []string{"apps", "monitoring"},
+		}))
+		Expect(checks).To(ContainElements(
+			"create rolebindings.rbac.authorization.k8s.io apps",
+			"create rolebindings.rbac.authorization.k8s.io monitoring",
+		))
+		Expect(checks).NotTo(ContainElement("create clusterrolebindings.rbac.authorization.k8s.io "))
+	})
+
+	It("should skip serviceaccount and binding checks in none mode", func() {
+		checks := resources(Checks("helm-in-pod", true, cmdoptions.ExecOptions{RBACMode: hipconsts.RBACModeNone, ServiceAccount: "deployer"}))
+		for _, c := range checks {
+			Expect(c).NotTo(ContainSubstring("serviceaccounts"))
+			Expect(c).NotTo(ContainSubstring("rolebindings"))
+		}
+	})
+
+	It("should check secrets for credential passthrough", func() {
+		checks := resources(Checks("helm-in-pod", true, cmdoptions.ExecOptions{RBACMode: hipconsts.RBACModeNone, PassthroughCredentials: true}))
+		Expect(checks).To(ContainElements(
+			"create serviceaccounts helm-in-pod",
+			"create secrets helm-in-pod",
+			"deletecollection secrets helm-in-pod",
+		))
+		Expect(checks).NotTo(ContainElement("create clusterrolebindings.rbac.authorization.k8s.io "))
+	})
+
+	It("should check secrets for secret env without credential passthrough", func() {
+		checks := resources(Checks("helm-in-pod", true, cmdoptions.ExecOptions{RBACMode: hipconsts.RBACModeCluster, SecretEnv: map[string]string{"TOKEN": "s3cr3t"}}))
+		Expect(checks).To(ContainElements(
+			"create secrets helm-in-pod",
+			"update secrets helm-in-pod",
+			"deletecollection secrets helm-in-pod",
+		))
+		checks = resources(Checks("helm-in-pod", true, cmdoptions.ExecOptions{RBACMode: hipconsts.RBACModeCluster}))
+		for _, c := range checks {
+			Expect(c).NotTo(ContainSubstring("secrets"))
+		}
+	})
+
+	It("should check that --env-from sources can be read", func() {
+		checks := Checks("helm-in-pod", true, cmdoptions.ExecOptions{
+			RBACMode: hipconsts.RBACModeCluster,
+			EnvFrom:  []string{"secret:deploy-creds", "ConfigMap:settings:APP_"},
+		})
+		Expect(checks).To(ContainElements(
+			Check{Verb: "get", Resource: "secrets", Namespace: "helm-in-pod", Name: "deploy-creds"},
+			Check{Verb: "get", Resource: "configmaps", Namespace: "helm-in-pod", Name: "settings"},
+		))
+	})
+
+	It("should check jobs only with --as-job", func() {
+		checks := resources(Checks("helm-in-pod", true, cmdoptions.ExecOptions{RBACMode: hipconsts.RBACModeCluster, AsJob: true}))
+		Expect(checks).To(ContainElements(
+			"create jobs.batch helm-in-pod",
+			"delete jobs.batch helm-in-pod",
+		))
+		checks = resources(Checks("helm-in-pod", true, cmdoptions.ExecOptions{RBACMode: hipconsts.RBACModeCluster}))
+		for _, c := range checks {
+			Expect(c).NotTo(ContainSubstring("jobs.batch"))
+		}
+	})
+
+	It("should check pod updates only for daemons and detached runs", func() {
+		checks := resources(Checks("helm-in-pod", true, cmdoptions.ExecOptions{RBACMode: hipconsts.RBACModeCluster, Daemon: true}))
+		Expect(checks).To(ContainElement("update pods helm-in-pod"))
+		checks = resources(Checks("helm-in-pod", true, cmdoptions.ExecOptions{RBACMode: hipconsts.RBACModeCluster, Detach: true}))
+		Expect(checks).To(ContainElement("update pods helm-in-pod"))
+		checks = resources(Checks("helm-in-pod", true, cmdoptions.ExecOptions{RBACMode: hipconsts.RBACModeCluster}))
+		Expect(checks).NotTo(ContainElement("update pods helm-in-pod"))
+	})
+
+	It("should skip PDB checks when PDB creation is disabled", func() {
+		checks := resources(Checks("helm-in-pod", true, cmdoptions.ExecOptions{RBACMode: hipconsts.RBACModeCluster}))
+		for _, c := range checks {
+			Expect(c).NotTo(ContainSubstring("poddisruptionbudgets"))
+		}
+	})
+})
+
+var _ = Describe("Run", func() {
+	It("should report denied permissions", func() {
+		cs := fake.NewSimpleClientset()
+		cs.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+			review.Status.Allowed = review.Spec.ResourceAttributes.Subresource != "exec"
+			return true, review, nil
+		})
+		results, err := Run(context.Background(), cs, []Check{
+			{Verb: "create", Resource: "pods", Namespace: "helm-in-pod"},
+			{Verb: "create", Resource: "pods", Subresource: "exec", Namespace: "helm-in-pod"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(HaveLen(2))
+		missing := Missing(results)
+		Expect(missing).To(HaveLen(1))
+		Expect(missing[0].ResourceString()).To(Equal("pods/exec"))
+	})
+})
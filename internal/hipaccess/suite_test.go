@@ -0,0 +1,13 @@
+package hipaccess
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestHipaccess(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Hipaccess Suite")
+}
@@ -0,0 +1,135 @@
+package hipconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"sigs.k8s.io/yaml"
+)
+
+// EnvConfigPath overrides the location of the configuration file.
+const EnvConfigPath = "HELM_IN_POD_CONFIG"
+
+// Profile maps flag names (without leading dashes) to their values.
+// Scalars, lists and maps are accepted, e.g.:
+//
+//	image: docker.io/noksa/kubectl-helm:v1.34.5-v4.1.1
+//	tolerations: ["::Exists"]
+//	node-selector: {disktype: ssd}
+type Profile map[string]any
+
+// Config is the on-disk configuration file.
+type Config struct {
+	// Profiles holds named sets of flag values.
+	Profiles map[string]Profile `json:"profiles"`
+	// Contexts maps kube context names to the profile that is selected
+	// automatically when no --profile flag is passed.
+	Contexts map[string]string `json:"contexts"`
+}
+
+// DefaultPath returns the configuration file path: HELM_IN_POD_CONFIG if set,
+// otherwise $XDG_CONFIG_HOME/helm-in-pod/config.yaml (~/.config by default) on every platform.
+func DefaultPath() (string, error) {
+	if p := os.Getenv(EnvConfigPath); p != "" {
+		return p, nil
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "helm-in-pod", "config.yaml"), nil
+}
+
+// Load reads the configuration file at path. A missing file yields an empty config.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Resolve picks the profile to use. An explicitly requested profile must exist;
+// otherwise the profile mapped to kubeContext is used, if any.
+// It returns an empty name and nil profile when nothing is selected.
+func (c *Config) Resolve(name, kubeContext string) (string, Profile, error) {
+	if name != "" {
+		p, ok := c.Profiles[name]
+		if !ok {
+			return "", nil, fmt.Errorf("profile %q is not defined", name)
+		}
+		return name, p, nil
+	}
+	if kubeContext == "" {
+		return "", nil, nil
+	}
+	mapped, ok := c.Contexts[kubeContext]
+	if !ok {
+		return "", nil, nil
+	}
+	p, ok := c.Profiles[mapped]
+	if !ok {
+		return "", nil, fmt.Errorf("profile %q mapped to context %q is not defined", mapped, kubeContext)
+	}
+	return mapped, p, nil
+}
+
+// FlagValues converts a profile value into the list of strings that should be
+// passed to pflag's Set, one call per element. Maps are rendered as key=value.
+func FlagValues(v any) ([]string, error) {
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{val}, nil
+	case bool:
+		return []string{strconv.FormatBool(val)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(val, 'f', -1, 64)}, nil
+	case []any:
+		result := make([]string, 0, len(val))
+		for _, item := range val {
+			items, err := FlagValues(item)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, items...)
+		}
+		return result, nil
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		result := make([]string, 0, len(val))
+		for _, k := range keys {
+			items, err := FlagValues(val[k])
+			if err != nil {
+				return nil, err
+			}
+			if len(items) != 1 {
+				return nil, fmt.Errorf("value of %q must be a scalar", k)
+			}
+			result = append(result, fmt.Sprintf("%s=%s", k, items[0]))
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", v)
+	}
+}
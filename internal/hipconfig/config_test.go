@@ -0,0 +1,139 @@
+package hipconfig
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Load", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+	})
+
+	It("should return an empty config when the file does not exist", func() {
+		cfg, err := Load(filepath.Join(dir, "missing.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Profiles).To(BeEmpty())
+		Expect(cfg.Contexts).To(BeEmpty())
+	})
+
+	It("should parse profiles and context mappings", func() {
+		path := filepath.Join(dir, "config.yaml")
+		Expect(os.WriteFile(path, []byte(`
+profiles:
+  ci:
+    image: my-image:v1
+    create-pdb: false
+    copy-attempts: 5
+    tolerations: ["::Exists"]
+    node-selector:
+      disktype: ssd
+contexts:
+  prod-eu: ci
+`), 0o600)).To(Succeed())
+
+		cfg, err := Load(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Profiles).To(HaveKey("ci"))
+		Expect(cfg.Profiles["ci"]).To(HaveKeyWithValue("image", "my-image:v1"))
+		Expect(cfg.Contexts).To(HaveKeyWithValue("prod-eu", "ci"))
+	})
+
+	It("should reject unknown top-level keys", func() {
+		path := filepath.Join(dir, "config.yaml")
+		Expect(os.WriteFile(path, []byte("profile:\n  ci: {}\n"), 0o600)).To(Succeed())
+		_, err := Load(path)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("DefaultPath", func() {
+	AfterEach(func() {
+		_ = os.Unsetenv(EnvConfigPath)
+	})
+
+	It("should honor HELM_IN_POD_CONFIG", func() {
+		_ = os.Setenv(EnvConfigPath, "/tmp/custom.yaml")
+		path, err := DefaultPath()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(Equal("/tmp/custom.yaml"))
+	})
+
+	It("should fall back to the user config directory", func() {
+		_ = os.Unsetenv(EnvConfigPath)
+		path, err := DefaultPath()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(HaveSuffix(filepath.Join("helm-in-pod", "config.yaml")))
+	})
+})
+
+var _ = Describe("Resolve", func() {
+	cfg := &Config{
+		Profiles: map[string]Profile{
+			"ci":   {"image": "ci-image"},
+			"prod": {"image": "prod-image"},
+		},
+		Contexts: map[string]string{
+			"prod-eu": "prod",
+			"broken":  "missing",
+		},
+	}
+
+	It("should return the explicitly requested profile", func() {
+		name, p, err := cfg.Resolve("ci", "prod-eu")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("ci"))
+		Expect(p).To(HaveKeyWithValue("image", "ci-image"))
+	})
+
+	It("should fail for an unknown explicit profile", func() {
+		_, _, err := cfg.Resolve("nope", "")
+		Expect(err).To(MatchError(ContainSubstring(`profile "nope" is not defined`)))
+	})
+
+	It("should auto-select a profile by kube context", func() {
+		name, p, err := cfg.Resolve("", "prod-eu")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("prod"))
+		Expect(p).To(HaveKeyWithValue("image", "prod-image"))
+	})
+
+	It("should select nothing for an unmapped context", func() {
+		name, p, err := cfg.Resolve("", "dev")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(BeEmpty())
+		Expect(p).To(BeNil())
+	})
+
+	It("should fail when a context maps to an undefined profile", func() {
+		_, _, err := cfg.Resolve("", "broken")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("FlagValues", func() {
+	It("should convert scalars", func() {
+		Expect(FlagValues("abc")).To(Equal([]string{"abc"}))
+		Expect(FlagValues(true)).To(Equal([]string{"true"}))
+		Expect(FlagValues(float64(1000))).To(Equal([]string{"1000"}))
+		Expect(FlagValues(nil)).To(BeEmpty())
+	})
+
+	It("should flatten lists", func() {
+		Expect(FlagValues([]any{"a", "b"})).To(Equal([]string{"a", "b"}))
+	})
+
+	It("should render maps as sorted key=value pairs", func() {
+		Expect(FlagValues(map[string]any{"b": "2", "a": "1"})).To(Equal([]string{"a=1", "b=2"}))
+	})
+
+	It("should reject nested maps", func() {
+		_, err := FlagValues(map[string]any{"a": []any{"x", "y"}})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,13 @@
+package hipconfig
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestHipconfig(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Hipconfig Suite")
+}
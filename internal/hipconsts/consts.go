@@ -1,27 +1,95 @@
 package hipconsts
 
+import "time"
+
+// Default phase timeouts, used when the corresponding option is not set
+const (
+	DefaultStartupTimeout    = 5 * time.Minute
+	DefaultCopyTimeout       = 10 * time.Minute
+	DefaultCompletionTimeout = time.Minute
+	DefaultDeleteTimeout     = 2 * time.Minute
+	DefaultGracePeriod       = 5 * time.Minute
+	DefaultTerminateTimeout  = 3 * time.Minute
+	DefaultCopyFromWait      = 5 * time.Minute
+	DefaultReadWait          = time.Minute
+)
+
 const (
-	HelmInPodNamespace           = "helm-in-pod"
+	// HelmInPodNamespace is the default namespace for plugin pods.
+	HelmInPodNamespace = "helm-in-pod"
+	// HelmInPodName is used for the container, service account, managed-by label
+	// and generated object names regardless of the namespace the plugin runs in.
+	HelmInPodName = "helm-in-pod"
+
 	AnnotationHomeDirectory      = "helm-in-pod/home-directory"
 	AnnotationHelmFound          = "helm-in-pod/helm-found"
 	AnnotationHelm4              = "helm-in-pod/helm4"
 	AnnotationLastRepoUpdateTime = "helm-in-pod/last-repo-update-time"
+	// AnnotationResultNonce holds the nonce the result record of a detached
+	// command is signed with, so wait and result can verify it later
+	AnnotationResultNonce = "helm-in-pod/result-nonce"
 
 	EnvDaemonName = "HELM_IN_POD_DAEMON_NAME"
+	EnvNamespace  = "HELM_IN_POD_NAMESPACE"
+
+	LabelOperationID     = "helm-in-pod/operation-id"
+	LabelManagedBy       = "app.kubernetes.io/managed-by"
+	LabelPluginNamespace = "helm-in-pod/plugin-namespace"
 
-	LabelOperationID = "helm-in-pod/operation-id"
-	LabelManagedBy   = "app.kubernetes.io/managed-by"
+	// RBAC modes for the plugin ServiceAccount
+	RBACModeCluster    = "cluster"
+	RBACModeNamespaced = "namespaced"
+	RBACModeNone       = "none"
+	// DefaultRBACClusterRole is bound when --rbac-cluster-role is not set
+	DefaultRBACClusterRole = "cluster-admin"
+
+	// SyncManifestName is the file in the daemon home directory that records the
+	// files --copy synced to the daemon pod, so later syncs only send changes
+	SyncManifestName = ".helm-in-pod-sync.json"
 
 	// Sentinel files for copy-from flow
 	CopyFromDoneFile = "/tmp/copy-done"
 
-	// Marker written to stdout by the pod script when command finishes (copy-from mode)
-	CopyFromExitCodeMarkerPrefix = "###HIP_EXIT_CODE:"
-	CopyFromExitCodeMarkerSuffix = "###"
-
 	// Environment variable to enable copy-from wait mode in the pod script
 	EnvWaitCopyDone = "WAIT_COPY_DONE"
 
-	// WrappedScriptPath is the fixed path inside the pod for the user command script.
+	// WrappedScriptPath is the fixed path inside the pod for the script that runs
+	// the user command and records its result. The pod script starts once it exists.
 	WrappedScriptPath = "/tmp/hip-wrapped-script.sh"
+	// CommandScriptPath is the fixed path inside the pod for the user command script.
+	CommandScriptPath = "/tmp/hip-command.sh"
+
+	// Environment variable to make the pod script wait for an attached exec session
+	// to run the command instead of running it itself
+	EnvAttach = "HIP_ATTACH"
+	// ResultFile receives the nonce-signed result record of the command, written
+	// by the wrapped script in every exec mode
+	ResultFile = "/tmp/hip-result.json"
+	// NonceFile holds the nonce the result record is signed with. The wrapped
+	// script removes it before it starts the command
+	NonceFile = "/tmp/hip-nonce"
+	// ExitCodeFile is created by the host with the exit code of an attached
+	// command once it has verified its result record, the pod exits with it
+	ExitCodeFile = "/tmp/hip-exit-code"
+
+	// OutputFile receives the command's output in addition to the container log,
+	// so the host can resume streaming it from the last byte it received
+	OutputFile = "/tmp/hip-output"
+	// OutputDoneFile is created by the pod script once OutputFile is complete
+	OutputDoneFile = "/tmp/hip-output.done"
+	// OutputReadFile is created by the host once it has read OutputFile, so the pod can exit
+	OutputReadFile = "/tmp/hip-output.read"
+
+	// Environment variable to make the pod script exit without waiting for the
+	// host to read the output, set for exec --detach
+	EnvDetach = "HIP_DETACH"
+
+	// Environment variable with the seconds the pod script waits for the command
+	// to exit after the pod is signalled
+	EnvTerminateTimeout = "HIP_TERMINATE_TIMEOUT"
+	// Environment variable with the seconds the pod script waits for copy-from
+	EnvCopyFromWait = "HIP_COPY_FROM_WAIT"
+	// Environment variable with the seconds the pod script waits for the host to
+	// read the command's output and result
+	EnvReadWait = "HIP_READ_WAIT"
 )
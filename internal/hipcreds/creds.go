@@ -0,0 +1,157 @@
+// Package hipcreds resolves the caller's credentials from the loaded kubeconfig
+// so that commands in the pod can run with the caller's own identity.
+package hipcreds
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// InClusterServer is the API server address used by the pod.
+	InClusterServer = "https://kubernetes.default.svc"
+	// KubeconfigKey is the Secret key holding the kubeconfig.
+	KubeconfigKey = "config"
+
+	contextName = "helm-in-pod"
+)
+
+// Credentials are the caller's credentials resolved from the kubeconfig.
+type Credentials struct {
+	Token                 string
+	ClientCertificateData []byte
+	ClientKeyData         []byte
+	Impersonate           string
+	ImpersonateGroups     []string
+	// Expiry is set when the credentials come from an exec plugin that reports it.
+	Expiry time.Time
+}
+
+// Resolve extracts the credentials from cfg. Bearer tokens (inline or file),
+// exec plugins and client certificates (inline or files) are supported.
+func Resolve(cfg *rest.Config) (*Credentials, error) {
+	creds := &Credentials{
+		Impersonate:       cfg.Impersonate.UserName,
+		ImpersonateGroups: cfg.Impersonate.Groups,
+	}
+	switch {
+	case cfg.BearerToken != "":
+		creds.Token = cfg.BearerToken
+	case cfg.BearerTokenFile != "":
+		data, err := os.ReadFile(cfg.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token file: %w", err)
+		}
+		creds.Token = string(bytes.TrimSpace(data))
+	case cfg.ExecProvider != nil:
+		if err := runExecProvider(cfg.ExecProvider, creds); err != nil {
+			return nil, err
+		}
+	case cfg.AuthProvider != nil:
+		token := cfg.AuthProvider.Config["id-token"]
+		if token == "" {
+			return nil, fmt.Errorf("auth provider %q is not supported, only its id-token can be passed through", cfg.AuthProvider.Name)
+		}
+		creds.Token = token
+	case len(cfg.CertData) > 0 || cfg.CertFile != "":
+		certData, err := readData(cfg.CertData, cfg.CertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client certificate: %w", err)
+		}
+		keyData, err := readData(cfg.KeyData, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client key: %w", err)
+		}
+		creds.ClientCertificateData = certData
+		creds.ClientKeyData = keyData
+	default:
+		return nil, fmt.Errorf("no bearer token, exec plugin or client certificate found in kubeconfig")
+	}
+	return creds, nil
+}
+
+func readData(data []byte, file string) ([]byte, error) {
+	if len(data) > 0 {
+		return data, nil
+	}
+	return os.ReadFile(file)
+}
+
+// execCredential is the subset of client.authentication.k8s.io ExecCredential
+// shared by v1beta1 and v1.
+type execCredential struct {
+	Status *struct {
+		Token                 string       `json:"token"`
+		ClientCertificateData string       `json:"clientCertificateData"`
+		ClientKeyData         string       `json:"clientKeyData"`
+		ExpirationTimestamp   *metav1.Time `json:"expirationTimestamp"`
+	} `json:"status"`
+}
+
+func runExecProvider(provider *clientcmdapi.ExecConfig, creds *Credentials) error {
+	execInfo, err := json.Marshal(map[string]any{
+		"apiVersion": provider.APIVersion,
+		"kind":       "ExecCredential",
+		"spec":       map[string]any{"interactive": false},
+	})
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(provider.Command, provider.Args...)
+	cmd.Env = os.Environ()
+	for _, env := range provider.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", env.Name, env.Value))
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("KUBERNETES_EXEC_INFO=%s", execInfo))
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("exec plugin %q failed: %w", provider.Command, err)
+	}
+	cred := execCredential{}
+	if err := json.Unmarshal(out, &cred); err != nil {
+		return fmt.Errorf("failed to parse exec plugin %q output: %w", provider.Command, err)
+	}
+	if cred.Status == nil || (cred.Status.Token == "" && cred.Status.ClientCertificateData == "") {
+		return fmt.Errorf("exec plugin %q returned no credentials", provider.Command)
+	}
+	creds.Token = cred.Status.Token
+	creds.ClientCertificateData = []byte(cred.Status.ClientCertificateData)
+	creds.ClientKeyData = []byte(cred.Status.ClientKeyData)
+	if cred.Status.ExpirationTimestamp != nil {
+		creds.Expiry = cred.Status.ExpirationTimestamp.Time
+	}
+	return nil
+}
+
+// Kubeconfig renders a kubeconfig that talks to the in-cluster API server
+// using the CA file at caPath and the resolved credentials.
+func Kubeconfig(creds *Credentials, caPath string) ([]byte, error) {
+	cfg := clientcmdapi.NewConfig()
+	cfg.Clusters[contextName] = &clientcmdapi.Cluster{
+		Server:               InClusterServer,
+		CertificateAuthority: caPath,
+	}
+	cfg.AuthInfos[contextName] = &clientcmdapi.AuthInfo{
+		Token:                 creds.Token,
+		ClientCertificateData: creds.ClientCertificateData,
+		ClientKeyData:         creds.ClientKeyData,
+		Impersonate:           creds.Impersonate,
+		ImpersonateGroups:     creds.ImpersonateGroups,
+	}
+	cfg.Contexts[contextName] = &clientcmdapi.Context{
+		Cluster:  contextName,
+		AuthInfo: contextName,
+	}
+	cfg.CurrentContext = contextName
+	return clientcmd.Write(*cfg)
+}
@@ -0,0 +1,84 @@
+package hipcreds
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+var _ = Describe("Resolve", func() {
+	It("should use an inline bearer token", func() {
+		creds, err := Resolve(&rest.Config{BearerToken: "abc"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(creds.Token).To(Equal("abc"))
+	})
+
+	It("should read a bearer token file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "token")
+		Expect(os.WriteFile(path, []byte("from-file\n"), 0o600)).To(Succeed())
+		creds, err := Resolve(&rest.Config{BearerTokenFile: path})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(creds.Token).To(Equal("from-file"))
+	})
+
+	It("should use client certificate data", func() {
+		creds, err := Resolve(&rest.Config{TLSClientConfig: rest.TLSClientConfig{CertData: []byte("cert"), KeyData: []byte("key")}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(creds.ClientCertificateData).To(Equal([]byte("cert")))
+		Expect(creds.ClientKeyData).To(Equal([]byte("key")))
+	})
+
+	It("should run an exec plugin", func() {
+		script := filepath.Join(GinkgoT().TempDir(), "plugin.sh")
+		Expect(os.WriteFile(script, []byte(`#!/bin/sh
+echo "{\"apiVersion\":\"client.authentication.k8s.io/v1\",\"kind\":\"ExecCredential\",\"status\":{\"token\":\"$TOKEN\",\"expirationTimestamp\":\"2030-01-01T00:00:00Z\"}}"
+`), 0o700)).To(Succeed())
+		creds, err := Resolve(&rest.Config{ExecProvider: &clientcmdapi.ExecConfig{
+			Command:    script,
+			APIVersion: "client.authentication.k8s.io/v1",
+			Env:        []clientcmdapi.ExecEnvVar{{Name: "TOKEN", Value: "exec-token"}},
+		}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(creds.Token).To(Equal("exec-token"))
+		Expect(creds.Expiry.Year()).To(Equal(2030))
+	})
+
+	It("should fail when the exec plugin returns no credentials", func() {
+		script := filepath.Join(GinkgoT().TempDir(), "plugin.sh")
+		Expect(os.WriteFile(script, []byte("#!/bin/sh\necho '{\"status\":{}}'\n"), 0o700)).To(Succeed())
+		_, err := Resolve(&rest.Config{ExecProvider: &clientcmdapi.ExecConfig{Command: script}})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("returned no credentials"))
+	})
+
+	It("should keep impersonation settings", func() {
+		creds, err := Resolve(&rest.Config{BearerToken: "abc", Impersonate: rest.ImpersonationConfig{UserName: "jane", Groups: []string{"devs"}}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(creds.Impersonate).To(Equal("jane"))
+		Expect(creds.ImpersonateGroups).To(Equal([]string{"devs"}))
+	})
+
+	It("should fail without any supported credentials", func() {
+		_, err := Resolve(&rest.Config{Username: "admin", Password: "secret"})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Kubeconfig", func() {
+	It("should point at the in-cluster API server", func() {
+		data, err := Kubeconfig(&Credentials{Token: "abc"}, "/etc/ca.crt")
+		Expect(err).NotTo(HaveOccurred())
+		cfg, err := clientcmd.Load(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.CurrentContext).NotTo(BeEmpty())
+		ctx := cfg.Contexts[cfg.CurrentContext]
+		Expect(cfg.Clusters[ctx.Cluster].Server).To(Equal(InClusterServer))
+		Expect(cfg.Clusters[ctx.Cluster].CertificateAuthority).To(Equal("/etc/ca.crt"))
+		Expect(cfg.AuthInfos[ctx.AuthInfo].Token).To(Equal("abc"))
+	})
+})
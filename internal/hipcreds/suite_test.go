@@ -0,0 +1,13 @@
+package hipcreds
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestHipcreds(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Hipcreds Suite")
+}
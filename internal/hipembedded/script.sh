@@ -3,7 +3,7 @@
 trapMe() {
   set +e
   TRAP_TIME=0
-  TRAP_END_TIME=$((TRAP_TIME+180))
+  TRAP_END_TIME=$((TRAP_TIME+${HIP_TERMINATE_TIMEOUT:-180}))
   while [ $TRAP_TIME -lt $TRAP_END_TIME ]; do
     #echo "Sending INT and TERM to all processes except PID 1"
     kill -s INT -1 2>/dev/null
@@ -36,24 +36,78 @@ while [ $MY_TIME -lt $END ]; do
   #echo "Waiting ${SCRIPT_PATH}"
   if [ ! -f "${SCRIPT_PATH}" ]; then
     sleep 1
+    MY_TIME=$((MY_TIME+1))
     continue
   fi
   break
 done
 
-#echo "#### EXECUTION STARTED ####"
-"${SCRIPT_PATH}" &
-pid=$!
-set +e
-wait $pid
-CMD_EXIT=$?
-set -e
+# The wrapped script writes the command's result record to RESULT_PATH when it
+# finishes. The record is signed with a nonce only the host knows, so the exit
+# code of the pod is never taken from it
+RESULT_PATH="/tmp/hip-result.json"
+if [ -n "${HIP_ATTACH:-}" ]; then
+  # The command runs in an exec session attached from the host. PID 1 has no
+  # stdin, so --stdin is only possible through that session
+  ATTACH_TIME=0
+  while [ ! -f "${RESULT_PATH}" ]; do
+    if [ $ATTACH_TIME -ge $TIMEOUT ]; then
+      echo "Timed out waiting for the attached command to finish"
+      exit 1
+    fi
+    sleep 1
+    ATTACH_TIME=$((ATTACH_TIME+1))
+  done
+  # The host verifies the record and writes the command's exit code to EXIT_CODE_PATH
+  EXIT_CODE_PATH="/tmp/hip-exit-code"
+  READ_TIME=0
+  while [ ! -f "${EXIT_CODE_PATH}" ] && [ $READ_TIME -lt ${HIP_READ_WAIT:-60} ]; do
+    sleep 1
+    READ_TIME=$((READ_TIME+1))
+  done
+  CMD_EXIT="$(cat "${EXIT_CODE_PATH}" 2>/dev/null || true)"
+else
+  # The output goes to the container log and to OUTPUT_PATH. The host streams
+  # OUTPUT_PATH by byte offset, so it can resume after a broken connection
+  # without losing or repeating lines
+  OUTPUT_PATH="/tmp/hip-output"
+  OUTPUT_FIFO="/tmp/hip-output.fifo"
+  : > "${OUTPUT_PATH}"
+  rm -f "${OUTPUT_FIFO}"
+  mkfifo "${OUTPUT_FIFO}"
+  #echo "#### EXECUTION STARTED ####"
+  tee -a "${OUTPUT_PATH}" < "${OUTPUT_FIFO}" &
+  tee_pid=$!
+  # The output is passed through a FIFO instead of a pipe, so the exit code is
+  # the wrapped script's own, which is the command's
+  "${SCRIPT_PATH}" > "${OUTPUT_FIFO}" 2>&1 &
+  pid=$!
+  set +e
+  wait $pid
+  CMD_EXIT=$?
+  wait $tee_pid
+  set -e
+  touch "${OUTPUT_PATH}.done"
+  # Give the host time to read the rest of the output before the pod exits
+  if [ -z "${HIP_DETACH:-}" ]; then
+    READ_TIME=0
+    while [ ! -f "${OUTPUT_PATH}.read" ] && [ $READ_TIME -lt ${HIP_READ_WAIT:-60} ]; do
+      sleep 1
+      READ_TIME=$((READ_TIME+1))
+    done
+  fi
+fi
+case "${CMD_EXIT}" in
+  ''|*[!0-9]*) CMD_EXIT=1 ;;
+esac
+# Kubernetes keeps the record as the termination message of the container, so
+# the host can verify it after the pod has exited
+{ cat "${RESULT_PATH}" > /dev/termination-log; } 2>/dev/null || true
 
-# If WAIT_COPY_DONE is set, emit exit code marker and wait for host to signal copy completion
+# If WAIT_COPY_DONE is set, wait for host to signal copy completion
 if [ -n "${WAIT_COPY_DONE:-}" ]; then
-  echo "###HIP_EXIT_CODE:${CMD_EXIT}###"
   WAIT_TIME=0
-  WAIT_END=$((WAIT_TIME+300))
+  WAIT_END=$((WAIT_TIME+${HIP_COPY_FROM_WAIT:-300}))
   while [ $WAIT_TIME -lt $WAIT_END ]; do
     if [ -f /tmp/copy-done ]; then
       break
@@ -20,4 +20,35 @@ var _ = Describe("GetShScript", func() {
 		script := GetShScript()
 		Expect(script).To(ContainSubstring("trapMe"))
 	})
+
+	It("should wait for the attached command's result file in attach mode", func() {
+		script := GetShScript()
+		Expect(script).To(ContainSubstring("HIP_ATTACH"))
+		Expect(script).To(ContainSubstring("/tmp/hip-result.json"))
+	})
+
+	It("should not take the exit code from the unverified result file", func() {
+		script := GetShScript()
+		Expect(script).NotTo(ContainSubstring(`"exitCode"`))
+		Expect(script).To(ContainSubstring("/tmp/hip-exit-code"))
+		Expect(script).To(ContainSubstring(`CMD_EXIT=$?`))
+	})
+
+	It("should hand the result file to Kubernetes as the termination message", func() {
+		script := GetShScript()
+		Expect(script).To(ContainSubstring(`cat "${RESULT_PATH}" > /dev/termination-log`))
+	})
+
+	It("should copy the command output to the output file", func() {
+		script := GetShScript()
+		Expect(script).To(ContainSubstring(`tee -a "${OUTPUT_PATH}"`))
+		Expect(script).To(ContainSubstring("/tmp/hip-output"))
+	})
+
+	It("should take the terminate and copy-from timeouts from the environment", func() {
+		script := GetShScript()
+		Expect(script).To(ContainSubstring("${HIP_TERMINATE_TIMEOUT:-180}"))
+		Expect(script).To(ContainSubstring("${HIP_COPY_FROM_WAIT:-300}"))
+		Expect(script).To(ContainSubstring("${HIP_READ_WAIT:-60}"))
+	})
 })
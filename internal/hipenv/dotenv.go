@@ -0,0 +1,116 @@
+package hipenv
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envName matches valid environment variable names.
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// dotenvEscapes are the escape sequences of double-quoted values.
+var dotenvEscapes = strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`, `\$`, `$`)
+
+// IsValidName reports whether name can be exported by a POSIX shell.
+func IsValidName(name string) bool {
+	return envName.MatchString(name)
+}
+
+// ReadDotenvFile reads the variables of the dotenv file at path.
+func ReadDotenvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	env, err := ParseDotenv(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid env file %s: %w", path, err)
+	}
+	return env, nil
+}
+
+// ParseDotenv parses KEY=value lines. Lines may start with "export ", blank
+// lines and lines starting with # are skipped. Unquoted values end at a #
+// preceded by whitespace. Single-quoted values are taken literally, double-quoted
+// values support \n, \r, \t, \", \\ and \$ escapes. Quoted values may span lines.
+// A variable set more than once takes its last value.
+func ParseDotenv(r io.Reader) (map[string]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	env := map[string]string{}
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "export "); ok {
+			line = strings.TrimLeft(rest, " \t")
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY=value", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		if !IsValidName(key) {
+			return nil, fmt.Errorf("line %d: invalid variable name %q", lineNo, key)
+		}
+		trimmed := strings.TrimLeft(value, " \t")
+		if trimmed == "" || (trimmed[0] != '"' && trimmed[0] != '\'') {
+			env[key] = strings.TrimSpace(stripComment(value))
+			continue
+		}
+
+		quote := trimmed[0]
+		rest := trimmed[1:]
+		end := closingQuote(rest, quote)
+		for end < 0 {
+			if i+1 >= len(lines) {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", lineNo)
+			}
+			i++
+			rest += "\n" + lines[i]
+			end = closingQuote(rest, quote)
+		}
+		if tail := strings.TrimSpace(rest[end+1:]); tail != "" && !strings.HasPrefix(tail, "#") {
+			return nil, fmt.Errorf("line %d: unexpected %q after quoted value", lineNo, tail)
+		}
+		value = rest[:end]
+		if quote == '"' {
+			value = dotenvEscapes.Replace(value)
+		}
+		env[key] = value
+	}
+	return env, nil
+}
+
+// stripComment removes an inline comment, a # preceded by whitespace, from an unquoted value.
+func stripComment(value string) string {
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			return value[:i]
+		}
+	}
+	return value
+}
+
+// closingQuote returns the index of the quote ending s, -1 if there is none.
+// Double quotes can be escaped with a backslash.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
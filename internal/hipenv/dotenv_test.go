@@ -0,0 +1,87 @@
+package hipenv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseDotenv", func() {
+	parse := func(content string) (map[string]string, error) {
+		return ParseDotenv(strings.NewReader(content))
+	}
+
+	It("should parse variables, comments and export prefixes", func() {
+		env, err := parse(`# deploy settings
+HELM_DRIVER=sql
+
+export HELM_NAMESPACE=apps
+  SPACED = value with spaces  # trailing comment
+HASH=abc#def
+EMPTY=
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(Equal(map[string]string{
+			"HELM_DRIVER":    "sql",
+			"HELM_NAMESPACE": "apps",
+			"SPACED":         "value with spaces",
+			"HASH":           "abc#def",
+			"EMPTY":          "",
+		}))
+	})
+
+	It("should take single-quoted values literally", func() {
+		env, err := parse(`PASSWORD='p@ss "w" \n # not a comment' # comment`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(HaveKeyWithValue("PASSWORD", `p@ss "w" \n # not a comment`))
+	})
+
+	It("should unescape double-quoted values", func() {
+		env, err := parse(`MESSAGE="line 1\nsaid \"hi\" for \$5\\"`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(HaveKeyWithValue("MESSAGE", "line 1\nsaid \"hi\" for $5\\"))
+	})
+
+	It("should read quoted values spanning lines", func() {
+		env, err := parse("KEY=\"-----BEGIN KEY-----\nabc\n-----END KEY-----\"\nNEXT=1\r\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(Equal(map[string]string{
+			"KEY":  "-----BEGIN KEY-----\nabc\n-----END KEY-----",
+			"NEXT": "1",
+		}))
+	})
+
+	It("should use the last value of a repeated variable", func() {
+		env, err := parse("A=1\nA=2\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(HaveKeyWithValue("A", "2"))
+	})
+
+	DescribeTable("should reject invalid lines",
+		func(content, message string) {
+			_, err := parse(content)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("no equal sign", "A=1\nJUST_A_NAME\n", "line 2: expected KEY=value"),
+		Entry("invalid name", "1ABC=x", `line 1: invalid variable name "1ABC"`),
+		Entry("unterminated quote", "A=\"abc\nB=1\n", "line 1: unterminated quoted value"),
+		Entry("text after quote", `A="abc" def`, `line 1: unexpected "def" after quoted value`),
+	)
+})
+
+var _ = Describe("ReadDotenvFile", func() {
+	It("should name the file in errors", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "prod.env")
+		Expect(os.WriteFile(path, []byte("broken"), 0o600)).To(Succeed())
+		_, err := ReadDotenvFile(path)
+		Expect(err).To(MatchError(ContainSubstring("invalid env file " + path + ": line 1")))
+	})
+
+	It("should fail for a missing file", func() {
+		_, err := ReadDotenvFile(filepath.Join(GinkgoT().TempDir(), "missing.env"))
+		Expect(err).To(MatchError(ContainSubstring("failed to read env file")))
+	})
+})
@@ -0,0 +1,83 @@
+package hipenv
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Forwarded is a host environment variable forwarded to the pod.
+type Forwarded struct {
+	// Name is the name of the variable in the pod.
+	Name string
+	// HostName is the name of the variable on the host, different from Name if it was renamed.
+	HostName string
+	Value    string
+}
+
+// Forward selects the variables of environ, given as KEY=value like os.Environ,
+// that specs forward to the pod. A spec is a name, a glob pattern such as HELM_*
+// or a HOST_NAME=POD_NAME rename. Variables matched by a pattern are skipped if
+// they match one of the excludes patterns. Names and renames are forwarded even
+// if they are not set, with an empty value. The result is sorted by Name, and a
+// name selected more than once takes the value of the last spec.
+func Forward(specs, excludes, environ []string) ([]Forwarded, error) {
+	for _, exclude := range excludes {
+		if _, err := path.Match(exclude, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", exclude, err)
+		}
+	}
+	hostEnv := map[string]string{}
+	var hostNames []string
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if _, seen := hostEnv[name]; !seen {
+			hostNames = append(hostNames, name)
+		}
+		hostEnv[name] = value
+	}
+	slices.Sort(hostNames)
+
+	selected := map[string]Forwarded{}
+	for _, spec := range specs {
+		if hostName, podName, ok := strings.Cut(spec, "="); ok {
+			if !IsValidName(hostName) || !IsValidName(podName) {
+				return nil, fmt.Errorf("invalid rename %q, expected HOST_NAME=POD_NAME", spec)
+			}
+			selected[podName] = Forwarded{Name: podName, HostName: hostName, Value: hostEnv[hostName]}
+			continue
+		}
+		if !strings.ContainsAny(spec, "*?[") {
+			if !IsValidName(spec) {
+				return nil, fmt.Errorf("invalid variable name %q", spec)
+			}
+			selected[spec] = Forwarded{Name: spec, HostName: spec, Value: hostEnv[spec]}
+			continue
+		}
+		if _, err := path.Match(spec, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", spec, err)
+		}
+		for _, name := range hostNames {
+			if matched, _ := path.Match(spec, name); !matched || !IsValidName(name) || matchesAny(excludes, name) {
+				continue
+			}
+			selected[name] = Forwarded{Name: name, HostName: name, Value: hostEnv[name]}
+		}
+	}
+
+	forwarded := make([]Forwarded, 0, len(selected))
+	for _, f := range selected {
+		forwarded = append(forwarded, f)
+	}
+	slices.SortFunc(forwarded, func(a, b Forwarded) int { return cmp.Compare(a.Name, b.Name) })
+	return forwarded, nil
+}
+
+func matchesAny(patterns []string, name string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	})
+}
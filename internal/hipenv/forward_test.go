@@ -0,0 +1,70 @@
+package hipenv
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Forward", func() {
+	environ := []string{
+		"HELM_DRIVER=sql",
+		"HELM_NAMESPACE=apps",
+		"CI_JOB_ID=42",
+		"CI_JOB_TOKEN=hunter22",
+		"CI_COMMIT_SHA=abc",
+		"AWS_REGION=eu-west-1",
+		"HOME=/home/ci",
+		"PATH=/usr/bin",
+		"EQUALS=a=b",
+	}
+
+	It("should forward names, patterns and renames sorted by name", func() {
+		forwarded, err := Forward([]string{"HOME", "HELM_*", "AWS_REGION=REGION"}, nil, environ)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(forwarded).To(Equal([]Forwarded{
+			{Name: "HELM_DRIVER", HostName: "HELM_DRIVER", Value: "sql"},
+			{Name: "HELM_NAMESPACE", HostName: "HELM_NAMESPACE", Value: "apps"},
+			{Name: "HOME", HostName: "HOME", Value: "/home/ci"},
+			{Name: "REGION", HostName: "AWS_REGION", Value: "eu-west-1"},
+		}))
+	})
+
+	It("should skip pattern matches that are excluded", func() {
+		forwarded, err := Forward([]string{"CI_*"}, []string{"*_TOKEN", "CI_COMMIT_*"}, environ)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(forwarded).To(Equal([]Forwarded{{Name: "CI_JOB_ID", HostName: "CI_JOB_ID", Value: "42"}}))
+	})
+
+	It("should forward names even if they are excluded or unset", func() {
+		forwarded, err := Forward([]string{"CI_JOB_TOKEN", "UNSET"}, []string{"*_TOKEN"}, environ)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(forwarded).To(Equal([]Forwarded{
+			{Name: "CI_JOB_TOKEN", HostName: "CI_JOB_TOKEN", Value: "hunter22"},
+			{Name: "UNSET", HostName: "UNSET", Value: ""},
+		}))
+	})
+
+	It("should keep values containing equal signs", func() {
+		forwarded, err := Forward([]string{"EQUALS"}, nil, environ)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(forwarded[0].Value).To(Equal("a=b"))
+	})
+
+	It("should let the last spec win for the same name", func() {
+		forwarded, err := Forward([]string{"HELM_*", "AWS_REGION=HELM_DRIVER"}, nil, environ)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(forwarded).To(ContainElement(Forwarded{Name: "HELM_DRIVER", HostName: "AWS_REGION", Value: "eu-west-1"}))
+	})
+
+	DescribeTable("should reject invalid specs",
+		func(specs, excludes []string, message string) {
+			_, err := Forward(specs, excludes, environ)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("bad pattern", []string{"HELM_[*"}, nil, `invalid pattern "HELM_[*"`),
+		Entry("bad exclude", []string{"HELM_*"}, []string{"["}, `invalid exclude pattern "["`),
+		Entry("rename with pattern", []string{"CI_*=X"}, nil, `invalid rename "CI_*=X"`),
+		Entry("rename without target", []string{"HOME="}, nil, `invalid rename "HOME="`),
+		Entry("invalid name", []string{"MY-VAR"}, nil, `invalid variable name "MY-VAR"`),
+	)
+})
@@ -0,0 +1,13 @@
+package hipenv
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestHipenv(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Hipenv Suite")
+}
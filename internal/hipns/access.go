@@ -0,0 +1,25 @@
+package hipns
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipaccess"
+)
+
+// CheckAccess reviews every permission PrepareNs and pod creation need for opts.
+// Nothing is created in the cluster.
+func (m *Manager) CheckAccess(opts cmdoptions.ExecOptions) ([]hipaccess.Result, error) {
+	cs := m.client().ClientSet()
+	_, err := cs.CoreV1().Namespaces().Get(m.ctx, m.namespace, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsForbidden(err) {
+		return nil, fmt.Errorf("failed to get namespace %s: %w", m.namespace, err)
+	}
+	// A forbidden get is reported by the namespaces check, and creating the
+	// namespace is checked too, as it may not exist
+	namespaceExists := err == nil
+	return hipaccess.Run(m.ctx, cs, hipaccess.Checks(m.namespace, namespaceExists, opts))
+}
@@ -2,73 +2,109 @@ package hipns
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/Noksa/operator-home/pkg/operatorkclient"
 	v1 "k8s.io/api/core/v1"
-	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+	"github.com/noksa/helm-in-pod/internal/hiptrace"
 	"github.com/noksa/helm-in-pod/internal/logz"
 )
 
-const Name = "helm-in-pod"
-
 type Manager struct {
-	ctx context.Context
+	ctx       context.Context
+	namespace string
+	kclient   *operatorkclient.Client
+	log       *logz.Loggers
+}
+
+// ManagerOption customizes a Manager.
+type ManagerOption func(*Manager)
+
+// WithClient makes the manager use c instead of the default client, e.g. for another kube context.
+func WithClient(c *operatorkclient.Client) ManagerOption {
+	return func(m *Manager) {
+		m.kclient = c
+	}
+}
+
+// WithLoggers replaces the default loggers.
+func WithLoggers(l *logz.Loggers) ManagerOption {
+	return func(m *Manager) {
+		m.log = l
+	}
 }
 
-func NewManager(ctx context.Context) *Manager {
-	return &Manager{ctx: ctx}
+func NewManager(ctx context.Context, namespace string, opts ...ManagerOption) *Manager {
+	m := &Manager{ctx: ctx, namespace: namespace, log: logz.Default()}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *Manager) client() *operatorkclient.Client {
+	if m.kclient != nil {
+		return m.kclient
+	}
+	return operatorkclient.DefaultClient()
 }
 
-func (m *Manager) PrepareNs() error {
-	cs := operatorkclient.DefaultClient().ClientSet()
-	ns, err := cs.CoreV1().Namespaces().Get(m.ctx, Name, metav1.GetOptions{})
+// ClusterRoleBindingName returns the name of the ClusterRoleBinding for the namespace.
+// ClusterRoleBindings are cluster-scoped, so every non-default namespace gets its own
+// binding to avoid teams sharing a cluster from overwriting each other's.
+func ClusterRoleBindingName(namespace string) string {
+	if namespace == hipconsts.HelmInPodNamespace {
+		return hipconsts.HelmInPodName
+	}
+	return fmt.Sprintf("%s-%s", hipconsts.HelmInPodName, namespace)
+}
+
+// PrepareNs creates the plugin namespace, the plugin ServiceAccount unless a custom
+// one is used and, depending on the RBAC mode, its bindings.
+func (m *Manager) PrepareNs(opts cmdoptions.ExecOptions) (err error) {
+	_, span := hiptrace.Start(m.ctx, "PrepareNs", hiptrace.Namespace(m.namespace))
+	defer func() { hiptrace.End(span, err) }()
+	cs := m.client().ClientSet()
+	ns, err := cs.CoreV1().Namespaces().Get(m.ctx, m.namespace, metav1.GetOptions{})
 	if client.IgnoreNotFound(err) != nil {
 		return err
 	}
 	if ns == nil || ns.Name == "" {
-		logz.Host().Debug().Msgf("Creating '%v' ns", Name)
+		m.log.Host().Debug().Msgf("Creating '%v' ns", m.namespace)
 		_, err = cs.CoreV1().Namespaces().Create(m.ctx, &v1.Namespace{
-			ObjectMeta: metav1.ObjectMeta{Name: Name},
+			ObjectMeta: metav1.ObjectMeta{Name: m.namespace},
 		}, metav1.CreateOptions{})
 		if err != nil && client.IgnoreAlreadyExists(err) != nil {
 			return err
 		}
 	}
-	sa, err := cs.CoreV1().ServiceAccounts(Name).Get(m.ctx, Name, metav1.GetOptions{})
-	if client.IgnoreNotFound(err) != nil {
-		return err
-	}
-	if sa == nil || sa.Name == "" {
-		logz.Host().Debug().Msgf("Creating '%v' serviceaccount in '%v' ns", Name, Name)
-		_, err = cs.CoreV1().ServiceAccounts(Name).Create(m.ctx, &v1.ServiceAccount{
-			ObjectMeta: metav1.ObjectMeta{Name: Name},
-		}, metav1.CreateOptions{})
-		if err != nil && client.IgnoreAlreadyExists(err) != nil {
+	if opts.ServiceAccount == "" {
+		if err := m.createServiceAccount(); err != nil {
 			return err
 		}
 	}
-	return m.CreateClusterRoleBinding()
+	if opts.RBACMode == hipconsts.RBACModeNone {
+		m.log.Host().Debug().Msg("Skipping RBAC creation")
+		return nil
+	}
+	return m.CreateRBAC(opts)
 }
 
-func (m *Manager) CreateClusterRoleBinding() error {
-	cs := operatorkclient.DefaultClient().ClientSet()
-	crb, err := cs.RbacV1().ClusterRoleBindings().Get(m.ctx, Name, metav1.GetOptions{})
+func (m *Manager) createServiceAccount() error {
+	cs := m.client().ClientSet()
+	sa, err := cs.CoreV1().ServiceAccounts(m.namespace).Get(m.ctx, hipconsts.HelmInPodName, metav1.GetOptions{})
 	if client.IgnoreNotFound(err) != nil {
 		return err
 	}
-	if crb == nil || crb.Name == "" {
-		logz.Host().Debug().Msgf("Creating '%v' clusterrolebinding in '%v' ns", Name, Name)
-		_, err = cs.RbacV1().ClusterRoleBindings().Create(m.ctx, &rbacv1.ClusterRoleBinding{
-			ObjectMeta: metav1.ObjectMeta{Name: Name},
-			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: Name, Namespace: Name}},
-			RoleRef: rbacv1.RoleRef{
-				APIGroup: "rbac.authorization.k8s.io",
-				Kind:     "ClusterRole",
-				Name:     "cluster-admin",
-			},
+	if sa == nil || sa.Name == "" {
+		m.log.Host().Debug().Msgf("Creating '%v' serviceaccount in '%v' ns", hipconsts.HelmInPodName, m.namespace)
+		_, err = cs.CoreV1().ServiceAccounts(m.namespace).Create(m.ctx, &v1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: hipconsts.HelmInPodName},
 		}, metav1.CreateOptions{})
 		if err != nil && client.IgnoreAlreadyExists(err) != nil {
 			return err
@@ -76,17 +112,3 @@ func (m *Manager) CreateClusterRoleBinding() error {
 	}
 	return nil
 }
-
-func (m *Manager) DeleteClusterRoleBinding() error {
-	cs := operatorkclient.DefaultClient().ClientSet()
-	crb, err := cs.RbacV1().ClusterRoleBindings().Get(m.ctx, Name, metav1.GetOptions{})
-	if client.IgnoreNotFound(err) != nil {
-		return err
-	}
-	if crb != nil && crb.Name != "" {
-		logz.Host().Debug().Msgf("Removing '%v' clusterrolebinding in '%v' ns", Name, Name)
-		err = cs.RbacV1().ClusterRoleBindings().Delete(m.ctx, Name, metav1.DeleteOptions{})
-		return err
-	}
-	return nil
-}
@@ -0,0 +1,167 @@
+package hipns
+
+import (
+	"errors"
+	"fmt"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+)
+
+// BindingName returns the name of the (Cluster)RoleBinding created for the service account.
+// The default service account keeps the legacy ClusterRoleBinding name.
+func BindingName(namespace, serviceAccount string) string {
+	name := ClusterRoleBindingName(namespace)
+	if serviceAccount != "" && serviceAccount != hipconsts.HelmInPodName {
+		name = fmt.Sprintf("%s-%s", name, serviceAccount)
+	}
+	return name
+}
+
+func (m *Manager) rbacLabels() map[string]string {
+	return map[string]string{
+		hipconsts.LabelManagedBy:       hipconsts.HelmInPodName,
+		hipconsts.LabelPluginNamespace: m.namespace,
+	}
+}
+
+func (m *Manager) rbacSubjectsAndRole(opts cmdoptions.ExecOptions) ([]rbacv1.Subject, rbacv1.RoleRef) {
+	serviceAccount := hipconsts.HelmInPodName
+	if opts.ServiceAccount != "" {
+		serviceAccount = opts.ServiceAccount
+	}
+	clusterRole := opts.RBACClusterRole
+	if clusterRole == "" {
+		clusterRole = hipconsts.DefaultRBACClusterRole
+	}
+	subjects := []rbacv1.Subject{{Kind: "ServiceAccount", Name: serviceAccount, Namespace: m.namespace}}
+	roleRef := rbacv1.RoleRef{
+		APIGroup: "rbac.authorization.k8s.io",
+		Kind:     "ClusterRole",
+		Name:     clusterRole,
+	}
+	return subjects, roleRef
+}
+
+// CreateRBAC binds the plugin service account according to the RBAC mode:
+// a ClusterRoleBinding in cluster mode or RoleBindings in each target namespace in namespaced mode.
+func (m *Manager) CreateRBAC(opts cmdoptions.ExecOptions) error {
+	switch opts.RBACMode {
+	case hipconsts.RBACModeNone:
+		return nil
+	case hipconsts.RBACModeNamespaced:
+		for _, ns := range opts.RBACNamespaces {
+			if err := m.createRoleBinding(ns, opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return m.createClusterRoleBinding(opts)
+	}
+}
+
+func (m *Manager) createClusterRoleBinding(opts cmdoptions.ExecOptions) error {
+	cs := m.client().ClientSet()
+	name := BindingName(m.namespace, opts.ServiceAccount)
+	subjects, roleRef := m.rbacSubjectsAndRole(opts)
+	crb, err := cs.RbacV1().ClusterRoleBindings().Get(m.ctx, name, metav1.GetOptions{})
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if crb != nil && crb.Name != "" {
+		if equality.Semantic.DeepEqual(crb.RoleRef, roleRef) && equality.Semantic.DeepEqual(crb.Subjects, subjects) {
+			return nil
+		}
+		// roleRef is immutable, so the binding has to be recreated
+		m.log.Host().Debug().Msgf("Recreating '%v' clusterrolebinding to bind '%v' clusterrole", name, roleRef.Name)
+		err = cs.RbacV1().ClusterRoleBindings().Delete(m.ctx, name, metav1.DeleteOptions{})
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	m.log.Host().Debug().Msgf("Creating '%v' clusterrolebinding for '%v' ns with '%v' clusterrole", name, m.namespace, roleRef.Name)
+	_, err = cs.RbacV1().ClusterRoleBindings().Create(m.ctx, &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: m.rbacLabels()},
+		Subjects:   subjects,
+		RoleRef:    roleRef,
+	}, metav1.CreateOptions{})
+	if err != nil && client.IgnoreAlreadyExists(err) != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Manager) createRoleBinding(namespace string, opts cmdoptions.ExecOptions) error {
+	cs := m.client().ClientSet()
+	name := BindingName(m.namespace, opts.ServiceAccount)
+	subjects, roleRef := m.rbacSubjectsAndRole(opts)
+	rb, err := cs.RbacV1().RoleBindings(namespace).Get(m.ctx, name, metav1.GetOptions{})
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if rb != nil && rb.Name != "" {
+		if equality.Semantic.DeepEqual(rb.RoleRef, roleRef) && equality.Semantic.DeepEqual(rb.Subjects, subjects) {
+			return nil
+		}
+		m.log.Host().Debug().Msgf("Recreating '%v' rolebinding in '%v' ns to bind '%v' clusterrole", name, namespace, roleRef.Name)
+		err = cs.RbacV1().RoleBindings(namespace).Delete(m.ctx, name, metav1.DeleteOptions{})
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	m.log.Host().Debug().Msgf("Creating '%v' rolebinding in '%v' ns with '%v' clusterrole", name, namespace, roleRef.Name)
+	_, err = cs.RbacV1().RoleBindings(namespace).Create(m.ctx, &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: m.rbacLabels()},
+		Subjects:   subjects,
+		RoleRef:    roleRef,
+	}, metav1.CreateOptions{})
+	if err != nil && client.IgnoreAlreadyExists(err) != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteRBAC removes ClusterRoleBindings and RoleBindings created for the plugin namespace
+// in any RBAC mode, including the legacy unlabeled ClusterRoleBinding.
+func (m *Manager) DeleteRBAC() error {
+	cs := m.client().ClientSet()
+	selector := labels.SelectorFromSet(m.rbacLabels()).String()
+	var errs []error
+
+	legacyName := ClusterRoleBindingName(m.namespace)
+	crb, err := cs.RbacV1().ClusterRoleBindings().Get(m.ctx, legacyName, metav1.GetOptions{})
+	if client.IgnoreNotFound(err) != nil {
+		errs = append(errs, err)
+	} else if crb != nil && crb.Name != "" && crb.Labels[hipconsts.LabelManagedBy] == "" {
+		m.log.Host().Debug().Msgf("Removing '%v' clusterrolebinding for '%v' ns", legacyName, m.namespace)
+		errs = append(errs, client.IgnoreNotFound(cs.RbacV1().ClusterRoleBindings().Delete(m.ctx, legacyName, metav1.DeleteOptions{})))
+	}
+
+	crbs, err := cs.RbacV1().ClusterRoleBindings().List(m.ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, item := range crbs.Items {
+			m.log.Host().Debug().Msgf("Removing '%v' clusterrolebinding for '%v' ns", item.Name, m.namespace)
+			errs = append(errs, client.IgnoreNotFound(cs.RbacV1().ClusterRoleBindings().Delete(m.ctx, item.Name, metav1.DeleteOptions{})))
+		}
+	}
+
+	rbs, err := cs.RbacV1().RoleBindings(metav1.NamespaceAll).List(m.ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, item := range rbs.Items {
+			m.log.Host().Debug().Msgf("Removing '%v' rolebinding in '%v' ns", item.Name, item.Namespace)
+			errs = append(errs, client.IgnoreNotFound(cs.RbacV1().RoleBindings(item.Namespace).Delete(m.ctx, item.Name, metav1.DeleteOptions{})))
+		}
+	}
+	return errors.Join(errs...)
+}
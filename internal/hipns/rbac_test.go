@@ -0,0 +1,57 @@
+package hipns
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+)
+
+var _ = Describe("RBAC", func() {
+	Describe("BindingName", func() {
+		It("should keep the legacy name for the default namespace and service account", func() {
+			Expect(BindingName(hipconsts.HelmInPodNamespace, "")).To(Equal("helm-in-pod"))
+			Expect(BindingName(hipconsts.HelmInPodNamespace, hipconsts.HelmInPodName)).To(Equal("helm-in-pod"))
+		})
+
+		It("should include a non-default namespace", func() {
+			Expect(BindingName("team-a", "")).To(Equal("helm-in-pod-team-a"))
+		})
+
+		It("should include a custom service account", func() {
+			Expect(BindingName("team-a", "deployer")).To(Equal("helm-in-pod-team-a-deployer"))
+		})
+	})
+
+	Describe("rbacSubjectsAndRole", func() {
+		var m *Manager
+
+		BeforeEach(func() {
+			m = &Manager{namespace: "team-a"}
+		})
+
+		It("should bind the default service account to cluster-admin", func() {
+			subjects, roleRef := m.rbacSubjectsAndRole(cmdoptions.ExecOptions{})
+			Expect(subjects).To(HaveLen(1))
+			Expect(subjects[0].Name).To(Equal(hipconsts.HelmInPodName))
+			Expect(subjects[0].Namespace).To(Equal("team-a"))
+			Expect(roleRef.Kind).To(Equal("ClusterRole"))
+			Expect(roleRef.Name).To(Equal(hipconsts.DefaultRBACClusterRole))
+		})
+
+		It("should bind a custom service account to the requested cluster role", func() {
+			subjects, roleRef := m.rbacSubjectsAndRole(cmdoptions.ExecOptions{ServiceAccount: "deployer", RBACClusterRole: "edit"})
+			Expect(subjects[0].Name).To(Equal("deployer"))
+			Expect(roleRef.Name).To(Equal("edit"))
+		})
+	})
+
+	Describe("rbacLabels", func() {
+		It("should label objects with the plugin namespace", func() {
+			m := &Manager{namespace: "team-a"}
+			Expect(m.rbacLabels()).To(HaveKeyWithValue(hipconsts.LabelManagedBy, hipconsts.HelmInPodName))
+			Expect(m.rbacLabels()).To(HaveKeyWithValue(hipconsts.LabelPluginNamespace, "team-a"))
+		})
+	})
+})
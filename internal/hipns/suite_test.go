@@ -0,0 +1,13 @@
+package hipns
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestHipns(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Hipns Suite")
+}
@@ -0,0 +1,144 @@
+package hippod
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/noksa/go-helpers/helpers/gopointer"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+	"github.com/noksa/helm-in-pod/internal/hipcreds"
+)
+
+const (
+	credentialsSecretPurpose = "creds"
+	credentialsVolumeName    = "hip-kubeconfig"
+	credentialsMountPath     = "/etc/helm-in-pod/kubeconfig"
+	// rootCAConfigMap is published by kube-controller-manager in every namespace.
+	rootCAConfigMap = "kube-root-ca.crt"
+	rootCAKey       = "ca.crt"
+)
+
+// applyCredentialsPassthrough mounts the kubeconfig Secret together with the cluster CA,
+// points KUBECONFIG at it and disables the service account token, so every API call
+// from the pod is made with the caller's identity.
+func applyCredentialsPassthrough(podSpec *corev1.PodSpec, secretName string) {
+	podSpec.AutomountServiceAccountToken = gopointer.NewOf(false)
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: credentialsVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{Secret: &corev1.SecretProjection{
+						LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						Items:                []corev1.KeyToPath{{Key: hipcreds.KubeconfigKey, Path: hipcreds.KubeconfigKey}},
+					}},
+					{ConfigMap: &corev1.ConfigMapProjection{
+						LocalObjectReference: corev1.LocalObjectReference{Name: rootCAConfigMap},
+						Items:                []corev1.KeyToPath{{Key: rootCAKey, Path: rootCAKey}},
+					}},
+				},
+			},
+		},
+	})
+	container := &podSpec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      credentialsVolumeName,
+		MountPath: credentialsMountPath,
+		ReadOnly:  true,
+	})
+	container.Env = append(container.Env, corev1.EnvVar{
+		Name:  "KUBECONFIG",
+		Value: path.Join(credentialsMountPath, hipcreds.KubeconfigKey),
+	})
+}
+
+// createCredentialsSecret resolves the caller's credentials from the loaded kubeconfig
+// and stores an in-cluster kubeconfig with them in a per-operation Secret.
+func (m *Manager) createCredentialsSecret(ctx context.Context) (*corev1.Secret, error) {
+	if m.restConfig == nil {
+		return nil, fmt.Errorf("kubeconfig is not loaded")
+	}
+	creds, err := hipcreds.Resolve(m.restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve credentials for passthrough: %w", err)
+	}
+	if !creds.Expiry.IsZero() {
+		m.log.Host().Debug().Msgf("Passed through credentials expire at %v", color.CyanString(creds.Expiry.Local().String()))
+	}
+	kubeconfig, err := hipcreds.Kubeconfig(creds, path.Join(credentialsMountPath, rootCAKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build kubeconfig for passthrough: %w", err)
+	}
+	return m.CreateOperationSecret(ctx, operationSecretName(credentialsSecretPurpose, m.invocationID), map[string][]byte{
+		hipcreds.KubeconfigKey: kubeconfig,
+	})
+}
+
+// podReferencesOperationSecrets reports whether the pod uses a per-operation
+// Secret, so cleanup only touches Secrets when there may be any.
+func podReferencesOperationSecrets(pod *corev1.Pod) bool {
+	operationID := pod.Labels[hipconsts.LabelOperationID]
+	if operationID == "" {
+		return false
+	}
+	isOperationSecret := func(name string) bool {
+		return strings.HasPrefix(name, hipconsts.HelmInPodName+"-") && strings.HasSuffix(name, "-"+operationID)
+	}
+	for _, v := range pod.Spec.Volumes {
+		if v.Secret != nil && isOperationSecret(v.Secret.SecretName) {
+			return true
+		}
+		if v.Projected != nil {
+			for _, s := range v.Projected.Sources {
+				if s.Secret != nil && isOperationSecret(s.Secret.Name) {
+					return true
+				}
+			}
+		}
+	}
+	for _, c := range pod.Spec.Containers {
+		for _, e := range c.Env {
+			if e.ValueFrom != nil && e.ValueFrom.SecretKeyRef != nil && isOperationSecret(e.ValueFrom.SecretKeyRef.Name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// prepareCredentialsPassthrough creates the kubeconfig Secret and wires it into
+// podSpec when credential passthrough is enabled. It returns nil otherwise.
+func (m *Manager) prepareCredentialsPassthrough(opts cmdoptions.ExecOptions, podSpec *corev1.PodSpec) (*corev1.Secret, error) {
+	if !opts.PassthroughCredentials {
+		return nil, nil
+	}
+	secret, err := m.createCredentialsSecret(m.ctx)
+	if err != nil {
+		return nil, err
+	}
+	applyCredentialsPassthrough(podSpec, secret.Name)
+	return secret, nil
+}
+
+// cleanupOrphanedSecrets deletes the Secrets whose pod could not be created.
+// Nil secrets are ignored.
+func (m *Manager) cleanupOrphanedSecrets(secrets ...*corev1.Secret) {
+	var names []string
+	for _, secret := range secrets {
+		if secret != nil {
+			names = append(names, secret.Name)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	if err := m.DeleteOperationSecrets(m.ctx, m.invocationID); err != nil {
+		m.log.Host().Warn().Msgf("Failed to delete '%v' secrets: %v", strings.Join(names, "', '"), err)
+	}
+}
@@ -0,0 +1,65 @@
+package hippod
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+)
+
+var _ = Describe("Credential passthrough", func() {
+	Describe("applyCredentialsPassthrough", func() {
+		It("should mount the kubeconfig and disable the service account token", func() {
+			spec, err := buildPodSpec(cmdoptions.ExecOptions{Image: "test:latest"}, false)
+			Expect(err).NotTo(HaveOccurred())
+			applyCredentialsPassthrough(&spec, "helm-in-pod-creds-abc")
+
+			Expect(*spec.AutomountServiceAccountToken).To(BeFalse())
+			Expect(spec.Volumes).To(ContainElement(HaveField("Name", credentialsVolumeName)))
+			for _, v := range spec.Volumes {
+				if v.Name == credentialsVolumeName {
+					Expect(v.Projected.Sources).To(HaveLen(2))
+					Expect(v.Projected.Sources[0].Secret.Name).To(Equal("helm-in-pod-creds-abc"))
+					Expect(v.Projected.Sources[1].ConfigMap.Name).To(Equal(rootCAConfigMap))
+				}
+			}
+			Expect(spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name: credentialsVolumeName, MountPath: credentialsMountPath, ReadOnly: true,
+			}))
+			Expect(spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+				Name: "KUBECONFIG", Value: credentialsMountPath + "/config",
+			}))
+		})
+	})
+
+	Describe("podReferencesOperationSecrets", func() {
+		newPod := func(operationID string) *corev1.Pod {
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{hipconsts.LabelOperationID: operationID}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: hipconsts.HelmInPodName}}},
+			}
+		}
+
+		It("should detect the credentials secret", func() {
+			pod := newPod("abc")
+			applyCredentialsPassthrough(&pod.Spec, operationSecretName(credentialsSecretPurpose, "abc"))
+			Expect(podReferencesOperationSecrets(pod)).To(BeTrue())
+		})
+
+		It("should ignore user secrets", func() {
+			pod := newPod("abc")
+			pod.Spec.Volumes = []corev1.Volume{{Name: "creds", VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: "my-secret"},
+			}}}
+			Expect(podReferencesOperationSecrets(pod)).To(BeFalse())
+		})
+
+		It("should ignore pods without operation ID", func() {
+			pod := newPod("")
+			Expect(podReferencesOperationSecrets(pod)).To(BeFalse())
+		})
+	})
+})
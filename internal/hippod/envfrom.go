@@ -0,0 +1,85 @@
+package hippod
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/fatih/color"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/noksa/helm-in-pod/internal/hipenv"
+	"github.com/noksa/helm-in-pod/internal/hipredact"
+)
+
+// resolveEnvFrom reads the variables of the --env-from sources the way the
+// kubelet does for envFrom: later sources override earlier ones. Keys that
+// can't be exported by the shell are skipped. Variables read from Secrets are
+// returned in secretEnv, so they can be kept out of the pod.
+func (m *Manager) resolveEnvFrom(ctx context.Context, sources []string) (env, secretEnv map[string]string, err error) {
+	env, secretEnv = map[string]string{}, map[string]string{}
+	for _, s := range sources {
+		source, err := parseEnvFrom(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid --env-from %q: %w", s, err)
+		}
+		values, err := m.envFromValues(ctx, source)
+		if err != nil {
+			return nil, nil, err
+		}
+		isSecret := source.SecretRef != nil
+		for key, value := range values {
+			name := source.Prefix + key
+			if !hipenv.IsValidName(name) {
+				m.log.Host().Debug().Msgf("Skipping %v from %v, it is not a valid variable name", color.CyanString(name), s)
+				continue
+			}
+			if isSecret {
+				hipredact.AddValues(value)
+				secretEnv[name] = value
+				delete(env, name)
+			} else {
+				env[name] = value
+				delete(secretEnv, name)
+			}
+		}
+	}
+	return env, secretEnv, nil
+}
+
+// checkEnvFrom fails if an --env-from source doesn't exist, so a misspelled
+// name is reported before the pod is created instead of the pod failing to start.
+func (m *Manager) checkEnvFrom(ctx context.Context, sources []string) error {
+	for _, s := range sources {
+		source, err := parseEnvFrom(s)
+		if err != nil {
+			return fmt.Errorf("invalid --env-from %q: %w", s, err)
+		}
+		if _, err := m.envFromValues(ctx, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// envFromValues reads the keys and values of the Secret or ConfigMap of source.
+func (m *Manager) envFromValues(ctx context.Context, source corev1.EnvFromSource) (map[string]string, error) {
+	values := map[string]string{}
+	if source.SecretRef != nil {
+		secret, err := m.client().ClientSet().CoreV1().Secrets(m.namespace).Get(ctx, source.SecretRef.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get '%v' secret: %w", source.SecretRef.Name, err)
+		}
+		for key, value := range secret.Data {
+			values[key] = string(value)
+		}
+		return values, nil
+	}
+	configMap, err := m.client().ClientSet().CoreV1().ConfigMaps(m.namespace).Get(ctx, source.ConfigMapRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get '%v' configmap: %w", source.ConfigMapRef.Name, err)
+	}
+	maps.Copy(values, configMap.Data)
+	return values, nil
+}
@@ -0,0 +1,106 @@
+package hippod
+
+import (
+	"context"
+
+	"github.com/Noksa/operator-home/pkg/operatorkclient"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipredact"
+)
+
+var _ = Describe("parseEnvFrom", func() {
+	It("should parse a secret source", func() {
+		source, err := parseEnvFrom("secret:deploy-creds")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(source.SecretRef.Name).To(Equal("deploy-creds"))
+		Expect(source.ConfigMapRef).To(BeNil())
+		Expect(source.Prefix).To(BeEmpty())
+	})
+
+	It("should parse a configmap source with a prefix", func() {
+		source, err := parseEnvFrom("ConfigMap:settings:APP_")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(source.ConfigMapRef.Name).To(Equal("settings"))
+		Expect(source.Prefix).To(Equal("APP_"))
+	})
+
+	DescribeTable("should reject invalid sources",
+		func(s, message string) {
+			_, err := parseEnvFrom(s)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("no name", "secret", "expected format"),
+		Entry("empty name", "secret:", "expected format"),
+		Entry("unsupported type", "pvc:data", `unsupported source type "pvc"`),
+	)
+
+	It("should become envFrom of the pod but not of the daemon pod", func() {
+		opts := cmdoptions.ExecOptions{EnvFrom: []string{"secret:deploy-creds", "configmap:settings:APP_"}}
+		podSpec, err := buildPodSpec(opts, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(podSpec.Containers[0].EnvFrom).To(HaveLen(2))
+
+		daemonSpec, err := buildDaemonPodSpec(opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(daemonSpec.Containers[0].EnvFrom).To(BeEmpty())
+	})
+
+	It("should fail to build the pod spec with an invalid source", func() {
+		_, err := buildPodSpec(cmdoptions.ExecOptions{EnvFrom: []string{"nfs:x"}}, false)
+		Expect(err).To(MatchError(ContainSubstring(`invalid --env-from "nfs:x"`)))
+	})
+})
+
+var _ = Describe("resolveEnvFrom", func() {
+	var m *Manager
+
+	BeforeEach(func() {
+		cs := fake.NewSimpleClientset(
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "deploy-creds", Namespace: "helm-in-pod"},
+				Data:       map[string][]byte{"DB_PASSWORD": []byte("hunter22"), "SHARED": []byte("from-secret")},
+			},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "helm-in-pod"},
+				Data:       map[string]string{"REGION": "eu", "SHARED": "from-configmap", "not-a-name": "x"},
+			},
+		)
+		m = NewManager(context.Background(), "host", "helm-in-pod", nil,
+			WithClient(operatorkclient.NewClientFromClientSet(cs, nil, nil)))
+	})
+
+	AfterEach(func() {
+		hipredact.Reset()
+	})
+
+	It("should split plain and secret variables with later sources winning", func() {
+		env, secretEnv, err := m.resolveEnvFrom(context.Background(), []string{"secret:deploy-creds", "configmap:settings"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(Equal(map[string]string{"REGION": "eu", "SHARED": "from-configmap"}))
+		Expect(secretEnv).To(Equal(map[string]string{"DB_PASSWORD": "hunter22"}))
+		Expect(hipredact.String("hunter22")).To(Equal(hipredact.Mask))
+	})
+
+	It("should apply the prefix", func() {
+		env, _, err := m.resolveEnvFrom(context.Background(), []string{"configmap:settings:APP_"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(HaveKeyWithValue("APP_REGION", "eu"))
+	})
+
+	It("should fail for a missing source", func() {
+		_, _, err := m.resolveEnvFrom(context.Background(), []string{"secret:missing"})
+		Expect(err).To(MatchError(ContainSubstring("failed to get 'missing' secret")))
+	})
+
+	It("should check that every source exists", func() {
+		Expect(m.checkEnvFrom(context.Background(), []string{"secret:deploy-creds", "configmap:settings"})).To(Succeed())
+		Expect(m.checkEnvFrom(context.Background(), []string{"secret:deploy-creds", "configmap:missing"})).To(MatchError(ContainSubstring("failed to get 'missing' configmap")))
+		Expect(hipredact.String("hunter22")).To(Equal("hunter22"))
+	})
+})
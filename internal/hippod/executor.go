@@ -1,15 +1,14 @@
 package hippod
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
-	"strconv"
+	"slices"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/Noksa/operator-home/pkg/operatorkclient"
@@ -18,12 +17,14 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/helpers"
 	"github.com/noksa/helm-in-pod/internal/hipconsts"
 	"github.com/noksa/helm-in-pod/internal/hiperrors"
-	"github.com/noksa/helm-in-pod/internal/hipretry"
+	"github.com/noksa/helm-in-pod/internal/hipredact"
+	"github.com/noksa/helm-in-pod/internal/hipreport"
+	"github.com/noksa/helm-in-pod/internal/hiptrace"
 	"github.com/noksa/helm-in-pod/internal/logz"
 )
 
@@ -36,13 +37,13 @@ type UserInfo struct {
 func (m *Manager) GetPodUserInfo(pod *corev1.Pod) (*UserInfo, error) {
 	var stdout string
 
-	err := hipretry.Retry(3, func() error {
-		logz.Pod().Debug().Msg("Determining user home directory")
+	err := m.report.Retry("user info", 3, func() error {
+		m.log.Pod().Debug().Msg("Determining user home directory")
 		var stderr string
 		var err error
 		stdout, stderr, err = m.client().ExecInPod(
 			`echo "${HOME}:::$(whoami):::$(id)"`,
-			Namespace, pod.Name, pod.Namespace)
+			hipconsts.HelmInPodName, pod.Name, pod.Namespace)
 		if err != nil {
 			return fmt.Errorf("%s: %w", stderr, err)
 		}
@@ -69,7 +70,7 @@ func (m *Manager) GetPodUserInfo(pod *corev1.Pod) (*UserInfo, error) {
 		return nil, fmt.Errorf("user (%v) in the image doesn't have home directory", userInfo)
 	}
 
-	logz.Pod().Debug().Msgf("(%v) home directory: %v", color.GreenString(whoami), color.MagentaString(homeDirectory))
+	m.log.Pod().Debug().Msgf("(%v) home directory: %v", color.GreenString(whoami), color.MagentaString(homeDirectory))
 	return &UserInfo{
 		HomeDirectory: homeDirectory,
 		Whoami:        whoami,
@@ -77,7 +78,10 @@ func (m *Manager) GetPodUserInfo(pod *corev1.Pod) (*UserInfo, error) {
 	}, nil
 }
 
-func (m *Manager) SyncHelmRepositories(pod *corev1.Pod, opts cmdoptions.ExecOptions, homeDirectory string, isHelm4 bool) error {
+func (m *Manager) SyncHelmRepositories(pod *corev1.Pod, opts cmdoptions.ExecOptions, homeDirectory string, isHelm4 bool) (err error) {
+	ctx, span := hiptrace.Start(m.ctx, "SyncHelmRepositories", hiptrace.Pod(pod.Name))
+	defer func() { hiptrace.End(span, err) }()
+	log := m.phaseLog(pod.Name, logz.PhaseRepos)
 	settings := cli.New()
 	_, statErr := os.Stat(settings.RepositoryConfig)
 	if statErr != nil && !errors.Is(statErr, os.ErrNotExist) {
@@ -87,31 +91,34 @@ func (m *Manager) SyncHelmRepositories(pod *corev1.Pod, opts cmdoptions.ExecOpti
 		return nil
 	}
 
-	err := hipretry.Retry(opts.CopyAttempts, func() error {
-		logz.Pod().Debug().Msgf("Creating %v/.config/helm directory", homeDirectory)
+	stopSync := m.report.Start(hipreport.PhaseRepoSync)
+	err = m.report.Retry("helm config directory", opts.CopyAttempts, func() error {
+		log.Pod().Debug().Msgf("Creating %v/.config/helm directory", homeDirectory)
 		_, stderr, err := m.client().ExecInPod(
 			`set +e; mkdir -p "${HOME}/.config/helm" &>/dev/null`,
-			Namespace, pod.Name, pod.Namespace)
+			hipconsts.HelmInPodName, pod.Name, pod.Namespace)
 		if err != nil {
 			return fmt.Errorf("%s: %w", stderr, err)
 		}
 		return nil
 	})
 	if err != nil {
+		stopSync()
 		return err
 	}
 
 	err = m.CopyFileToPod(pod, settings.RepositoryConfig,
-		fmt.Sprintf("%v/.config/helm/repositories.yaml", homeDirectory), opts.CopyAttempts)
+		fmt.Sprintf("%v/.config/helm/repositories.yaml", homeDirectory), opts.CopyAttempts, opts.CopyTimeout)
+	stopSync()
 	if err != nil {
 		return err
 	}
 
-	return m.updateHelmRepositories(pod, opts, isHelm4)
+	return m.updateHelmRepositories(ctx, pod, opts, isHelm4)
 }
 
 func (m *Manager) UpdateHelmRepositories(pod *corev1.Pod, opts cmdoptions.ExecOptions, isHelm4 bool) error {
-	err := m.updateHelmRepositories(pod, opts, isHelm4)
+	err := m.updateHelmRepositories(m.ctx, pod, opts, isHelm4)
 	if err != nil {
 		return err
 	}
@@ -123,42 +130,51 @@ func (m *Manager) UpdateHelmRepositories(pod *corev1.Pod, opts cmdoptions.ExecOp
 	})
 }
 
-func (m *Manager) updateHelmRepositories(pod *corev1.Pod, opts cmdoptions.ExecOptions, isHelm4 bool) error {
+// updateHelmRepositories updates opts.UpdateRepo, or all repositories if it is
+// empty. Each update gets a span that is a child of the span in ctx.
+func (m *Manager) updateHelmRepositories(ctx context.Context, pod *corev1.Pod, opts cmdoptions.ExecOptions, isHelm4 bool) error {
+	log := m.phaseLog(pod.Name, logz.PhaseRepos)
+	defer m.report.Start(hipreport.PhaseRepoUpdate)()
 	if len(opts.UpdateRepo) == 0 {
-		return hipretry.Retry(opts.UpdateRepoAttempts, func() error {
-			logz.Pod().Info().Msgf("Fetching updates from %v helm repositories", color.GreenString("all"))
+		_, span := hiptrace.Start(ctx, "helm repo update", hiptrace.Pod(pod.Name))
+		err := m.report.Retry("helm repo update", opts.UpdateRepoAttempts, func() error {
+			log.Pod().Info().Msgf("Fetching updates from %v helm repositories", color.GreenString("all"))
 			cmdToUse := "helm repo update"
 			if !isHelm4 {
 				cmdToUse = fmt.Sprintf("%v --fail-on-repo-update-fail", cmdToUse)
 			}
 			stdout, stderr, err := m.client().ExecInPod(cmdToUse,
-				Namespace, pod.Name, pod.Namespace,
+				hipconsts.HelmInPodName, pod.Name, pod.Namespace,
 				operatorkclient.WithRawCommand(true))
 			if err != nil {
 				return fmt.Errorf("%w\n%v\n%v", err, stdout, stderr)
 			}
-			logz.Pod().Debug().Msg("Helm repository updates have been fetched")
+			log.Pod().Debug().Msg("Helm repository updates have been fetched")
 			return nil
 		})
+		hiptrace.End(span, err)
+		return err
 	}
 
 	var errs []error
 	for _, repo := range opts.UpdateRepo {
-		err := hipretry.Retry(opts.UpdateRepoAttempts, func() error {
-			logz.Pod().Info().Msgf("Fetching updates from %v helm repository", color.CyanString(repo))
+		_, span := hiptrace.Start(ctx, "helm repo update "+repo, hiptrace.Pod(pod.Name))
+		err := m.report.Retry(fmt.Sprintf("helm repo update %v", repo), opts.UpdateRepoAttempts, func() error {
+			log.Pod().Info().Msgf("Fetching updates from %v helm repository", color.CyanString(repo))
 			cmdToUse := fmt.Sprintf("helm repo update %v", repo)
 			if !isHelm4 {
 				cmdToUse = fmt.Sprintf("%v --fail-on-repo-update-fail", cmdToUse)
 			}
 			stdout, stderr, err := m.client().ExecInPod(cmdToUse,
-				Namespace, pod.Name, pod.Namespace,
+				hipconsts.HelmInPodName, pod.Name, pod.Namespace,
 				operatorkclient.WithRawCommand(true))
 			if err != nil {
 				return fmt.Errorf("%w\n%v\n%v", err, stdout, stderr)
 			}
-			logz.Pod().Debug().Msgf("%v helm repository updates have been fetched", color.CyanString(repo))
+			log.Pod().Debug().Msgf("%v helm repository updates have been fetched", color.CyanString(repo))
 			return nil
 		})
+		hiptrace.End(span, err)
 		if err != nil {
 			errs = append(errs, err)
 		}
@@ -166,37 +182,161 @@ func (m *Manager) updateHelmRepositories(pod *corev1.Pod, opts cmdoptions.ExecOp
 	return errors.Join(errs...)
 }
 
-func (m *Manager) CopyUserFiles(pod *corev1.Pod, opts cmdoptions.ExecOptions, expandPath func(string) (string, error), cleanPaths []string) error {
-	// Delete specified paths first to ensure clean state
-	if len(cleanPaths) > 0 {
-		cmd := fmt.Sprintf("rm -rf %s", strings.Join(cleanPaths, " "))
-		logz.Pod().Debug().Msgf("Cleaning up files: %v", cmd)
-		stdOut, stdErr, err := m.client().ExecInPod(cmd, Namespace, pod.Name, pod.Namespace)
-		if err != nil {
-			return fmt.Errorf("%v\n%v\n%v", err, stdErr, stdOut)
+// ExecuteCommand copies the wrapped script to the pod and streams execution until
+// the command finishes. The result is read from the signed result record.
+// Always call after all preprocessing (file copies, repo sync) so the pod init
+// script does not start the user command prematurely.
+func (m *Manager) ExecuteCommand(ctx context.Context, pod *corev1.Pod, command string, opts cmdoptions.ExecOptions) (err error) {
+	ctx, span := hiptrace.Start(ctx, "ExecuteCommand", hiptrace.Pod(pod.Name))
+	defer func() { hiptrace.End(span, err) }()
+	log := m.phaseLog(pod.Name, logz.PhaseExec)
+	defer m.flushOutput()
+	defer m.report.Start(hipreport.PhaseCommand)()
+	nonce := newNonce()
+	if err := m.copyWrappedScript(ctx, pod, command, nonce, opts); err != nil {
+		return err
+	}
+
+	log.Pod().Info().Msgf("Running '%v' command", color.YellowString(commandDescription(command, opts)))
+
+	go func() {
+		<-ctx.Done()
+		log.Host().Warn().Msg("Timed out!")
+		for {
+			_, _, err := m.client().ExecInPod("kill -term 1",
+				hipconsts.HelmInPodName, pod.Name, pod.Namespace,
+				operatorkclient.WithRawCommand(true))
+			if err == nil {
+				return
+			}
+			time.Sleep(time.Millisecond * 50)
 		}
+	}()
+
+	if opts.Attach {
+		return m.runAttached(ctx, pod, nonce, opts)
 	}
 
-	for k, v := range opts.FilesAsMap {
-		path, err := expandPath(k)
-		if err != nil {
-			return err
+	if err := m.streamCommandOutput(ctx, pod); err != nil {
+		return err
+	}
+	// The record is written before the output is complete
+	return m.commandResultErr(ctx, pod, nonce, opts.CompletionTimeout)
+}
+
+// outputStreamScript prints the output file from offset as it grows and exits
+// once the pod script has created doneFile and everything is printed.
+func outputStreamScript(file, doneFile string, offset int64) string {
+	return fmt.Sprintf(`f=%[1]s; off=%[2]d
+while :; do
+  done=0; [ -f %[3]s ] && done=1
+  if [ -f "$f" ]; then
+    size=$(($(wc -c < "$f")))
+    if [ "$size" -gt "$off" ]; then
+      tail -c +$((off+1)) "$f" | head -c $((size-off))
+      off=$size
+    fi
+  fi
+  [ "$done" = 1 ] && exit 0
+  sleep 0.2 2>/dev/null || sleep 1
+done`, file, offset, doneFile)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// streamCommandOutput writes the command's output to the manager's stdout until
+// the command finishes. After a broken connection it resumes from the last byte
+// received, so every line is shown exactly once. If the pod terminates before
+// the output is complete, the rest of it is lost and nil is returned.
+func (m *Manager) streamCommandOutput(ctx context.Context, pod *corev1.Pod) error {
+	log := m.phaseLog(pod.Name, logz.PhaseExec)
+	out := &countingWriter{w: m.stdout}
+	for {
+		err := m.streamExec(ctx, pod, streamExecOptions{
+			Command: []string{"sh", "-c", outputStreamScript(hipconsts.OutputFile, hipconsts.OutputDoneFile, out.n)},
+			Stdout:  out,
+			Stderr:  io.Discard,
+		})
+		if err == nil {
+			return nil
 		}
-		err = m.CopyFileToPod(pod, path, v, opts.CopyAttempts)
-		if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		phase, phaseErr := m.GetPodPhase(ctx, pod)
+		if phaseErr == nil && (phase == corev1.PodSucceeded || phase == corev1.PodFailed) {
+			log.Host().Warn().Msgf("%v pod terminated before its output was read completely", color.CyanString(pod.Name))
+			return nil
+		}
+		log.Host().Info().Msgf("got an error from streaming command output, resuming from byte %d: %v", out.n, err)
+		time.Sleep(time.Second)
+	}
+}
+
+// StartCommand copies the wrapped script to the pod and returns without waiting,
+// the pod runs the command on its own. Used by exec --detach. The nonce of the
+// result record is kept in an annotation of the pod, outside the command's reach.
+func (m *Manager) StartCommand(pod *corev1.Pod, command string, opts cmdoptions.ExecOptions) error {
+	log := m.phaseLog(pod.Name, logz.PhaseExec)
+	nonce := newNonce()
+	if err := m.AnnotatePod(pod, map[string]string{hipconsts.AnnotationResultNonce: nonce}); err != nil {
+		return err
+	}
+	if err := m.copyWrappedScript(m.ctx, pod, command, nonce, opts); err != nil {
+		return err
+	}
+	log.Pod().Info().Msgf("Started '%v' command", color.YellowString(commandDescription(command, opts)))
+	return nil
+}
+
+// copyWrappedScript copies command and the nonce to the pod, followed by the
+// wrapped script the pod init script waits for, which runs the command and
+// writes its result record signed with nonce.
+func (m *Manager) copyWrappedScript(ctx context.Context, pod *corev1.Pod, command, nonce string, opts cmdoptions.ExecOptions) error {
+	scripts := []struct{ content, dest string }{
+		{"#!/bin/sh\nset -eu\n" + traceParentExport(ctx) + command + "\n", hipconsts.CommandScriptPath},
+		{nonce, hipconsts.NonceFile},
+		// Copied last, the pod init script starts once it exists
+		{resultRunnerScript(hipconsts.CommandScriptPath, hipconsts.ResultFile, hipconsts.NonceFile), hipconsts.WrappedScriptPath},
+	}
+	for _, script := range scripts {
+		if err := m.copyScript(pod, script.content, script.dest, opts); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// ExecuteCommand copies the wrapped script to the pod and streams execution until
-// the pod completes. Always call after all preprocessing (file copies, repo sync)
-// so the pod init script does not start the user command prematurely.
-func (m *Manager) ExecuteCommand(ctx context.Context, pod *corev1.Pod, command string, opts cmdoptions.ExecOptions) error {
-	copyFromMode := len(opts.CopyFrom) > 0
+// traceParentExport returns a line exporting the trace context of ctx as
+// TRACEPARENT, so instrumented tools in the pod continue the trace. It is empty
+// when there is no trace.
+func traceParentExport(ctx context.Context) string {
+	traceParent := hiptrace.TraceParent(ctx)
+	if traceParent == "" {
+		return ""
+	}
+	return fmt.Sprintf("export %s=%q\n", hiptrace.EnvTraceParent, traceParent)
+}
 
-	tempScriptFile, err := os.CreateTemp("", hipconsts.HelmInPodNamespace)
+// exportLine returns a shell line exporting name with value taken verbatim,
+// without expanding variables, command substitutions or escapes in it.
+func exportLine(name, value string) string {
+	return fmt.Sprintf("export %s=%s\n", name, helpers.ShellQuote(value))
+}
+
+// copyScript copies content to dest in the pod as an executable file.
+func (m *Manager) copyScript(pod *corev1.Pod, content, dest string, opts cmdoptions.ExecOptions) error {
+	tempScriptFile, err := os.CreateTemp("", hipconsts.HelmInPodName)
 	if err != nil {
 		return err
 	}
@@ -208,98 +348,91 @@ func (m *Manager) ExecuteCommand(ctx context.Context, pod *corev1.Pod, command s
 	if err := os.Chmod(tempScriptFile.Name(), os.ModePerm); err != nil {
 		return err
 	}
-	for _, s := range []string{"#!/bin/sh\nset -eu\n", command, "\n"} {
-		if _, wErr := tempScriptFile.WriteString(s); wErr != nil {
-			return wErr
-		}
-	}
-
-	since := time.Now()
-	if err := m.CopyFileToPod(pod, tempScriptFile.Name(), hipconsts.WrappedScriptPath, opts.CopyAttempts); err != nil {
+	if _, err := tempScriptFile.WriteString(content); err != nil {
 		return err
 	}
+	return m.CopyFileToPod(pod, tempScriptFile.Name(), dest, opts.CopyAttempts, opts.CopyTimeout)
+}
 
-	logz.Pod().Info().Msgf("Running '%v' command", color.YellowString(command))
+// runAttached runs the wrapped script in an exec session so the command's stdout
+// and stderr reach the host's stdout and stderr separately. PID 1 waits for the
+// result record and for the exit code the host verified from it, and then exits
+// (or waits for copy-from) as usual.
+// With opts.Stdin the host's stdin is forwarded to the command; EOF on the host
+// closes the command's stdin. If the exec session fails before the command
+// started, e.g. because exec is forbidden, the error is returned right away.
+func (m *Manager) runAttached(ctx context.Context, pod *corev1.Pod, nonce string, opts cmdoptions.ExecOptions) error {
+	log := m.phaseLog(pod.Name, logz.PhaseExec)
+	stdout := &countingWriter{w: m.stdout}
+	stderr := &countingWriter{w: m.stderr}
+	streamOpts := streamExecOptions{
+		Command: []string{hipconsts.WrappedScriptPath},
+		Stdout:  stdout,
+		Stderr:  stderr,
+	}
+	if opts.Stdin {
+		streamOpts.Stdin = os.Stdin
+	}
+	err := m.streamExec(ctx, pod, streamOpts)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	var exitErr *hiperrors.ExitCodeError
+	lost := err != nil && !errors.As(err, &exitErr)
+	if lost && stdout.n+stderr.n == 0 && !m.commandStarted(ctx, pod, hipconsts.NonceFile) {
+		return fmt.Errorf("failed to start the command: %w", err)
+	}
+	var pollTimeout time.Duration
+	if lost {
+		// The command keeps running in the pod when the exec connection drops,
+		// the pod waits for it no longer than opts.Timeout
+		log.Host().Warn().Msgf("Lost connection to the command: %v. Waiting for it to finish, the rest of its output is not shown", err)
+		pollTimeout = opts.Timeout
+	}
+	result, resultErr := m.readCommandResult(ctx, pod, hipconsts.ResultFile, nonce, pollTimeout)
+	if resultErr == nil {
+		m.signalExitCode(pod, result.ExitCode)
+		return result.Err()
+	}
+	if !errors.Is(resultErr, errNoResult) {
+		return resultErr
+	}
+	if lost {
+		m.signalExitCode(pod, 1)
+		return errors.Join(err, resultErr)
+	}
+	// Fall back to the exit code of the exec session, which is the wrapped script's
+	log.Host().Warn().Msgf("%v in %v pod, using the exit code of the exec session", resultErr, color.CyanString(pod.Name))
+	if exitErr != nil {
+		m.signalExitCode(pod, exitErr.Code)
+		log.Pod().Info().Msgf("Command exited with code %d", exitErr.Code)
+		return exitErr
+	}
+	m.signalExitCode(pod, 0)
+	return nil
+}
 
-	b := &bytes.Buffer{}
-	var logWriter io.Writer
+func (m *Manager) ExecuteCommandInDaemon(ctx context.Context, pod *corev1.Pod, command string, homeDirectory string, timeout time.Duration, opts cmdoptions.ExecOptions) (err error) {
+	ctx, span := hiptrace.Start(ctx, "ExecuteCommandInDaemon", hiptrace.Pod(pod.Name))
+	defer func() { hiptrace.End(span, err) }()
+	log := m.phaseLog(pod.Name, logz.PhaseExec)
+	defer m.flushOutput()
+	defer m.report.Start(hipreport.PhaseCommand)()
+	scriptPath := fmt.Sprintf("%v/wrapped-script.sh", homeDirectory)
+	runnerPath := fmt.Sprintf("%v/hip-result-runner.sh", homeDirectory)
+	resultPath := fmt.Sprintf("%v/hip-result.json", homeDirectory)
+	noncePath := fmt.Sprintf("%v/hip-nonce", homeDirectory)
 
-	// In copy-from mode, wrap the writer to intercept the exit code marker.
-	// We use a cancellable context so the marker writer can break the blocking
-	// StreamLogsFromPod call once the marker is detected.
-	var mw *exitCodeMarkerWriter
-	var cancelStream context.CancelFunc
-	streamCtx := ctx
-	if copyFromMode {
-		streamCtx, cancelStream = context.WithCancel(ctx)
-		defer cancelStream()
-		mw = newExitCodeMarkerWriter(io.MultiWriter(os.Stdout, b), cancelStream)
-		logWriter = mw
-	} else {
-		logWriter = io.MultiWriter(os.Stdout, b)
+	envFrom, envFromSecrets, err := m.resolveEnvFrom(ctx, opts.EnvFrom)
+	if err != nil {
+		return err
 	}
-
-	go func() {
-		<-ctx.Done()
-		logz.Host().Warn().Msg("Timed out!")
-		for {
-			_, _, err := m.client().ExecInPod("kill -term 1",
-				hipconsts.HelmInPodNamespace, pod.Name, pod.Namespace,
-				operatorkclient.WithRawCommand(true))
-			if err == nil {
-				return
-			}
-			time.Sleep(time.Millisecond * 50)
-		}
-	}()
-
-	wg := sync.WaitGroup{}
-	wg.Go(func() {
-		for {
-			phase, err := m.GetPodPhase(ctx, pod)
-			if err != nil {
-				if client.IgnoreNotFound(err) == nil {
-					return
-				}
-				if ctx.Err() != nil {
-					return
-				}
-				time.Sleep(time.Millisecond * 25)
-				continue
-			}
-			if phase == corev1.PodFailed || phase == corev1.PodSucceeded {
-				_ = m.StreamLogsFromPod(ctx, pod, logWriter, since)
-				return
-			}
-			err = m.StreamLogsFromPod(streamCtx, pod, logWriter, since)
-			since = time.Now()
-			if err == nil {
-				return
-			}
-			if copyFromMode && mw.Found() {
-				return
-			}
-			logz.Host().Info().Msgf("got an error from streaming pod logs: %v", err)
-			time.Sleep(time.Millisecond * 25)
-		}
-	})
-	wg.Wait()
-
-	if copyFromMode && mw.Found() {
-		code := mw.ExitCode()
-		logz.Pod().Info().Msgf("Command exited with code %d (pod kept alive for copy-from)", code)
-		if code != 0 {
-			return &hiperrors.ExitCodeError{Code: int32(code)}
-		}
-		return nil
+	forwarded, err := opts.ForwardedEnv()
+	if err != nil {
+		return err
 	}
-	return m.waitForPodCompletion(ctx, pod)
-}
-
-func (m *Manager) ExecuteCommandInDaemon(ctx context.Context, pod *corev1.Pod, command string, homeDirectory string, timeout time.Duration, opts cmdoptions.ExecOptions) error {
-	scriptPath := fmt.Sprintf("%v/wrapped-script.sh", homeDirectory)
 
-	tempScriptFile, err := os.CreateTemp("", hipconsts.HelmInPodNamespace)
+	tempScriptFile, err := os.CreateTemp("", hipconsts.HelmInPodName)
 	if err != nil {
 		return err
 	}
@@ -319,25 +452,49 @@ func (m *Manager) ExecuteCommandInDaemon(ctx context.Context, pod *corev1.Pod, c
 	}
 
 	// Log command execution to PID 1 stdout
-	_, err = fmt.Fprintf(tempScriptFile, "echo \"[$(date +%%D-%%T)] Executing: %s\" > /proc/1/fd/1\n", command)
+	_, err = fmt.Fprintf(tempScriptFile, "echo \"[$(date +%%D-%%T)] Executing: %s\" > /proc/1/fd/1\n", commandDescription(command, opts))
 	if err != nil {
 		return err
 	}
 
-	// Export environment variables
-	for _, env := range opts.SubstEnv {
-		val := os.Getenv(env)
-		_, err = fmt.Fprintf(tempScriptFile, "export %s=%q\n", env, val)
+	// Export environment variables, --env-from first so the others override it
+	for _, name := range slices.Sorted(maps.Keys(envFrom)) {
+		_, err = tempScriptFile.WriteString(exportLine(name, envFrom[name]))
+		if err != nil {
+			return err
+		}
+	}
+	plainNames := slices.Collect(maps.Keys(opts.Env))
+	for _, env := range forwarded {
+		plainNames = append(plainNames, env.Name)
+		_, err = tempScriptFile.WriteString(exportLine(env.Name, env.Value))
 		if err != nil {
 			return err
 		}
 	}
 	for k, v := range opts.Env {
-		_, err = fmt.Fprintf(tempScriptFile, "export %s=%q\n", k, v)
+		_, err = tempScriptFile.WriteString(exportLine(k, v))
+		if err != nil {
+			return err
+		}
+	}
+	// Secret variables are read from stdin, so they are never written to the pod
+	secretEnv := opts.SecretEnvValues()
+	for name, value := range envFromSecrets {
+		if _, ok := secretEnv[name]; !ok && !slices.Contains(plainNames, name) {
+			secretEnv[name] = value
+		}
+	}
+	if len(secretEnv) > 0 {
+		_, err = tempScriptFile.WriteString(secretEnvScript)
 		if err != nil {
 			return err
 		}
 	}
+	_, err = tempScriptFile.WriteString(traceParentExport(ctx))
+	if err != nil {
+		return err
+	}
 
 	_, err = tempScriptFile.WriteString(command)
 	if err != nil {
@@ -350,35 +507,84 @@ func (m *Manager) ExecuteCommandInDaemon(ctx context.Context, pod *corev1.Pod, c
 		return err
 	}
 
-	err = m.CopyFileToPod(pod, tempScriptFile.Name(), scriptPath, 3)
+	err = m.CopyFileToPod(pod, tempScriptFile.Name(), scriptPath, 3, opts.CopyTimeout)
+	if err != nil {
+		return err
+	}
+	nonce := newNonce()
+	err = m.copyScript(pod, nonce, noncePath, opts)
+	if err != nil {
+		return err
+	}
+	err = m.copyScript(pod, resultRunnerScript(scriptPath, resultPath, noncePath), runnerPath, opts)
 	if err != nil {
 		return err
 	}
 
-	logz.Pod().Info().Msgf("Running '%v' command", color.YellowString(command))
+	log.Pod().Info().Msgf("Running '%v' command", color.YellowString(commandDescription(command, opts)))
 
-	_, _, err = m.client().ExecInPod(fmt.Sprintf("sh %s", scriptPath), Namespace, pod.Name, pod.Namespace,
+	stdout := &countingWriter{w: m.stdout}
+	stderr := &countingWriter{w: m.stderr}
+	execOpts := []operatorkclient.RunCommandOption{
 		operatorkclient.WithContext(ctx),
 		operatorkclient.WithTimeout(timeout),
 		operatorkclient.WithRawCommand(true),
-		operatorkclient.WithStdout(os.Stdout),
-		operatorkclient.WithStderr(os.Stderr),
-	)
-	if err != nil {
-		if code := parseExitCodeFromError(err); code != hiperrors.ExitCodeUnknown {
-			logz.Pod().Info().Msgf("Command exited with code %d", code)
-			return &hiperrors.ExitCodeError{Code: int32(code)}
+		operatorkclient.WithStdout(stdout),
+		operatorkclient.WithStderr(stderr),
+	}
+	var stdin io.Reader
+	if opts.Stdin {
+		stdin = os.Stdin
+	}
+	if len(secretEnv) > 0 {
+		readers := []io.Reader{strings.NewReader(secretEnvInput(secretEnv))}
+		if stdin != nil {
+			readers = append(readers, stdin)
 		}
+		stdin = io.MultiReader(readers...)
+	}
+	if stdin != nil {
+		execOpts = append(execOpts, operatorkclient.WithStdin(stdin))
+	}
+	_, _, err = m.client().ExecInPod(fmt.Sprintf("sh %s", runnerPath), hipconsts.HelmInPodName, pod.Name, pod.Namespace, execOpts...)
+	if ctx.Err() != nil {
+		return err
+	}
+	code := parseExitCodeFromError(err)
+	lost := err != nil && code == hiperrors.ExitCodeUnknown
+	if lost && stdout.n+stderr.n == 0 && !m.commandStarted(ctx, pod, noncePath) {
+		return fmt.Errorf("failed to start the command: %w", err)
+	}
+	var pollTimeout time.Duration
+	if lost {
+		// The command keeps running in the daemon when the exec connection drops
+		log.Host().Warn().Msgf("Lost connection to the command: %v. Waiting for it to finish, the rest of its output is not shown", err)
+		pollTimeout = timeout
+	}
+	result, resultErr := m.readCommandResult(ctx, pod, resultPath, nonce, pollTimeout)
+	if resultErr == nil {
+		return result.Err()
+	}
+	if lost {
+		return errors.Join(err, resultErr)
 	}
-	return err
+	// Fall back to the exit code of the exec session
+	log.Host().Warn().Msgf("%v in %v pod, using the exit code of the exec session", resultErr, color.CyanString(pod.Name))
+	if code != hiperrors.ExitCodeUnknown {
+		log.Pod().Info().Msgf("Command exited with code %d", code)
+		return &hiperrors.ExitCodeError{Code: int32(code)}
+	}
+	return nil
 }
 
-func (m *Manager) waitForPodCompletion(ctx context.Context, pod *corev1.Pod) error {
-	logz.Host().Debug().Msg("Waiting 60s until pod phase is changed to failed/succeeded")
+func (m *Manager) waitForPodCompletion(ctx context.Context, pod *corev1.Pod, timeout time.Duration) error {
+	log := m.phaseLog(pod.Name, logz.PhaseExec)
+	timeout = orDefault(timeout, hipconsts.DefaultCompletionTimeout)
+	log.Host().Debug().Msgf("Waiting %v until pod phase is changed to failed/succeeded", timeout)
 
 	var phase corev1.PodPhase
 
-	err := wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, 60*time.Second, true, func(pollCtx context.Context) (bool, error) {
+	err := wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, timeout, true, func(pollCtx context.Context) (bool, error) {
 		p, getErr := m.GetPodPhase(pollCtx, pod)
 		if getErr != nil {
 			return false, nil
@@ -387,7 +593,7 @@ func (m *Manager) waitForPodCompletion(ctx context.Context, pod *corev1.Pod) err
 		return phase == corev1.PodSucceeded || phase == corev1.PodFailed, nil
 	})
 
-	logz.Host().Debug().Msgf("Pod got phase: %v", color.CyanString("%v", phase))
+	log.Host().Debug().Msgf("Pod got phase: %v", color.CyanString("%v", phase))
 
 	if wait.Interrupted(err) {
 		return fmt.Errorf("unexpected pod phase: %v", phase)
@@ -400,7 +606,7 @@ func (m *Manager) waitForPodCompletion(ctx context.Context, pod *corev1.Pod) err
 		// Extract the actual exit code from the container status
 		exitCode := m.getContainerExitCode(pod)
 		if exitCode != hiperrors.ExitCodeUnknown {
-			logz.Pod().Info().Msgf("Command exited with code %d", exitCode)
+			log.Pod().Info().Msgf("Command exited with code %d", exitCode)
 			return &hiperrors.ExitCodeError{Code: exitCode}
 		}
 		return fmt.Errorf("pod failed")
@@ -429,6 +635,16 @@ func exitCodeFromContainerStatuses(statuses []corev1.ContainerStatus) int32 {
 	return hiperrors.ExitCodeUnknown
 }
 
+// commandDescription returns the command for logs with secrets masked. Commands
+// generated for --script embed the whole script, so only the script and
+// interpreter are shown.
+func commandDescription(command string, opts cmdoptions.ExecOptions) string {
+	if opts.Script == "" {
+		return hipredact.String(command)
+	}
+	return fmt.Sprintf("%s %s", opts.Interpreter, opts.Script)
+}
+
 // parseExitCodeFromError attempts to extract an exit code from an error message.
 // The Kubernetes remotecommand executor produces messages like
 // "command terminated with exit code N" which get wrapped by operatorkclient.
@@ -459,64 +675,32 @@ func parseExitCodeFromError(err error) int {
 	return code
 }
 
-// exitCodeMarkerWriter wraps an io.Writer and intercepts the exit code marker
-// line emitted by the pod script in copy-from mode. The marker line is consumed
-// (not forwarded to the underlying writer) and the exit code is stored.
-type exitCodeMarkerWriter struct {
-	inner      io.Writer
-	found      bool
-	exitCode   int
-	cancelFunc context.CancelFunc
-}
-
-func newExitCodeMarkerWriter(inner io.Writer, cancel context.CancelFunc) *exitCodeMarkerWriter {
-	return &exitCodeMarkerWriter{inner: inner, cancelFunc: cancel}
+// SignalCopyDone creates the sentinel file in the pod to let it know
+// that copy-from is complete and it can exit.
+func (m *Manager) SignalCopyDone(pod *corev1.Pod) {
+	m.log.HostPod().Debug().Msg("Signaling copy-done")
+	m.signalPod(pod, hipconsts.CopyFromDoneFile)
 }
 
-func (w *exitCodeMarkerWriter) Write(p []byte) (int, error) {
-	if w.found {
-		return w.inner.Write(p)
-	}
-	s := string(p)
-	prefix := hipconsts.CopyFromExitCodeMarkerPrefix
-	suffix := hipconsts.CopyFromExitCodeMarkerSuffix
-	if strings.Contains(s, prefix) {
-		// Extract exit code between prefix and suffix
-		_, after, _ := strings.Cut(s, prefix)
-		if codeStr, ok := strings.CutSuffix(after, suffix+"\n"); !ok {
-			codeStr, _ = strings.CutSuffix(after, suffix)
-			after = codeStr
-		} else {
-			after = codeStr
-		}
-		code, err := strconv.Atoi(strings.TrimSpace(after))
-		if err == nil {
-			w.found = true
-			w.exitCode = code
-			w.cancelFunc()
-		}
-		return len(p), nil
+// signalExitCode writes the verified exit code of an attached command to
+// ExitCodeFile, the pod exits with it.
+func (m *Manager) signalExitCode(pod *corev1.Pod, code int32) {
+	_, _, err := m.client().ExecInPod(
+		fmt.Sprintf("echo %[1]d > %[2]s.tmp && mv %[2]s.tmp %[2]s", code, hipconsts.ExitCodeFile),
+		hipconsts.HelmInPodName, pod.Name, pod.Namespace)
+	if err != nil {
+		m.log.Host().Debug().Msgf("Failed to create %v (pod may have already exited): %v", hipconsts.ExitCodeFile, err)
 	}
-	return w.inner.Write(p)
 }
 
-func (w *exitCodeMarkerWriter) Found() bool {
-	return w.found
-}
-
-func (w *exitCodeMarkerWriter) ExitCode() int {
-	return w.exitCode
-}
-
-// SignalCopyDone creates the sentinel file in the pod to let it know
-// that copy-from is complete and it can exit.
-func (m *Manager) SignalCopyDone(pod *corev1.Pod) {
-	logz.HostPod().Debug().Msg("Signaling copy-done")
+// signalPod creates the sentinel file in the pod. Errors are only logged, the
+// pod may have already exited.
+func (m *Manager) signalPod(pod *corev1.Pod, file string) {
 	_, _, err := m.client().ExecInPod(
-		fmt.Sprintf("touch %s", hipconsts.CopyFromDoneFile),
-		hipconsts.HelmInPodNamespace, pod.Name, pod.Namespace,
+		fmt.Sprintf("touch %s", file),
+		hipconsts.HelmInPodName, pod.Name, pod.Namespace,
 		operatorkclient.WithRawCommand(true))
 	if err != nil {
-		logz.Host().Debug().Msgf("Failed to signal copy-done (pod may have already exited): %v", err)
+		m.log.Host().Debug().Msgf("Failed to create %v (pod may have already exited): %v", file, err)
 	}
 }
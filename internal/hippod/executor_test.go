@@ -1,10 +1,16 @@
 package hippod
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -120,3 +126,80 @@ var _ = Describe("exitCodeFromContainerStatuses", func() {
 		Expect(exitCodeFromContainerStatuses(statuses)).To(Equal(int32(-1)))
 	})
 })
+
+var _ = Describe("outputStreamScript", func() {
+	var file, doneFile string
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		file = filepath.Join(dir, "output")
+		doneFile = filepath.Join(dir, "output.done")
+		Expect(os.WriteFile(file, []byte("line 1\nline 2\nline 3\n"), 0o600)).To(Succeed())
+		Expect(os.WriteFile(doneFile, nil, 0o600)).To(Succeed())
+	})
+
+	It("should print the whole output from offset 0", func() {
+		out, err := exec.Command("sh", "-c", outputStreamScript(file, doneFile, 0)).Output()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("line 1\nline 2\nline 3\n"))
+	})
+
+	It("should resume from the byte offset", func() {
+		out, err := exec.Command("sh", "-c", outputStreamScript(file, doneFile, int64(len("line 1\nli")))).Output()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("ne 2\nline 3\n"))
+	})
+
+	It("should keep streaming until the output is done", func() {
+		Expect(os.Remove(doneFile)).To(Succeed())
+		cmd := exec.Command("sh", "-c", outputStreamScript(file, doneFile, 0))
+		out := gbytes.NewBuffer()
+		cmd.Stdout = out
+		Expect(cmd.Start()).To(Succeed())
+
+		Eventually(out).Should(gbytes.Say("line 3\n"))
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0o600)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = f.WriteString("line 4\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+		Expect(os.WriteFile(doneFile, nil, 0o600)).To(Succeed())
+
+		Expect(cmd.Wait()).To(Succeed())
+		Expect(string(out.Contents())).To(Equal("line 1\nline 2\nline 3\nline 4\n"))
+	})
+})
+
+var _ = Describe("countingWriter", func() {
+	It("should count the bytes written", func() {
+		out := &countingWriter{w: GinkgoWriter}
+		_, _ = out.Write([]byte("abc"))
+		_, _ = out.Write([]byte("de"))
+		Expect(out.n).To(Equal(int64(5)))
+	})
+})
+
+var _ = Describe("traceParentExport", func() {
+	It("should be empty without a trace", func() {
+		Expect(traceParentExport(context.Background())).To(BeEmpty())
+	})
+
+	It("should export the trace context of ctx", func() {
+		traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+		spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled,
+		}))
+		Expect(traceParentExport(ctx)).To(Equal("export TRACEPARENT=\"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01\"\n"))
+	})
+})
+
+var _ = Describe("exportLine", func() {
+	It("should export the value verbatim", func() {
+		value := "a$(echo pwned)`id`$HOME\\n'quoted'\nsecond line"
+		script := exportLine("VALUE", value) + `printf '%s' "$VALUE"`
+		out, err := exec.Command("sh", "-c", script).Output()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal(value))
+	})
+})
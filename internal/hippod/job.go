@@ -0,0 +1,207 @@
+package hippod
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/noksa/go-helpers/helpers/gopointer"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+	"github.com/noksa/helm-in-pod/internal/logz"
+)
+
+// buildJob wraps podSpec in a Job. The pod template carries the same labels as
+// the Job, so the pods are found by the operation selectors like bare pods.
+func buildJob(objectMeta metav1.ObjectMeta, podSpec corev1.PodSpec, opts cmdoptions.ExecOptions) *batchv1.Job {
+	job := &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+		},
+		ObjectMeta: objectMeta,
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            gopointer.NewOf(opts.JobBackoffLimit),
+			TTLSecondsAfterFinished: gopointer.NewOf(opts.JobTTLSeconds),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      maps.Clone(objectMeta.Labels),
+					Annotations: maps.Clone(objectMeta.Annotations),
+				},
+				Spec: podSpec,
+			},
+		},
+	}
+	if opts.ActiveDeadlineSeconds > 0 {
+		job.Spec.ActiveDeadlineSeconds = gopointer.NewOf(opts.ActiveDeadlineSeconds)
+	}
+	return job
+}
+
+func jobOwnerReference(job *batchv1.Job) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Name:       job.Name,
+		UID:        job.UID,
+	}
+}
+
+// createHelmJobPod creates a Job running podSpec and returns its first pod once it is ready.
+func (m *Manager) createHelmJobPod(ctx context.Context, objectMeta metav1.ObjectMeta, podSpec corev1.PodSpec, secrets []*corev1.Secret, opts cmdoptions.ExecOptions) (*corev1.Pod, error) {
+	job, err := m.client().ClientSet().BatchV1().Jobs(m.namespace).Create(m.ctx, buildJob(objectMeta, podSpec, opts), metav1.CreateOptions{})
+	if err != nil {
+		m.cleanupOrphanedSecrets(secrets...)
+		return nil, err
+	}
+	m.log.Host().Debug().Msgf("%v job has been created", color.MagentaString(job.Name))
+	for _, secret := range secrets {
+		if err := m.setSecretOwnerReference(m.ctx, secret, jobOwnerReference(job)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Create PodDisruptionBudget for the job's pods if enabled
+	if opts.CreatePDB {
+		if err := m.CreatePodDisruptionBudget(m.ctx, m.invocationID); err != nil {
+			// If PDB creation fails, clean up the job immediately
+			_ = m.deleteHelmJob(job.Name)
+			return nil, fmt.Errorf("failed to create PodDisruptionBudget: %w", err)
+		}
+	}
+
+	// Handle interrupt signals until the job's pod is ready
+	defer m.HandleInterrupts(opts)()
+
+	pod, err := m.waitForJobPod(job, "", opts.StartupTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return pod, m.waitUntilPodIsRunning(ctx, pod, opts.StartupTimeout)
+}
+
+// jobFailure returns the reason a Job failed, or an empty string if it did not.
+func jobFailure(job *batchv1.Job) string {
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			if c.Message != "" {
+				return fmt.Sprintf("%s: %s", c.Reason, c.Message)
+			}
+			return c.Reason
+		}
+	}
+	return ""
+}
+
+// activeJobPod returns a pod of the job that is not terminated, being deleted or
+// named previous, or nil if the Job controller has not created one yet.
+func activeJobPod(job *batchv1.Job, pods []corev1.Pod, previous string) *corev1.Pod {
+	for i := range pods {
+		pod := &pods[i]
+		if !metav1.IsControlledBy(pod, job) || pod.Name == previous || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		return pod
+	}
+	return nil
+}
+
+// waitForJobPod waits until the Job controller creates a pod for job other than previous.
+func (m *Manager) waitForJobPod(job *batchv1.Job, previous string, timeout time.Duration) (*corev1.Pod, error) {
+	m.log.Host().Debug().Msgf("Waiting for a pod of %v job", color.MagentaString(job.Name))
+
+	var pod *corev1.Pod
+	selector := fmt.Sprintf("%v=%v", hipconsts.LabelOperationID, m.invocationID)
+	err := wait.PollUntilContextTimeout(m.ctx, time.Second, orDefault(timeout, hipconsts.DefaultStartupTimeout), true, func(ctx context.Context) (bool, error) {
+		if m.interrupted.Load() {
+			return false, fmt.Errorf("interrupted while was waiting for job pod")
+		}
+		latestJob, getErr := m.client().ClientSet().BatchV1().Jobs(m.namespace).Get(ctx, job.Name, metav1.GetOptions{})
+		if getErr != nil {
+			m.log.Host().Debug().Msgf("Couldn't get %v job: %v", job.Name, getErr)
+			return false, nil
+		}
+		if reason := jobFailure(latestJob); reason != "" {
+			return false, fmt.Errorf("'%v' job failed: %v", job.Name, reason)
+		}
+		pods, listErr := m.client().ClientSet().CoreV1().Pods(m.namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if listErr != nil {
+			m.log.Host().Debug().Msgf("Couldn't list pods of %v job: %v", job.Name, listErr)
+			return false, nil
+		}
+		pod = activeJobPod(latestJob, pods.Items, previous)
+		return pod != nil, nil
+	})
+	if wait.Interrupted(err) {
+		return nil, fmt.Errorf("timeout waiting for a pod of '%v' job", job.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.log.Host().Debug().Msgf("%v pod has been created by %v job", color.MagentaString(pod.Name), job.Name)
+	return pod, nil
+}
+
+// NextJobPod waits for the pod the Job controller creates after pod failed and
+// for it to become ready. pod must belong to a Job created with --as-job.
+func (m *Manager) NextJobPod(pod *corev1.Pod, opts cmdoptions.ExecOptions) (*corev1.Pod, error) {
+	log := m.phaseLog(pod.Name, logz.PhaseStartup)
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil || owner.Kind != "Job" {
+		return nil, fmt.Errorf("'%v' pod is not controlled by a job", pod.Name)
+	}
+	job, err := m.client().ClientSet().BatchV1().Jobs(m.namespace).Get(m.ctx, owner.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	log.Host().Warn().Msgf("Command failed in %v pod, waiting for %v job to retry it", color.CyanString(pod.Name), color.MagentaString(job.Name))
+	next, err := m.waitForJobPod(job, pod.Name, opts.StartupTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return next, m.waitUntilPodIsRunning(m.ctx, next, opts.StartupTimeout)
+}
+
+// deleteHelmJobs deletes the jobs matching opts. Their pods are deleted in the
+// background by the garbage collector, so the Job controller doesn't replace
+// pods deleted afterwards.
+func (m *Manager) deleteHelmJobs(opts metav1.ListOptions) error {
+	jobs, err := m.client().ClientSet().BatchV1().Jobs(m.namespace).List(m.ctx, opts)
+	if k8serrors.IsForbidden(err) {
+		// Users without access to jobs can't have created any with --as-job
+		m.log.Host().Debug().Msgf("Skipping jobs cleanup: %v", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for i := range jobs.Items {
+		if err := m.deleteHelmJob(jobs.Items[i].Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) deleteHelmJob(name string) error {
+	m.log.Host().Debug().Msgf("Deleting '%v' job", name)
+	err := m.client().ClientSet().BatchV1().Jobs(m.namespace).Delete(m.ctx, name, metav1.DeleteOptions{
+		PropagationPolicy: gopointer.NewOf(metav1.DeletePropagationBackground),
+	})
+	if err != nil {
+		return err
+	}
+	m.log.Host().Debug().Msgf("'%v' job has been deleted", name)
+	return nil
+}
@@ -0,0 +1,89 @@
+package hippod
+
+import (
+	"github.com/noksa/go-helpers/helpers/gopointer"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+)
+
+var _ = Describe("buildJob", func() {
+	objectMeta := metav1.ObjectMeta{
+		GenerateName: "helm-in-pod-",
+		Labels:       map[string]string{hipconsts.LabelOperationID: "op-1"},
+		Annotations:  map[string]string{"team": "a"},
+	}
+
+	It("should wrap the pod spec with the job options", func() {
+		podSpec := corev1.PodSpec{RestartPolicy: corev1.RestartPolicyNever}
+		job := buildJob(objectMeta, podSpec, cmdoptions.ExecOptions{JobTTLSeconds: 600, JobBackoffLimit: 2, ActiveDeadlineSeconds: 300})
+		Expect(job.Kind).To(Equal("Job"))
+		Expect(job.GenerateName).To(Equal("helm-in-pod-"))
+		Expect(*job.Spec.TTLSecondsAfterFinished).To(Equal(int32(600)))
+		Expect(*job.Spec.BackoffLimit).To(Equal(int32(2)))
+		Expect(*job.Spec.ActiveDeadlineSeconds).To(Equal(int64(300)))
+		Expect(job.Spec.Template.Spec).To(Equal(podSpec))
+	})
+
+	It("should label the pod template like the job", func() {
+		job := buildJob(objectMeta, corev1.PodSpec{}, cmdoptions.ExecOptions{})
+		Expect(job.Spec.Template.Labels).To(HaveKeyWithValue(hipconsts.LabelOperationID, "op-1"))
+		Expect(job.Spec.Template.Annotations).To(HaveKeyWithValue("team", "a"))
+	})
+
+	It("should omit the deadline when it is not set", func() {
+		job := buildJob(objectMeta, corev1.PodSpec{}, cmdoptions.ExecOptions{})
+		Expect(job.Spec.ActiveDeadlineSeconds).To(BeNil())
+		Expect(*job.Spec.BackoffLimit).To(Equal(int32(0)))
+	})
+})
+
+var _ = Describe("activeJobPod", func() {
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "helm-in-pod-abc", UID: types.UID("job-uid")}}
+	jobPod := func(name string, phase corev1.PodPhase) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "batch/v1", Kind: "Job", Name: job.Name, UID: job.UID, Controller: gopointer.NewOf(true),
+				}},
+			},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+	}
+
+	It("should skip the previous, terminated and foreign pods", func() {
+		foreign := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other"}, Status: corev1.PodStatus{Phase: corev1.PodPending}}
+		pods := []corev1.Pod{
+			foreign,
+			jobPod("first", corev1.PodRunning),
+			jobPod("failed", corev1.PodFailed),
+			jobPod("second", corev1.PodPending),
+		}
+		Expect(activeJobPod(job, pods, "first").Name).To(Equal("second"))
+		Expect(activeJobPod(job, pods, "").Name).To(Equal("first"))
+	})
+
+	It("should return nil when the job has no new pod yet", func() {
+		Expect(activeJobPod(job, []corev1.Pod{jobPod("first", corev1.PodFailed)}, "")).To(BeNil())
+	})
+})
+
+var _ = Describe("jobFailure", func() {
+	It("should return the reason of a failed job", func() {
+		job := &batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+			{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded", Message: "Job has reached the specified backoff limit"},
+		}}}
+		Expect(jobFailure(job)).To(Equal("BackoffLimitExceeded: Job has reached the specified backoff limit"))
+	})
+
+	It("should return an empty string for a running job", func() {
+		Expect(jobFailure(&batchv1.Job{})).To(BeEmpty())
+	})
+})
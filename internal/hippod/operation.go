@@ -0,0 +1,175 @@
+package hippod
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+	"github.com/noksa/helm-in-pod/internal/hiperrors"
+)
+
+// OperationResult is the state of the command of a detached operation.
+type OperationResult struct {
+	OperationID string
+	PodName     string
+	Phase       corev1.PodPhase
+	// ExitCode is ExitCodeUnknown until the command has finished.
+	ExitCode int32
+	// Reason explains a failure without an exit code, e.g. an eviction.
+	Reason string
+	// Verified reports whether ExitCode was taken from the command's signed
+	// result record rather than from the pod.
+	Verified bool
+}
+
+// Finished reports whether the pod running the command has terminated.
+func (r *OperationResult) Finished() bool {
+	return r.Phase == corev1.PodSucceeded || r.Phase == corev1.PodFailed
+}
+
+// Err returns nil if the command succeeded, an *hiperrors.ExitCodeError if it
+// exited with a non-zero code, or a plain error if the pod failed without one.
+func (r *OperationResult) Err() error {
+	if r.ExitCode == 0 {
+		return nil
+	}
+	if r.ExitCode != hiperrors.ExitCodeUnknown {
+		return &hiperrors.ExitCodeError{Code: r.ExitCode}
+	}
+	if !r.Finished() {
+		return fmt.Errorf("operation %v is still running in '%v' pod", r.OperationID, r.PodName)
+	}
+	if r.Reason != "" {
+		return fmt.Errorf("'%v' pod failed: %v", r.PodName, r.Reason)
+	}
+	return fmt.Errorf("'%v' pod %v", r.PodName, strings.ToLower(string(r.Phase)))
+}
+
+// operationResult returns the state of the operation's pod. The exit code of a
+// finished command is taken from its result record, verified with the nonce in
+// the pod's annotation, and from the pod if there is no valid record.
+func operationResult(operationID string, pod *corev1.Pod) *OperationResult {
+	result := &OperationResult{
+		OperationID: operationID,
+		PodName:     pod.Name,
+		Phase:       pod.Status.Phase,
+		ExitCode:    hiperrors.ExitCodeUnknown,
+		Reason:      pod.Status.Reason,
+	}
+	if !result.Finished() {
+		return result
+	}
+	record, err := parseCommandResult([]byte(terminationMessage(pod.Status.ContainerStatuses)), pod.Annotations[hipconsts.AnnotationResultNonce])
+	if err != nil {
+		result.ExitCode = exitCodeFromContainerStatuses(pod.Status.ContainerStatuses)
+		return result
+	}
+	result.ExitCode = record.ExitCode
+	result.Verified = true
+	return result
+}
+
+// latestPod returns the most recently created pod, e.g. the last retry of a Job.
+func latestPod(pods []corev1.Pod) *corev1.Pod {
+	var latest *corev1.Pod
+	for i := range pods {
+		if latest == nil || latest.CreationTimestamp.Before(&pods[i].CreationTimestamp) {
+			latest = &pods[i]
+		}
+	}
+	return latest
+}
+
+// GetOperationPod returns the pod running the command of the operation.
+func (m *Manager) GetOperationPod(ctx context.Context, operationID string) (*corev1.Pod, error) {
+	pods, err := m.client().ClientSet().CoreV1().Pods(m.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%v=%v", hipconsts.LabelOperationID, operationID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	pod := latestPod(pods.Items)
+	if pod == nil {
+		return nil, fmt.Errorf("no pod found for operation %v in '%v' namespace", operationID, m.namespace)
+	}
+	return pod, nil
+}
+
+// GetOperationResult returns the current state of the operation's command.
+func (m *Manager) GetOperationResult(ctx context.Context, operationID string) (*OperationResult, error) {
+	pod, err := m.GetOperationPod(ctx, operationID)
+	if err != nil {
+		return nil, err
+	}
+	result := operationResult(operationID, pod)
+	if result.Finished() && !result.Verified {
+		m.log.Host().Warn().Msgf("%v in %v pod, using the exit code of the pod", errNoResult, color.CyanString(pod.Name))
+	}
+	return result, nil
+}
+
+// WaitForOperation waits until the operation's command finishes or ctx is done.
+func (m *Manager) WaitForOperation(ctx context.Context, operationID string) (*OperationResult, error) {
+	logged := false
+	for {
+		result, err := m.GetOperationResult(ctx, operationID)
+		if err != nil {
+			return nil, err
+		}
+		if result.Finished() {
+			return result, nil
+		}
+		if !logged {
+			m.log.Host().Info().Msgf("Waiting for operation %v to finish in %v pod", color.CyanString(operationID), color.MagentaString(result.PodName))
+			logged = true
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// StreamOperationLogs writes the output of the operation's command to the
+// manager's stdout. With follow it keeps streaming until the command finishes,
+// resuming from the last byte received if the stream breaks, like for attached
+// runs. A finished pod can't be streamed from, its log is written instead.
+func (m *Manager) StreamOperationLogs(ctx context.Context, operationID string, follow bool) error {
+	pod, err := m.GetOperationPod(ctx, operationID)
+	if err != nil {
+		return err
+	}
+	defer m.flushOutput()
+	if !follow || operationResult(operationID, pod).Finished() {
+		return m.writePodLogs(ctx, pod, m.stdout)
+	}
+	return m.streamCommandOutput(ctx, pod)
+}
+
+// writePodLogs writes the logs the pod has produced so far to w.
+func (m *Manager) writePodLogs(ctx context.Context, pod *corev1.Pod, w io.Writer) error {
+	stream, err := m.client().ClientSet().CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{}).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stream.Close() }()
+	_, err = io.Copy(w, stream)
+	return err
+}
+
+// DeleteOperation deletes the jobs and pods of the operation together with
+// their PDBs and secrets.
+func (m *Manager) DeleteOperation(operationID string) error {
+	m.log.Host().Info().Msgf("Deleting pods of operation %v", color.CyanString(operationID))
+	return m.deletePods(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%v=%v", hipconsts.LabelOperationID, operationID),
+	})
+}
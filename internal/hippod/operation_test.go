@@ -0,0 +1,89 @@
+package hippod
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+	"github.com/noksa/helm-in-pod/internal/hiperrors"
+)
+
+var _ = Describe("operationResult", func() {
+	terminatedPod := func(phase corev1.PodPhase, code int32) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "helm-in-pod-abc"},
+			Status: corev1.PodStatus{
+				Phase: phase,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: code}},
+				}},
+			},
+		}
+	}
+
+	It("should succeed for a succeeded pod", func() {
+		result := operationResult("op-1", terminatedPod(corev1.PodSucceeded, 0))
+		Expect(result.Finished()).To(BeTrue())
+		Expect(result.Err()).To(Succeed())
+	})
+
+	It("should return the command's exit code", func() {
+		result := operationResult("op-1", terminatedPod(corev1.PodFailed, 3))
+		Expect(result.Err()).To(MatchError(&hiperrors.ExitCodeError{Code: 3}))
+	})
+
+	It("should take the exit code from the verified result record", func() {
+		pod := terminatedPod(corev1.PodFailed, 1)
+		pod.Annotations = map[string]string{hipconsts.AnnotationResultNonce: "nonce"}
+		pod.Status.ContainerStatuses[0].State.Terminated.Message = `{"exitCode":3,"signal":0,"durationSeconds":1,"signature":"` + resultSignature("nonce", 3, 0, 1) + `"}`
+		result := operationResult("op-1", pod)
+		Expect(result.Verified).To(BeTrue())
+		Expect(result.Err()).To(MatchError(&hiperrors.ExitCodeError{Code: 3}))
+	})
+
+	It("should fall back to the pod's exit code without a valid result record", func() {
+		pod := terminatedPod(corev1.PodFailed, 2)
+		pod.Annotations = map[string]string{hipconsts.AnnotationResultNonce: "nonce"}
+		pod.Status.ContainerStatuses[0].State.Terminated.Message = `{"exitCode":0,"signal":0,"durationSeconds":1,"signature":"` + resultSignature("forged", 0, 0, 1) + `"}`
+		result := operationResult("op-1", pod)
+		Expect(result.Verified).To(BeFalse())
+		Expect(result.Err()).To(MatchError(&hiperrors.ExitCodeError{Code: 2}))
+	})
+
+	It("should report a running command without an exit code", func() {
+		result := operationResult("op-1", &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "helm-in-pod-abc"},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		})
+		Expect(result.Finished()).To(BeFalse())
+		Expect(result.ExitCode).To(BeEquivalentTo(hiperrors.ExitCodeUnknown))
+		Expect(result.Err()).To(MatchError(ContainSubstring("still running")))
+	})
+
+	It("should report the reason of a pod failed without an exit code", func() {
+		result := operationResult("op-1", &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "helm-in-pod-abc"},
+			Status:     corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Evicted"},
+		})
+		Expect(result.Err()).To(MatchError(ContainSubstring("Evicted")))
+	})
+})
+
+var _ = Describe("latestPod", func() {
+	It("should return the most recently created pod", func() {
+		now := time.Now()
+		pods := []corev1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Name: "first", CreationTimestamp: metav1.NewTime(now.Add(-time.Minute))}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "retry", CreationTimestamp: metav1.NewTime(now)}},
+		}
+		Expect(latestPod(pods).Name).To(Equal("retry"))
+	})
+
+	It("should return nil without pods", func() {
+		Expect(latestPod(nil)).To(BeNil())
+	})
+})
@@ -9,7 +9,6 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/noksa/helm-in-pod/internal/hipconsts"
-	"github.com/noksa/helm-in-pod/internal/logz"
 )
 
 // CreatePodDisruptionBudget creates a PDB for the given pod with the operation ID
@@ -17,8 +16,8 @@ func (m *Manager) CreatePodDisruptionBudget(ctx context.Context, operationID str
 	minAvailable := intstr.FromInt(1)
 	pdb := &policyv1.PodDisruptionBudget{
 		ObjectMeta: metav1.ObjectMeta{
-			GenerateName: fmt.Sprintf("%s-pdb-", Namespace),
-			Namespace:    Namespace,
+			GenerateName: fmt.Sprintf("%s-pdb-", hipconsts.HelmInPodName),
+			Namespace:    m.namespace,
 			Labels: map[string]string{
 				hipconsts.LabelOperationID: operationID,
 			},
@@ -33,12 +32,12 @@ func (m *Manager) CreatePodDisruptionBudget(ctx context.Context, operationID str
 		},
 	}
 
-	_, err := m.client().ClientSet().PolicyV1().PodDisruptionBudgets(Namespace).Create(ctx, pdb, metav1.CreateOptions{})
+	_, err := m.client().ClientSet().PolicyV1().PodDisruptionBudgets(m.namespace).Create(ctx, pdb, metav1.CreateOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to create PodDisruptionBudget: %w", err)
 	}
 
-	logz.Host().Debug().Msgf("Created PodDisruptionBudget for operation %s", operationID)
+	m.log.Host().Debug().Msgf("Created PodDisruptionBudget for operation %s", operationID)
 	return nil
 }
 
@@ -46,7 +45,7 @@ func (m *Manager) CreatePodDisruptionBudget(ctx context.Context, operationID str
 func (m *Manager) DeletePodDisruptionBudgets(ctx context.Context, operationID string) error {
 	labelSelector := fmt.Sprintf("%s=%s", hipconsts.LabelOperationID, operationID)
 
-	err := m.client().ClientSet().PolicyV1().PodDisruptionBudgets(Namespace).DeleteCollection(
+	err := m.client().ClientSet().PolicyV1().PodDisruptionBudgets(m.namespace).DeleteCollection(
 		ctx,
 		metav1.DeleteOptions{},
 		metav1.ListOptions{
@@ -57,6 +56,6 @@ func (m *Manager) DeletePodDisruptionBudgets(ctx context.Context, operationID st
 		return fmt.Errorf("failed to delete PodDisruptionBudgets: %w", err)
 	}
 
-	logz.Host().Debug().Msgf("Deleted PodDisruptionBudgets for operation %s", operationID)
+	m.log.Host().Debug().Msgf("Deleted PodDisruptionBudgets for operation %s", operationID)
 	return nil
 }
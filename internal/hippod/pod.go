@@ -23,37 +23,133 @@ import (
 	"github.com/Noksa/operator-home/pkg/operatorkclient"
 	"github.com/fatih/color"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 
 	"github.com/noksa/helm-in-pod/internal/cmdoptions"
 	"github.com/noksa/helm-in-pod/internal/helmtar"
 	"github.com/noksa/helm-in-pod/internal/hipconsts"
-	"github.com/noksa/helm-in-pod/internal/hipretry"
+	"github.com/noksa/helm-in-pod/internal/hipredact"
+	"github.com/noksa/helm-in-pod/internal/hipreport"
+	"github.com/noksa/helm-in-pod/internal/hiptrace"
 	"github.com/noksa/helm-in-pod/internal/logz"
 )
 
-const Namespace = "helm-in-pod"
-
 type Manager struct {
 	ctx          context.Context
 	myHostname   string
+	namespace    string
+	restConfig   *rest.Config
+	kclient      *operatorkclient.Client
+	log          *logz.Loggers
+	stdout       io.Writer
+	stderr       io.Writer
+	outputAsLogs bool
 	interrupted  atomic.Bool
 	invocationID string // unique per process; prevents concurrent instances from deleting each other's pods
+	report       *hipreport.Report
+}
+
+// ManagerOption customizes a Manager.
+type ManagerOption func(*Manager)
+
+// WithClient makes the manager use c instead of the default client, e.g. for another kube context.
+func WithClient(c *operatorkclient.Client) ManagerOption {
+	return func(m *Manager) {
+		m.kclient = c
+	}
+}
+
+// WithLoggers replaces the default loggers.
+func WithLoggers(l *logz.Loggers) ManagerOption {
+	return func(m *Manager) {
+		m.log = l
+	}
+}
+
+// WithOutput sets where the command's stdout and stderr are written (default: os.Stdout and os.Stderr).
+func WithOutput(stdout, stderr io.Writer) ManagerOption {
+	return func(m *Manager) {
+		m.stdout = stdout
+		m.stderr = stderr
+	}
+}
+
+// WithOutputAsLogs makes the manager write every line of the command's output as a
+// log record with source=pod and a stream field instead of writing it raw.
+func WithOutputAsLogs() ManagerOption {
+	return func(m *Manager) {
+		m.outputAsLogs = true
+	}
 }
 
-func NewManager(ctx context.Context, hostname string) *Manager {
-	return &Manager{
+func NewManager(ctx context.Context, hostname string, namespace string, restConfig *rest.Config, opts ...ManagerOption) *Manager {
+	m := &Manager{
 		ctx:          ctx,
 		myHostname:   hostname,
+		namespace:    namespace,
+		restConfig:   restConfig,
+		log:          logz.Default(),
+		stdout:       os.Stdout,
+		stderr:       os.Stderr,
 		invocationID: uuid.New().String(),
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.report = hipreport.New(m.invocationID)
+	m.log = m.log.With(logz.FieldOperation, m.invocationID)
+	if m.outputAsLogs {
+		m.stdout = logz.NewLineWriter(m.log.Pod().With().Str(logz.FieldStream, "stdout").Logger())
+		m.stderr = logz.NewLineWriter(m.log.Pod().With().Str(logz.FieldStream, "stderr").Logger())
+	} else {
+		// Log records are masked by logz
+		m.stdout = hipredact.NewWriter(m.stdout)
+		m.stderr = hipredact.NewWriter(m.stderr)
+	}
+	return m
+}
+
+// phaseLog returns the manager's loggers with the pod name and lifecycle phase fields.
+func (m *Manager) phaseLog(podName, phase string) *logz.Loggers {
+	return m.log.With(logz.FieldPod, podName).With(logz.FieldPhase, phase)
+}
+
+// flushOutput writes a trailing line of the command's output that did not end
+// with a newline when the output is written as log records.
+func (m *Manager) flushOutput() {
+	for _, w := range []io.Writer{m.stdout, m.stderr} {
+		if f, ok := w.(interface{ Flush() }); ok {
+			f.Flush()
+		}
+	}
+}
+
+// Report returns the report of this invocation's run.
+func (m *Manager) Report() *hipreport.Report {
+	return m.report
+}
+
+// Namespace returns the namespace the plugin pods are created in.
+func (m *Manager) Namespace() string {
+	return m.namespace
 }
+
+// OperationID returns the operation ID the pods of this invocation are labeled with.
+func (m *Manager) OperationID() string {
+	return m.invocationID
+}
+
 func (m *Manager) client() *operatorkclient.Client {
+	if m.kclient != nil {
+		return m.kclient
+	}
 	return operatorkclient.DefaultClient()
 }
 
@@ -69,18 +165,41 @@ func (m *Manager) DeleteHelmPods(execOptions cmdoptions.ExecOptions, purgeOption
 		}
 		opts.LabelSelector = selector
 	}
-	pods, err := m.client().ClientSet().CoreV1().Pods(Namespace).List(m.ctx, opts)
+	if err := m.deletePods(opts); err != nil {
+		return err
+	}
+	if purgeOptions.All {
+		// Secrets whose pod was never created are not found through the pods
+		return m.DeleteManagedSecrets(m.ctx)
+	}
+	return nil
+}
+
+// deletePods deletes the jobs and pods matching opts together with their PDBs
+// and operation secrets.
+func (m *Manager) deletePods(opts metav1.ListOptions) error {
+	// Jobs go first, otherwise the Job controller replaces the deleted pods
+	if err := m.deleteHelmJobs(opts); err != nil {
+		return err
+	}
+	pods, err := m.client().ClientSet().CoreV1().Pods(m.namespace).List(m.ctx, opts)
 	if err != nil {
 		return err
 	}
 	for i := range pods.Items {
 		pod := &pods.Items[i]
-		logz.Host().Debug().Msgf("Deleting '%v' pod", pod.Name)
+		log := m.phaseLog(pod.Name, logz.PhaseCleanup)
+		log.Host().Debug().Msgf("Deleting '%v' pod", pod.Name)
 
 		// Extract operation ID from pod labels and delete associated PDB
 		if operationID, ok := pod.Labels[hipconsts.LabelOperationID]; ok {
 			if err := m.DeletePodDisruptionBudgets(m.ctx, operationID); err != nil {
-				logz.Host().Warn().Msgf("Failed to delete PodDisruptionBudget for operation %s: %v", operationID, err)
+				log.Host().Warn().Msgf("Failed to delete PodDisruptionBudget for operation %s: %v", operationID, err)
+			}
+			if podReferencesOperationSecrets(pod) {
+				if err := m.DeleteOperationSecrets(m.ctx, operationID); err != nil {
+					log.Host().Warn().Msgf("Failed to delete secrets for operation %s: %v", operationID, err)
+				}
 			}
 		}
 
@@ -93,68 +212,93 @@ func (m *Manager) DeleteHelmPods(execOptions cmdoptions.ExecOptions, purgeOption
 			deleteOpts.GracePeriodSeconds = &zero
 		}
 
-		err = m.client().ClientSet().CoreV1().Pods(Namespace).Delete(m.ctx, pod.Name, deleteOpts)
+		err = m.client().ClientSet().CoreV1().Pods(m.namespace).Delete(m.ctx, pod.Name, deleteOpts)
 		if err != nil {
 			return err
 		}
-		logz.Host().Debug().Msgf("'%v' pod has been deleted", pod.Name)
+		log.Host().Debug().Msgf("'%v' pod has been deleted", pod.Name)
 	}
 	return nil
 }
 
-func (m *Manager) CreateHelmPod(opts cmdoptions.ExecOptions) (*corev1.Pod, error) {
-	err := m.DeleteHelmPods(opts, cmdoptions.PurgeOptions{All: false})
+func (m *Manager) CreateHelmPod(opts cmdoptions.ExecOptions) (pod *corev1.Pod, err error) {
+	ctx, span := hiptrace.Start(m.ctx, "CreateHelmPod", hiptrace.Namespace(m.namespace))
+	defer func() { hiptrace.End(span, err) }()
+	err = m.DeleteHelmPods(opts, cmdoptions.PurgeOptions{All: false})
 	if err != nil {
 		return nil, err
 	}
-	logz.Host().Info().Msgf("Creating '%v' pod", color.MagentaString(Namespace))
+	m.log.Host().Info().Msgf("Creating '%v' pod", color.MagentaString(hipconsts.HelmInPodName))
 
 	podSpec, err := buildPodSpec(opts, false)
 	if err != nil {
 		return nil, err
 	}
+	if err := m.checkEnvFrom(ctx, opts.EnvFrom); err != nil {
+		return nil, err
+	}
+	secrets, err := m.prepareOperationSecrets(opts, &podSpec)
+	if err != nil {
+		return nil, err
+	}
 
 	labels := map[string]string{
 		"host":                     m.myHostname,
 		hipconsts.LabelOperationID: m.invocationID,
-		hipconsts.LabelManagedBy:   Namespace,
+		hipconsts.LabelManagedBy:   hipconsts.HelmInPodName,
 	}
 	maps.Copy(labels, opts.Labels)
 	annotations := map[string]string{}
 	maps.Copy(annotations, opts.Annotations)
 
-	pod, err := m.client().ClientSet().CoreV1().Pods(Namespace).Create(m.ctx, &corev1.Pod{
-		ObjectMeta: metav1.ObjectMeta{
-			GenerateName: fmt.Sprintf("%v-", Namespace),
-			Labels:       labels,
-			Annotations:  annotations,
-		},
-		Spec: podSpec,
+	objectMeta := metav1.ObjectMeta{
+		GenerateName: fmt.Sprintf("%v-", hipconsts.HelmInPodName),
+		Labels:       labels,
+		Annotations:  annotations,
+	}
+	if opts.AsJob {
+		return m.createHelmJobPod(ctx, objectMeta, podSpec, secrets, opts)
+	}
+
+	pod, err = m.client().ClientSet().CoreV1().Pods(m.namespace).Create(m.ctx, &corev1.Pod{
+		ObjectMeta: objectMeta,
+		Spec:       podSpec,
 	}, metav1.CreateOptions{})
 	if err != nil {
+		m.cleanupOrphanedSecrets(secrets...)
 		return nil, err
 	}
+	if err := m.setSecretsOwner(secrets, pod); err != nil {
+		return pod, err
+	}
 
 	// Create PodDisruptionBudget for this pod if enabled
 	if opts.CreatePDB {
 		if err := m.CreatePodDisruptionBudget(m.ctx, m.invocationID); err != nil {
 			// If PDB creation fails, clean up the pod immediately
 			zero := int64(0)
-			_ = m.client().ClientSet().CoreV1().Pods(Namespace).Delete(m.ctx, pod.Name, metav1.DeleteOptions{
+			_ = m.client().ClientSet().CoreV1().Pods(m.namespace).Delete(m.ctx, pod.Name, metav1.DeleteOptions{
 				GracePeriodSeconds: &zero,
 			})
 			return nil, fmt.Errorf("failed to create PodDisruptionBudget: %w", err)
 		}
 	}
 
-	// Handle interrupt signals. done is closed when CreateHelmPod returns so
-	// the goroutine exits promptly and does not leak across invocations.
-	done := make(chan struct{})
-	defer close(done)
+	// Handle interrupt signals until CreateHelmPod returns
+	defer m.HandleInterrupts(opts)()
 
+	m.log.Host().Debug().Msgf("%v pod has been created", color.MagentaString(pod.Name))
+	span.SetAttributes(hiptrace.Pod(pod.Name))
+	return pod, m.waitUntilPodIsRunning(ctx, pod, opts.StartupTimeout)
+}
+
+// HandleInterrupts destroys the helm pods and PDBs of this invocation on the
+// first SIGINT/SIGTERM and exits on the second one. The returned function stops
+// handling signals; it must be called so the goroutine does not leak across invocations.
+func (m *Manager) HandleInterrupts(opts cmdoptions.ExecOptions) func() {
+	done := make(chan struct{})
 	c := make(chan os.Signal, 2)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	defer signal.Stop(c)
 
 	go func() {
 		select {
@@ -162,18 +306,16 @@ func (m *Manager) CreateHelmPod(opts cmdoptions.ExecOptions) (*corev1.Pod, error
 			return
 		case <-c:
 		}
-		if pod != nil && pod.Name != "" {
-			logz.Host().Warn().Msg("Interrupted! Destroying helm pod")
-			destroyErr := m.DeleteHelmPods(opts, cmdoptions.PurgeOptions{All: false})
-			if destroyErr != nil {
-				logz.Host().Error().Msgf("Couldn't destroy helm pods: %v", destroyErr.Error())
-			}
-			// Clean up PDB if it was created
-			if opts.CreatePDB {
-				_ = m.DeletePodDisruptionBudgets(m.ctx, m.invocationID)
-			}
-			m.interrupted.Store(true)
+		m.log.Host().Warn().Msg("Interrupted! Destroying helm pod")
+		destroyErr := m.DeleteHelmPods(opts, cmdoptions.PurgeOptions{All: false})
+		if destroyErr != nil {
+			m.log.Host().Error().Msgf("Couldn't destroy helm pods: %v", destroyErr.Error())
+		}
+		// Clean up PDB if it was created
+		if opts.CreatePDB {
+			_ = m.DeletePodDisruptionBudgets(m.ctx, m.invocationID)
 		}
+		m.interrupted.Store(true)
 		select {
 		case <-done:
 			return
@@ -182,8 +324,10 @@ func (m *Manager) CreateHelmPod(opts cmdoptions.ExecOptions) (*corev1.Pod, error
 		}
 	}()
 
-	logz.Host().Debug().Msgf("%v pod has been created", color.MagentaString(pod.Name))
-	return pod, m.waitUntilPodIsRunning(pod)
+	return func() {
+		signal.Stop(c)
+		close(done)
+	}
 }
 
 // isPodReady reports whether at least one of the pod's containers is marked
@@ -203,10 +347,14 @@ func isPodReady(pod *corev1.Pod) bool {
 	return false
 }
 
-func (m *Manager) waitUntilPodIsRunning(pod *corev1.Pod) error {
-	logz.Host().Info().Msgf("Waiting until %v pod is ready", color.MagentaString(pod.Name))
+// waitUntilPodIsRunning waits until pod is ready. Its span is a child of the span in ctx.
+func (m *Manager) waitUntilPodIsRunning(ctx context.Context, pod *corev1.Pod, timeout time.Duration) (err error) {
+	ctx, span := hiptrace.Start(ctx, "waitUntilPodIsRunning", hiptrace.Pod(pod.Name))
+	defer func() { hiptrace.End(span, err) }()
+	log := m.phaseLog(pod.Name, logz.PhaseStartup)
+	log.Host().Info().Msgf("Waiting until %v pod is ready", color.MagentaString(pod.Name))
 
-	err := wait.PollUntilContextTimeout(m.ctx, time.Second, 5*time.Minute, true, func(ctx context.Context) (bool, error) {
+	err = wait.PollUntilContextTimeout(ctx, time.Second, orDefault(timeout, hipconsts.DefaultStartupTimeout), true, func(ctx context.Context) (bool, error) {
 		if m.interrupted.Load() {
 			return false, fmt.Errorf("interrupted while was waiting for pod readiness")
 		}
@@ -219,33 +367,35 @@ func (m *Manager) waitUntilPodIsRunning(pod *corev1.Pod) error {
 		// run in parallel (exec opens an SPDY/WebSocket stream per call).
 		latestPod, getErr := m.client().ClientSet().CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
 		if getErr != nil {
-			logz.Pod().Debug().Msgf("Not ready yet: %v", getErr)
+			log.Pod().Debug().Msgf("Not ready yet: %v", getErr)
 			return false, nil
 		}
 		if isPodReady(latestPod) {
-			logz.Host().Debug().Msgf("%v pod is ready", color.CyanString(pod.Name))
+			log.Host().Debug().Msgf("%v pod is ready", color.CyanString(pod.Name))
+			m.report.AddPodStartup(latestPod)
 			return true, nil
 		}
 		return false, nil
 	})
 	if wait.Interrupted(err) {
-		return fmt.Errorf("timeout waiting pod readiness")
+		return fmt.Errorf("timeout waiting pod readiness, increase it with --startup-timeout")
 	}
 	return err
 }
 
-func (m *Manager) waitUntilPodIsDeleted(podName string) error {
-	logz.Host().Debug().Msgf("Waiting for pod %v to be deleted", color.CyanString(podName))
+func (m *Manager) waitUntilPodIsDeleted(podName string, timeout time.Duration) error {
+	log := m.phaseLog(podName, logz.PhaseCleanup)
+	log.Host().Debug().Msgf("Waiting for pod %v to be deleted", color.CyanString(podName))
 
-	err := wait.PollUntilContextTimeout(m.ctx, time.Second, 2*time.Minute, true, func(ctx context.Context) (bool, error) {
+	err := wait.PollUntilContextTimeout(m.ctx, time.Second, orDefault(timeout, hipconsts.DefaultDeleteTimeout), true, func(ctx context.Context) (bool, error) {
 		if m.interrupted.Load() {
 			return false, fmt.Errorf("interrupted while waiting for pod deletion")
 		}
 
-		_, getErr := m.client().ClientSet().CoreV1().Pods(Namespace).Get(ctx, podName, metav1.GetOptions{})
+		_, getErr := m.client().ClientSet().CoreV1().Pods(m.namespace).Get(ctx, podName, metav1.GetOptions{})
 		if getErr != nil {
 			if k8serrors.IsNotFound(getErr) {
-				logz.Host().Info().Msgf("Pod %v has been deleted", color.CyanString(podName))
+				log.Host().Info().Msgf("Pod %v has been deleted", color.CyanString(podName))
 				return true, nil
 			}
 			return false, fmt.Errorf("error checking pod status: %w", getErr)
@@ -258,6 +408,14 @@ func (m *Manager) waitUntilPodIsDeleted(podName string) error {
 	return err
 }
 
+// orDefault returns timeout, or def if the timeout is not set.
+func orDefault(timeout, def time.Duration) time.Duration {
+	if timeout <= 0 {
+		return def
+	}
+	return timeout
+}
+
 var helmMajorVersionRe = regexp.MustCompile(`v(\d+)\.`)
 
 // BootInfo holds pod metadata collected during the bundle copy step.
@@ -274,7 +432,11 @@ type BootInfo struct {
 // ExecInPod call and simultaneously collects boot metadata (home dir, user, helm version).
 // The pod-side command emits "HOME:::whoami:::id:::helmversion\n" on stdout, then
 // extracts the multi-entry tar from stdin at their destination paths.
-func (m *Manager) CopyFilesBundleWithBootInfo(pod *corev1.Pod, entries []helmtar.BundleEntry, cleanPaths []string, attempts int) (*BootInfo, error) {
+func (m *Manager) CopyFilesBundleWithBootInfo(pod *corev1.Pod, entries []helmtar.BundleEntry, cleanPaths []string, attempts int, timeout time.Duration) (info *BootInfo, err error) {
+	_, span := hiptrace.Start(m.ctx, "CopyFilesBundleWithBootInfo", hiptrace.Pod(pod.Name))
+	defer func() { hiptrace.End(span, err) }()
+	log := m.phaseLog(pod.Name, logz.PhaseCopy)
+	defer m.report.Start(hipreport.PhaseBundleCopy)()
 	buf := &bytes.Buffer{}
 	if err := helmtar.CompressMulti(entries, buf); err != nil {
 		return nil, fmt.Errorf("building bundle tar: %w", err)
@@ -290,14 +452,13 @@ func (m *Manager) CopyFilesBundleWithBootInfo(pod *corev1.Pod, entries []helmtar
 		cleanCmd,
 	)
 
-	var info *BootInfo
-	err := hipretry.Retry(attempts, func() error {
-		logz.HostPod().Info().Msg("Copying files bundle and collecting pod boot info")
+	err = m.report.Retry("bundle copy", attempts, func() error {
+		log.HostPod().Info().Msg("Copying files bundle and collecting pod boot info")
 
 		var stdout bytes.Buffer
-		_, stderr, execErr := m.client().ExecInPod(cmd, Namespace, pod.Name, pod.Namespace,
+		_, stderr, execErr := m.client().ExecInPod(cmd, hipconsts.HelmInPodName, pod.Name, pod.Namespace,
 			operatorkclient.WithContext(m.ctx),
-			operatorkclient.WithTimeout(time.Minute*10),
+			operatorkclient.WithTimeout(orDefault(timeout, hipconsts.DefaultCopyTimeout)),
 			operatorkclient.WithStdin(bytes.NewReader(tarBytes)),
 			operatorkclient.WithStdout(&stdout),
 		)
@@ -333,17 +494,20 @@ func (m *Manager) CopyFilesBundleWithBootInfo(pod *corev1.Pod, entries []helmtar
 			IsHelm4:       isHelm4,
 			HelmFound:     helmFound,
 		}
-		logz.HostPod().Debug().Msgf("Bundle extracted — user: %v, home: %v, helm: %v",
+		log.HostPod().Debug().Msgf("Bundle extracted — user: %v, home: %v, helm: %v",
 			color.GreenString(info.Whoami), color.MagentaString(info.HomeDirectory), color.CyanString(info.HelmVersion))
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
+	m.report.AddBytesSent(int64(len(tarBytes)))
+	m.report.SetBootInfo(hipreport.BootInfo{User: info.Whoami, ID: info.ID, Home: info.HomeDirectory, HelmVersion: info.HelmVersion})
 	return info, nil
 }
 
-func (m *Manager) CopyFileToPod(pod *corev1.Pod, srcPath string, destPath string, attempts int) error {
+func (m *Manager) CopyFileToPod(pod *corev1.Pod, srcPath string, destPath string, attempts int, timeout time.Duration) error {
+	log := m.phaseLog(pod.Name, logz.PhaseCopy)
 	buffer := &bytes.Buffer{}
 	srcPath = filepath.Clean(srcPath)
 	destPath = filepath.Clean(destPath)
@@ -358,19 +522,20 @@ func (m *Manager) CopyFileToPod(pod *corev1.Pod, srcPath string, destPath string
 	dir := filepath.Dir(destPath)
 	cmd := fmt.Sprintf("mkdir -p %s && tar zxf - -C /", dir)
 
-	return hipretry.Retry(attempts, func() error {
-		logz.HostPod().Info().Msgf("Copying %v to %v", color.CyanString(srcPath), color.MagentaString(destPath))
+	return m.report.Retry(fmt.Sprintf("copy %v", srcPath), attempts, func() error {
+		log.HostPod().Info().Msgf("Copying %v to %v", color.CyanString(srcPath), color.MagentaString(destPath))
 
-		_, stderr, err := m.client().ExecInPod(cmd, Namespace, pod.Name, pod.Namespace,
+		_, stderr, err := m.client().ExecInPod(cmd, hipconsts.HelmInPodName, pod.Name, pod.Namespace,
 			operatorkclient.WithContext(m.ctx),
-			operatorkclient.WithTimeout(time.Minute*10),
+			operatorkclient.WithTimeout(orDefault(timeout, hipconsts.DefaultCopyTimeout)),
 			operatorkclient.WithStdin(bytes.NewReader(buffer.Bytes())),
 		)
 		if err != nil {
 			return fmt.Errorf("%w: %s", err, stderr)
 		}
 
-		logz.HostPod().Debug().Msgf("%v has been copied to %v", color.CyanString(srcPath), color.MagentaString(destPath))
+		m.report.AddBytesSent(int64(buffer.Len()))
+		log.HostPod().Debug().Msgf("%v has been copied to %v", color.CyanString(srcPath), color.MagentaString(destPath))
 		return nil
 	})
 }
@@ -378,7 +543,7 @@ func (m *Manager) CopyFileToPod(pod *corev1.Pod, srcPath string, destPath string
 // isPodPathRegularFile checks whether podPath is a regular file inside the pod.
 func (m *Manager) isPodPathRegularFile(pod *corev1.Pod, podPath string) bool {
 	cmd := fmt.Sprintf("test -f %s", podPath)
-	_, _, err := m.client().ExecInPod(cmd, Namespace, pod.Name, pod.Namespace,
+	_, _, err := m.client().ExecInPod(cmd, hipconsts.HelmInPodName, pod.Name, pod.Namespace,
 		operatorkclient.WithRawCommand(true))
 	return err == nil
 }
@@ -391,11 +556,15 @@ func (m *Manager) isPodPathRegularFile(pod *corev1.Pod, podPath string) bool {
 //   - Otherwise hostPath is treated as the destination file path.
 //
 // For directories, the contents are placed directly inside hostPath.
-func (m *Manager) CopyFileFromPod(pod *corev1.Pod, podPath string, hostPath string, attempts int) error {
+func (m *Manager) CopyFileFromPod(pod *corev1.Pod, podPath string, hostPath string, attempts int, timeout time.Duration) (err error) {
+	_, span := hiptrace.Start(m.ctx, "CopyFileFromPod", hiptrace.Pod(pod.Name), attribute.String("path", podPath))
+	defer func() { hiptrace.End(span, err) }()
+	log := m.phaseLog(pod.Name, logz.PhaseCopyFrom)
+	defer m.report.Start(hipreport.PhaseCopyFrom)()
 	podPath = filepath.Clean(podPath)
 	hostPath = filepath.Clean(hostPath)
 
-	return hipretry.Retry(attempts, func() error {
+	return m.report.Retry(fmt.Sprintf("copy from %v", podPath), attempts, func() error {
 		isFile := m.isPodPathRegularFile(pod, podPath)
 
 		var tarCmd string
@@ -418,18 +587,19 @@ func (m *Manager) CopyFileFromPod(pod *corev1.Pod, podPath string, hostPath stri
 			extractDir = hostPath
 		}
 
-		logz.HostPod().Info().Msgf("Copying %v to %v", color.MagentaString(podPath), color.CyanString(hostPath))
+		log.HostPod().Info().Msgf("Copying %v to %v", color.MagentaString(podPath), color.CyanString(hostPath))
 
 		var stdout bytes.Buffer
-		_, _, err := m.client().ExecInPod(tarCmd, Namespace, pod.Name, pod.Namespace,
+		_, _, err := m.client().ExecInPod(tarCmd, hipconsts.HelmInPodName, pod.Name, pod.Namespace,
 			operatorkclient.WithContext(m.ctx),
-			operatorkclient.WithTimeout(time.Minute*10),
+			operatorkclient.WithTimeout(orDefault(timeout, hipconsts.DefaultCopyTimeout)),
 			operatorkclient.WithRawCommand(true),
 			operatorkclient.WithStdout(&stdout),
 		)
 		if err != nil {
 			return err
 		}
+		m.report.AddBytesReceived(int64(stdout.Len()))
 
 		if err := extractTarGz(&stdout, extractDir); err != nil {
 			return fmt.Errorf("failed to extract archive: %w", err)
@@ -446,7 +616,7 @@ func (m *Manager) CopyFileFromPod(pod *corev1.Pod, podPath string, hostPath stri
 			}
 		}
 
-		logz.HostPod().Debug().Msgf("%v has been copied to %v", color.MagentaString(podPath), color.CyanString(hostPath))
+		log.HostPod().Debug().Msgf("%v has been copied to %v", color.MagentaString(podPath), color.CyanString(hostPath))
 		return nil
 	})
 }
@@ -545,29 +715,33 @@ func (m *Manager) CreateDaemonPod(opts cmdoptions.DaemonOptions) (*corev1.Pod, e
 		if !opts.Force {
 			return nil, fmt.Errorf("daemon pod '%s' already exists. Use --force to recreate", opts.Name)
 		}
-		logz.Host().Info().Msgf("Force flag enabled, recreating daemon pod %v", color.CyanString(opts.Name))
-		if err := m.DeleteDaemonPod(opts.Name); err != nil {
+		m.log.Host().Info().Msgf("Force flag enabled, recreating daemon pod %v", color.CyanString(opts.Name))
+		if err := m.DeleteDaemonPod(opts.Name, opts.DeleteTimeout); err != nil {
 			return nil, fmt.Errorf("failed to delete existing daemon pod: %w", err)
 		}
 	}
 
-	logz.Host().Info().Msgf("Creating daemon pod '%v'", opts.Name)
+	m.log.Host().Info().Msgf("Creating daemon pod '%v'", opts.Name)
 
 	podSpec, err := buildDaemonPodSpec(opts.ExecOptions)
 	if err != nil {
 		return nil, err
 	}
+	credsSecret, err := m.prepareCredentialsPassthrough(opts.ExecOptions, &podSpec)
+	if err != nil {
+		return nil, err
+	}
 
 	labels := map[string]string{
 		"daemon":                   opts.Name,
 		hipconsts.LabelOperationID: m.invocationID,
-		hipconsts.LabelManagedBy:   Namespace,
+		hipconsts.LabelManagedBy:   hipconsts.HelmInPodName,
 	}
 	maps.Copy(labels, opts.Labels)
 	annotations := map[string]string{}
 	maps.Copy(annotations, opts.Annotations)
 
-	pod, err := m.client().ClientSet().CoreV1().Pods(Namespace).Create(m.ctx, &corev1.Pod{
+	pod, err := m.client().ClientSet().CoreV1().Pods(m.namespace).Create(m.ctx, &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        fmt.Sprintf("daemon-%s", opts.Name),
 			Labels:      labels,
@@ -576,59 +750,70 @@ func (m *Manager) CreateDaemonPod(opts cmdoptions.DaemonOptions) (*corev1.Pod, e
 		Spec: podSpec,
 	}, metav1.CreateOptions{})
 	if err != nil {
+		m.cleanupOrphanedSecrets(credsSecret)
 		return nil, err
 	}
+	if credsSecret != nil {
+		if err := m.SetSecretOwner(m.ctx, credsSecret, pod); err != nil {
+			return pod, err
+		}
+	}
 
 	// Create PodDisruptionBudget for this daemon pod if enabled
 	if opts.CreatePDB {
 		if err := m.CreatePodDisruptionBudget(m.ctx, m.invocationID); err != nil {
 			// If PDB creation fails, clean up the pod immediately
 			zero := int64(0)
-			_ = m.client().ClientSet().CoreV1().Pods(Namespace).Delete(m.ctx, pod.Name, metav1.DeleteOptions{
+			_ = m.client().ClientSet().CoreV1().Pods(m.namespace).Delete(m.ctx, pod.Name, metav1.DeleteOptions{
 				GracePeriodSeconds: &zero,
 			})
 			return nil, fmt.Errorf("failed to create PodDisruptionBudget: %w", err)
 		}
 	}
 
-	logz.Host().Debug().Msgf("Daemon pod %v has been created", pod.Name)
-	return pod, m.waitUntilPodIsRunning(pod)
+	m.log.Host().Debug().Msgf("Daemon pod %v has been created", pod.Name)
+	return pod, m.waitUntilPodIsRunning(m.ctx, pod, opts.StartupTimeout)
 }
 
 func (m *Manager) GetDaemonPod(name string) (*corev1.Pod, error) {
 	podName := fmt.Sprintf("daemon-%s", name)
-	pod, err := m.client().ClientSet().CoreV1().Pods(Namespace).Get(m.ctx, podName, metav1.GetOptions{})
+	pod, err := m.client().ClientSet().CoreV1().Pods(m.namespace).Get(m.ctx, podName, metav1.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("daemon pod '%s' not found: %w", name, err)
 	}
 	return pod, nil
 }
 
-func (m *Manager) DeleteDaemonPod(name string) error {
+func (m *Manager) DeleteDaemonPod(name string, timeout time.Duration) error {
 	podName := fmt.Sprintf("daemon-%s", name)
-	logz.Host().Info().Msgf("Deleting daemon pod %v", color.CyanString(podName))
+	m.log.Host().Info().Msgf("Deleting daemon pod %v", color.CyanString(podName))
 
 	// Get the pod to extract operation ID before deletion
-	pod, err := m.client().ClientSet().CoreV1().Pods(Namespace).Get(m.ctx, podName, metav1.GetOptions{})
+	pod, err := m.client().ClientSet().CoreV1().Pods(m.namespace).Get(m.ctx, podName, metav1.GetOptions{})
 	if err == nil {
 		// Extract operation ID from pod labels and delete associated PDB
 		if operationID, ok := pod.Labels[hipconsts.LabelOperationID]; ok {
 			if err := m.DeletePodDisruptionBudgets(m.ctx, operationID); err != nil {
-				logz.Host().Warn().Msgf("Failed to delete PodDisruptionBudget for operation %s: %v", operationID, err)
+				m.log.Host().Warn().Msgf("Failed to delete PodDisruptionBudget for operation %s: %v", operationID, err)
+			}
+			if podReferencesOperationSecrets(pod) {
+				if err := m.DeleteOperationSecrets(m.ctx, operationID); err != nil {
+					m.log.Host().Warn().Msgf("Failed to delete secrets for operation %s: %v", operationID, err)
+				}
 			}
 		}
 	}
 
-	err = m.client().ClientSet().CoreV1().Pods(Namespace).Delete(m.ctx, podName, metav1.DeleteOptions{})
+	err = m.client().ClientSet().CoreV1().Pods(m.namespace).Delete(m.ctx, podName, metav1.DeleteOptions{})
 	if err != nil {
 		return err
 	}
 
-	return m.waitUntilPodIsDeleted(podName)
+	return m.waitUntilPodIsDeleted(podName, timeout)
 }
 
 func (m *Manager) AnnotatePod(pod *corev1.Pod, annotations map[string]string) error {
-	return hipretry.Retry(3, func() error {
+	return m.report.Retry("annotate pod", 3, func() error {
 		// Get latest pod state before each attempt
 		latestPod, err := m.client().ClientSet().CoreV1().Pods(pod.Namespace).Get(m.ctx, pod.Name, metav1.GetOptions{})
 		if err != nil {
@@ -651,7 +836,10 @@ func (m *Manager) AnnotatePod(pod *corev1.Pod, annotations map[string]string) er
 	})
 }
 
-func (m *Manager) OpenInteractiveShell(ctx context.Context, pod *corev1.Pod, shell string) error {
+// OpenInteractiveShell opens a shell with a TTY in the pod. The local terminal
+// is put into raw mode and its size is propagated to the pod as it changes.
+// A non-zero exit status of the shell is returned as *hiperrors.ExitCodeError.
+func (m *Manager) OpenInteractiveShell(ctx context.Context, pod *corev1.Pod, opts cmdoptions.ShellOptions) error {
 	// Set up terminal for raw mode
 	oldState, err := setupTerminal()
 	if err != nil {
@@ -661,15 +849,40 @@ func (m *Manager) OpenInteractiveShell(ctx context.Context, pod *corev1.Pod, she
 		_ = restoreTerminal(oldState)
 	}()
 
-	_, _, err = m.client().ExecInPod(shell, Namespace, pod.Name, pod.Namespace,
-		operatorkclient.WithContext(ctx),
-		operatorkclient.WithTTY(true),
-		operatorkclient.WithRawCommand(true),
-		operatorkclient.WithStdin(os.Stdin),
-		operatorkclient.WithStdout(os.Stdout),
-		operatorkclient.WithStderr(os.Stderr),
-	)
-	return err
+	streamOpts := streamExecOptions{
+		Command: shellCommand(opts, os.Getenv("TERM")),
+		Stdin:   os.Stdin,
+		Stdout:  os.Stdout,
+		TTY:     true,
+	}
+	if sizeQueue := newTerminalSizeQueue(int(os.Stdout.Fd())); sizeQueue != nil {
+		defer sizeQueue.stop()
+		streamOpts.SizeQueue = sizeQueue
+	}
+	return m.streamExec(ctx, pod, streamOpts)
+}
+
+// OpenShellInHelmPod opens an interactive shell in a one-shot pod created with
+// opts.Attach, so PID 1 keeps the pod alive until the session ends or the timeout
+// is reached.
+func (m *Manager) OpenShellInHelmPod(ctx context.Context, pod *corev1.Pod, shellOpts cmdoptions.ShellOptions, opts cmdoptions.ExecOptions) error {
+	// The entrypoint waits for the wrapped script before it waits for the session
+	tempScriptFile, err := os.CreateTemp("", hipconsts.HelmInPodName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tempScriptFile.Close()
+		_ = os.RemoveAll(tempScriptFile.Name())
+	}()
+	if _, err := tempScriptFile.WriteString("#!/bin/sh\n"); err != nil {
+		return err
+	}
+	if err := m.CopyFileToPod(pod, tempScriptFile.Name(), hipconsts.WrappedScriptPath, opts.CopyAttempts, opts.CopyTimeout); err != nil {
+		return err
+	}
+
+	return m.OpenInteractiveShell(ctx, pod, shellOpts)
 }
 
 // PrintPodSpecYAML builds the pod spec and prints it as YAML without creating anything.
@@ -684,6 +897,12 @@ func (m *Manager) PrintPodSpecYAML(opts cmdoptions.ExecOptions, isDaemon bool) e
 	if err != nil {
 		return err
 	}
+	if opts.PassthroughCredentials {
+		applyCredentialsPassthrough(&podSpec, operationSecretName(credentialsSecretPurpose, "<operation-id>"))
+	}
+	if !isDaemon {
+		applySecretEnv(&podSpec, operationSecretName(envSecretPurpose, "<operation-id>"), opts.SecretEnvValues())
+	}
 
 	pod := corev1.Pod{
 		TypeMeta: metav1.TypeMeta{
@@ -691,11 +910,11 @@ func (m *Manager) PrintPodSpecYAML(opts cmdoptions.ExecOptions, isDaemon bool) e
 			Kind:       "Pod",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			GenerateName: fmt.Sprintf("%v-", Namespace),
-			Namespace:    Namespace,
+			GenerateName: fmt.Sprintf("%v-", hipconsts.HelmInPodName),
+			Namespace:    m.namespace,
 			Labels: map[string]string{
 				"host":                   m.myHostname,
-				hipconsts.LabelManagedBy: Namespace,
+				hipconsts.LabelManagedBy: hipconsts.HelmInPodName,
 			},
 			Annotations: maps.Clone(opts.Annotations),
 		},
@@ -703,7 +922,14 @@ func (m *Manager) PrintPodSpecYAML(opts cmdoptions.ExecOptions, isDaemon bool) e
 	}
 	maps.Copy(pod.Labels, opts.Labels)
 
-	data, err := json.Marshal(pod)
+	redactEnv(pod.Spec.InitContainers)
+	redactEnv(pod.Spec.Containers)
+
+	var object any = pod
+	if opts.AsJob && !isDaemon {
+		object = buildJob(pod.ObjectMeta, podSpec, opts)
+	}
+	data, err := json.Marshal(object)
 	if err != nil {
 		return fmt.Errorf("failed to marshal pod spec: %w", err)
 	}
@@ -712,11 +938,51 @@ func (m *Manager) PrintPodSpecYAML(opts cmdoptions.ExecOptions, isDaemon bool) e
 		return fmt.Errorf("failed to convert to YAML: %w", err)
 	}
 
+	if !isDaemon {
+		if err := printForwardedEnv(opts); err != nil {
+			return err
+		}
+	}
 	fmt.Println("---")
-	fmt.Print(string(yamlData))
+	fmt.Print(hipredact.String(string(yamlData)))
 	return nil
 }
 
+// printForwardedEnv prints the host variables --subst-env forwards as YAML
+// comments, with secrets masked, so it is clear what leaves the machine.
+func printForwardedEnv(opts cmdoptions.ExecOptions) error {
+	forwarded, err := opts.ForwardedEnv()
+	if err != nil || len(forwarded) == 0 {
+		return err
+	}
+	fmt.Println("# Host environment variables forwarded by --subst-env:")
+	for _, env := range forwarded {
+		name := env.Name
+		if env.HostName != env.Name {
+			name = fmt.Sprintf("%s (from %s)", env.Name, env.HostName)
+		}
+		fmt.Printf("#   %s=%s\n", name, strconv.Quote(hipredact.String(env.Value)))
+	}
+	return nil
+}
+
+// redactEnv masks the values of the containers' environment variables that
+// are secret or have sensitive names.
+func redactEnv(containers []corev1.Container) {
+	for i := range containers {
+		for j, env := range containers[i].Env {
+			if env.Value == "" {
+				continue
+			}
+			if hipredact.IsSensitiveName(env.Name) {
+				containers[i].Env[j].Value = hipredact.Mask
+				continue
+			}
+			containers[i].Env[j].Value = hipredact.String(env.Value)
+		}
+	}
+}
+
 // DaemonInfo holds information about a daemon pod for display.
 type DaemonInfo struct {
 	Name      string
@@ -732,7 +998,7 @@ type DaemonInfo struct {
 
 // ListDaemonPods returns information about all daemon pods in the namespace.
 func (m *Manager) ListDaemonPods() ([]DaemonInfo, error) {
-	pods, err := m.client().ClientSet().CoreV1().Pods(Namespace).List(m.ctx, metav1.ListOptions{
+	pods, err := m.client().ClientSet().CoreV1().Pods(m.namespace).List(m.ctx, metav1.ListOptions{
 		LabelSelector: "daemon",
 	})
 	if err != nil {
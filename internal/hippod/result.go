@@ -0,0 +1,208 @@
+package hippod
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Noksa/operator-home/pkg/operatorkclient"
+	"github.com/fatih/color"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+	"github.com/noksa/helm-in-pod/internal/hiperrors"
+	"github.com/noksa/helm-in-pod/internal/logz"
+)
+
+// errNoResult is returned when no valid result record was found, e.g. because
+// the pod was killed before the command finished.
+var errNoResult = errors.New("no command result was found")
+
+// signalNames maps the signals a command is usually killed by to their names.
+var signalNames = map[int32]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	6:  "SIGABRT",
+	9:  "SIGKILL",
+	13: "SIGPIPE",
+	15: "SIGTERM",
+}
+
+// CommandResult is the record the result runner writes when the command finishes.
+// It is the single source of truth for the command's exit code.
+type CommandResult struct {
+	ExitCode int32 `json:"exitCode"`
+	// Signal is the signal that killed the command, 0 if it exited on its own.
+	Signal          int32  `json:"signal"`
+	DurationSeconds int64  `json:"durationSeconds"`
+	Signature       string `json:"signature"`
+}
+
+// Err returns nil if the command succeeded, otherwise an *hiperrors.ExitCodeError.
+func (r *CommandResult) Err() error {
+	if r.ExitCode == 0 {
+		return nil
+	}
+	return &hiperrors.ExitCodeError{Code: r.ExitCode}
+}
+
+// SignalName returns the name of the signal that killed the command, if any.
+func (r *CommandResult) SignalName() string {
+	if r.Signal == 0 {
+		return ""
+	}
+	if name, ok := signalNames[r.Signal]; ok {
+		return name
+	}
+	return fmt.Sprintf("signal %d", r.Signal)
+}
+
+// newNonce returns a random value that signs the result record of one command run,
+// so a record left by another run or written by the command itself is rejected.
+func newNonce() string {
+	return rand.Text()
+}
+
+// resultSignature signs the result fields with nonce. The result runner computes
+// the same value with sha256sum.
+func resultSignature(nonce string, exitCode, signal int32, durationSeconds int64) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s:%d:%d:%d", nonce, exitCode, signal, durationSeconds))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseCommandResult decodes a result record and verifies that it was signed with nonce.
+func parseCommandResult(data []byte, nonce string) (*CommandResult, error) {
+	if nonce == "" {
+		return nil, errors.New("no nonce to verify the command result with")
+	}
+	result := &CommandResult{}
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, fmt.Errorf("invalid command result: %w", err)
+	}
+	expected := resultSignature(nonce, result.ExitCode, result.Signal, result.DurationSeconds)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(result.Signature)) != 1 {
+		return nil, fmt.Errorf("command result signature mismatch, the result was not written by this run")
+	}
+	return result, nil
+}
+
+// resultRunnerScript returns a script that runs the script at commandPath and
+// writes its signed result record to resultPath. The nonce is read from
+// noncePath, which is removed before the command starts, so the command can't
+// sign a record itself. The record is written to a temporary file first, so
+// readers never see a partial record. The runner exits with the command's exit code.
+func resultRunnerScript(commandPath, resultPath, noncePath string) string {
+	return fmt.Sprintf(`#!/bin/sh
+nonce=$(cat %[3]s 2>/dev/null)
+rm -f %[3]s
+start=$(date +%%s)
+sh %[1]s
+code=$?
+signal=0
+if [ "${code}" -gt 128 ]; then signal=$((code-128)); fi
+duration=$(($(date +%%s)-start))
+signature=$(printf '%%s' "${nonce}:${code}:${signal}:${duration}" | sha256sum | cut -d' ' -f1)
+printf '{"exitCode":%%s,"signal":%%s,"durationSeconds":%%s,"signature":"%%s"}\n' "${code}" "${signal}" "${duration}" "${signature}" > %[2]s.tmp && mv %[2]s.tmp %[2]s
+exit "${code}"
+`, commandPath, resultPath, noncePath)
+}
+
+// readCommandResult reads the result record signed with nonce from resultPath.
+// With a positive pollTimeout it waits up to pollTimeout for the record while
+// the command is still running, e.g. after the connection to it was lost.
+// errNoResult is returned if there is no valid record, or when polling once the
+// pod has terminated without one or pollTimeout has passed.
+func (m *Manager) readCommandResult(ctx context.Context, pod *corev1.Pod, resultPath, nonce string, pollTimeout time.Duration) (*CommandResult, error) {
+	log := m.phaseLog(pod.Name, logz.PhaseExec)
+	deadline := time.Now().Add(pollTimeout)
+	for {
+		stdout, _, err := m.client().ExecInPod(fmt.Sprintf("cat %s", resultPath),
+			hipconsts.HelmInPodName, pod.Name, pod.Namespace,
+			operatorkclient.WithContext(ctx),
+			operatorkclient.WithRawCommand(true))
+		if err == nil {
+			result, parseErr := parseCommandResult([]byte(stdout), nonce)
+			if parseErr == nil {
+				logCommandResult(log, result)
+				return result, nil
+			}
+			log.Host().Debug().Msgf("Ignoring %v: %v", resultPath, parseErr)
+		}
+		if pollTimeout <= 0 {
+			return nil, errNoResult
+		}
+		phase, phaseErr := m.GetPodPhase(ctx, pod)
+		if phaseErr == nil && (phase == corev1.PodSucceeded || phase == corev1.PodFailed) {
+			return nil, errNoResult
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w within %v", errNoResult, pollTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// commandResultErr returns the result of the command in a one-shot pod as an
+// error and lets the pod exit. Without a valid result record it falls back to
+// the pod's exit code, which the pod script takes from the wrapped script's own
+// exit status.
+func (m *Manager) commandResultErr(ctx context.Context, pod *corev1.Pod, nonce string, completionTimeout time.Duration) error {
+	log := m.phaseLog(pod.Name, logz.PhaseExec)
+	result, err := m.readCommandResult(ctx, pod, hipconsts.ResultFile, nonce, 0)
+	// Signalled before the fallback, which waits for the pod to exit
+	m.signalPod(pod, hipconsts.OutputReadFile)
+	if errors.Is(err, errNoResult) {
+		log.Host().Warn().Msgf("%v in %v pod, using the exit code of the pod", err, color.CyanString(pod.Name))
+		return m.waitForPodCompletion(ctx, pod, completionTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
+
+// commandStarted reports whether the wrapped script has started, which removes
+// noncePath before anything else. If the pod can't be checked, e.g. because
+// exec is forbidden, the command is assumed not to have started.
+func (m *Manager) commandStarted(ctx context.Context, pod *corev1.Pod, noncePath string) bool {
+	stdout, _, err := m.client().ExecInPod(fmt.Sprintf("[ -e %s ] || echo started", noncePath),
+		hipconsts.HelmInPodName, pod.Name, pod.Namespace,
+		operatorkclient.WithContext(ctx))
+	return err == nil && strings.TrimSpace(stdout) == "started"
+}
+
+// terminationMessage returns the termination message of the first terminated
+// container. The pod script hands the result record to Kubernetes as its
+// termination message, so it can be read after the pod has exited.
+func terminationMessage(statuses []corev1.ContainerStatus) string {
+	for i := range statuses {
+		if statuses[i].State.Terminated != nil {
+			return statuses[i].State.Terminated.Message
+		}
+	}
+	return ""
+}
+
+func logCommandResult(log *logz.Loggers, result *CommandResult) {
+	log.Host().Debug().Msgf("Command took %v", time.Duration(result.DurationSeconds)*time.Second)
+	if result.ExitCode == 0 {
+		return
+	}
+	if name := result.SignalName(); name != "" {
+		log.Pod().Info().Msgf("Command exited with code %d (killed by %v)", result.ExitCode, name)
+		return
+	}
+	log.Pod().Info().Msgf("Command exited with code %d", result.ExitCode)
+}
@@ -0,0 +1,123 @@
+package hippod
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/noksa/helm-in-pod/internal/hiperrors"
+)
+
+var _ = Describe("resultRunnerScript", func() {
+	var commandPath, resultPath, noncePath, runnerPath string
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		commandPath = filepath.Join(dir, "command.sh")
+		resultPath = filepath.Join(dir, "result.json")
+		noncePath = filepath.Join(dir, "nonce")
+		runnerPath = filepath.Join(dir, "runner.sh")
+	})
+
+	run := func(command, nonce string) (string, int) {
+		Expect(os.WriteFile(commandPath, []byte(command), 0o600)).To(Succeed())
+		Expect(os.WriteFile(noncePath, []byte(nonce), 0o600)).To(Succeed())
+		Expect(os.WriteFile(runnerPath, []byte(resultRunnerScript(commandPath, resultPath, noncePath)), 0o600)).To(Succeed())
+		out, err := exec.Command("sh", runnerPath).Output()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return string(out), exitErr.ExitCode()
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return string(out), 0
+	}
+
+	It("should write a signed record of a successful command", func() {
+		out, code := run(`echo '{"exitCode":1}'`, "nonce")
+		Expect(code).To(Equal(0))
+		Expect(out).To(Equal("{\"exitCode\":1}\n"))
+
+		data, err := os.ReadFile(resultPath)
+		Expect(err).NotTo(HaveOccurred())
+		result, err := parseCommandResult(data, "nonce")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.ExitCode).To(BeEquivalentTo(0))
+		Expect(result.Signal).To(BeEquivalentTo(0))
+		Expect(result.Err()).NotTo(HaveOccurred())
+	})
+
+	It("should record the exit code and exit with it", func() {
+		_, code := run("exit 3", "nonce")
+		Expect(code).To(Equal(3))
+
+		data, err := os.ReadFile(resultPath)
+		Expect(err).NotTo(HaveOccurred())
+		result, err := parseCommandResult(data, "nonce")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.ExitCode).To(BeEquivalentTo(3))
+		Expect(result.Err()).To(MatchError(&hiperrors.ExitCodeError{Code: 3}))
+	})
+
+	It("should record the signal that killed the command", func() {
+		_, code := run("kill -TERM $$", "nonce")
+		Expect(code).To(Equal(143))
+
+		data, err := os.ReadFile(resultPath)
+		Expect(err).NotTo(HaveOccurred())
+		result, err := parseCommandResult(data, "nonce")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Signal).To(BeEquivalentTo(15))
+		Expect(result.SignalName()).To(Equal("SIGTERM"))
+	})
+
+	It("should remove the nonce before the command starts", func() {
+		out, _ := run("cat "+noncePath+" 2>/dev/null || echo missing", "nonce")
+		Expect(out).To(Equal("missing\n"))
+		Expect(noncePath).NotTo(BeAnExistingFile())
+
+		data, err := os.ReadFile(resultPath)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = parseCommandResult(data, "nonce")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should not leave the temporary record behind", func() {
+		run("true", "nonce")
+		Expect(resultPath + ".tmp").NotTo(BeAnExistingFile())
+	})
+})
+
+var _ = Describe("parseCommandResult", func() {
+	It("should reject a record signed with another nonce", func() {
+		data := []byte(`{"exitCode":0,"signal":0,"durationSeconds":1,"signature":"` + resultSignature("other", 0, 0, 1) + `"}`)
+		_, err := parseCommandResult(data, "nonce")
+		Expect(err).To(MatchError(ContainSubstring("signature mismatch")))
+	})
+
+	It("should reject a record whose fields were changed", func() {
+		data := []byte(`{"exitCode":0,"signal":0,"durationSeconds":1,"signature":"` + resultSignature("nonce", 1, 0, 1) + `"}`)
+		_, err := parseCommandResult(data, "nonce")
+		Expect(err).To(MatchError(ContainSubstring("signature mismatch")))
+	})
+
+	It("should reject a record without a nonce to verify it with", func() {
+		data := []byte(`{"exitCode":0,"signal":0,"durationSeconds":1,"signature":"` + resultSignature("", 0, 0, 1) + `"}`)
+		_, err := parseCommandResult(data, "")
+		Expect(err).To(MatchError(ContainSubstring("no nonce")))
+	})
+
+	It("should reject output that is not a record", func() {
+		_, err := parseCommandResult([]byte("cat: can't open '/tmp/hip-result.json'"), "nonce")
+		Expect(err).To(MatchError(ContainSubstring("invalid command result")))
+	})
+})
+
+var _ = Describe("newNonce", func() {
+	It("should return a different value every time", func() {
+		Expect(newNonce()).NotTo(Equal(newNonce()))
+	})
+})
@@ -0,0 +1,95 @@
+package hippod
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/noksa/go-helpers/helpers/gopointer"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+)
+
+// operationSecretName returns the name of a per-operation Secret with the given purpose.
+func operationSecretName(purpose, operationID string) string {
+	return fmt.Sprintf("%s-%s-%s", hipconsts.HelmInPodName, purpose, operationID)
+}
+
+// CreateOperationSecret creates a Secret labeled with the operation ID so that it
+// is removed together with the operation's pod.
+func (m *Manager) CreateOperationSecret(ctx context.Context, name string, data map[string][]byte) (*corev1.Secret, error) {
+	secret, err := m.client().ClientSet().CoreV1().Secrets(m.namespace).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: m.namespace,
+			Labels: map[string]string{
+				hipconsts.LabelOperationID: m.invocationID,
+				hipconsts.LabelManagedBy:   hipconsts.HelmInPodName,
+			},
+		},
+		Type:      corev1.SecretTypeOpaque,
+		Immutable: gopointer.NewOf(true),
+		Data:      data,
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret: %w", err)
+	}
+	m.log.Host().Debug().Msgf("Created '%v' secret for operation %s", name, m.invocationID)
+	return secret, nil
+}
+
+// SetSecretOwner makes pod the owner of the secret, so the garbage collector
+// removes the secret even if the host never gets to clean it up.
+func (m *Manager) SetSecretOwner(ctx context.Context, secret *corev1.Secret, pod *corev1.Pod) error {
+	return m.setSecretOwnerReference(ctx, secret, metav1.OwnerReference{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Name:       pod.Name,
+		UID:        pod.UID,
+	})
+}
+
+func (m *Manager) setSecretOwnerReference(ctx context.Context, secret *corev1.Secret, owner metav1.OwnerReference) error {
+	secret.OwnerReferences = append(secret.OwnerReferences, owner)
+	_, err := m.client().ClientSet().CoreV1().Secrets(m.namespace).Update(ctx, secret, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to set owner of '%v' secret: %w", secret.Name, err)
+	}
+	return nil
+}
+
+// DeleteManagedSecrets deletes the per-operation Secrets of all operations.
+func (m *Manager) DeleteManagedSecrets(ctx context.Context) error {
+	err := m.client().ClientSet().CoreV1().Secrets(m.namespace).DeleteCollection(
+		ctx,
+		metav1.DeleteOptions{},
+		metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s,%s", hipconsts.LabelManagedBy, hipconsts.HelmInPodName, hipconsts.LabelOperationID),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete secrets: %w", err)
+	}
+	m.log.Host().Debug().Msg("Deleted secrets of all operations")
+	return nil
+}
+
+// DeleteOperationSecrets deletes all Secrets matching the given operation ID
+func (m *Manager) DeleteOperationSecrets(ctx context.Context, operationID string) error {
+	labelSelector := fmt.Sprintf("%s=%s", hipconsts.LabelOperationID, operationID)
+
+	err := m.client().ClientSet().CoreV1().Secrets(m.namespace).DeleteCollection(
+		ctx,
+		metav1.DeleteOptions{},
+		metav1.ListOptions{
+			LabelSelector: labelSelector,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete secrets: %w", err)
+	}
+
+	m.log.Host().Debug().Msgf("Deleted secrets for operation %s", operationID)
+	return nil
+}
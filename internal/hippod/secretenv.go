@@ -0,0 +1,104 @@
+package hippod
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+)
+
+const envSecretPurpose = "env"
+
+// secretEnvScript exports the variables written by secretEnvInput to its stdin,
+// so daemon exec never stores their values in the pod.
+const secretEnvScript = `while IFS= read -r hip_env && [ -n "${hip_env}" ]; do
+  export "${hip_env%%=*}=$(printf '%s' "${hip_env#*=}" | base64 -d)"
+done
+unset hip_env
+`
+
+// applySecretEnv references the variables in env from the Secret secretName
+// through secretKeyRef, in the order of their names.
+func applySecretEnv(podSpec *corev1.PodSpec, secretName string, env map[string]string) {
+	container := &podSpec.Containers[0]
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+					Key:                  name,
+				},
+			},
+		})
+	}
+}
+
+// prepareSecretEnv creates the Secret holding the --secret-env and
+// --secret-subst-env values and references it from podSpec. It returns nil if
+// there are no such variables.
+func (m *Manager) prepareSecretEnv(ctx context.Context, opts cmdoptions.ExecOptions, podSpec *corev1.PodSpec) (*corev1.Secret, error) {
+	env := opts.SecretEnvValues()
+	if len(env) == 0 {
+		return nil, nil
+	}
+	data := make(map[string][]byte, len(env))
+	for name, value := range env {
+		data[name] = []byte(value)
+	}
+	secret, err := m.CreateOperationSecret(ctx, operationSecretName(envSecretPurpose, m.invocationID), data)
+	if err != nil {
+		return nil, err
+	}
+	applySecretEnv(podSpec, secret.Name, env)
+	return secret, nil
+}
+
+// prepareOperationSecrets creates the per-operation Secrets the pod needs and
+// wires them into podSpec.
+func (m *Manager) prepareOperationSecrets(opts cmdoptions.ExecOptions, podSpec *corev1.PodSpec) ([]*corev1.Secret, error) {
+	var secrets []*corev1.Secret
+	credsSecret, err := m.prepareCredentialsPassthrough(opts, podSpec)
+	if err != nil {
+		return nil, err
+	}
+	if credsSecret != nil {
+		secrets = append(secrets, credsSecret)
+	}
+	envSecret, err := m.prepareSecretEnv(m.ctx, opts, podSpec)
+	if err != nil {
+		m.cleanupOrphanedSecrets(secrets...)
+		return nil, err
+	}
+	if envSecret != nil {
+		secrets = append(secrets, envSecret)
+	}
+	return secrets, nil
+}
+
+// setSecretsOwner makes pod the owner of the secrets.
+func (m *Manager) setSecretsOwner(secrets []*corev1.Secret, pod *corev1.Pod) error {
+	for _, secret := range secrets {
+		if err := m.SetSecretOwner(m.ctx, secret, pod); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// secretEnvInput encodes env for secretEnvScript: a NAME=base64(value) line per
+// variable, followed by an empty line.
+func secretEnvInput(env map[string]string) string {
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		fmt.Fprintf(&b, "%s=%s\n", name, base64.StdEncoding.EncodeToString([]byte(env[name])))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package hippod
+
+import (
+	"os/exec"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+)
+
+var _ = Describe("Secret environment variables", func() {
+	Describe("applySecretEnv", func() {
+		It("should reference every variable from the Secret in name order", func() {
+			podSpec := corev1.PodSpec{Containers: []corev1.Container{{Name: hipconsts.HelmInPodName}}}
+			applySecretEnv(&podSpec, "helm-in-pod-env-abc", map[string]string{"TOKEN": "t", "DB_PASSWORD": "p"})
+
+			env := podSpec.Containers[0].Env
+			Expect(env).To(HaveLen(2))
+			Expect(env[0].Name).To(Equal("DB_PASSWORD"))
+			Expect(env[0].Value).To(BeEmpty())
+			Expect(env[0].ValueFrom.SecretKeyRef.Name).To(Equal("helm-in-pod-env-abc"))
+			Expect(env[0].ValueFrom.SecretKeyRef.Key).To(Equal("DB_PASSWORD"))
+			Expect(env[1].Name).To(Equal("TOKEN"))
+		})
+
+		It("should make cleanup delete the operation's secrets", func() {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{hipconsts.LabelOperationID: "abc"}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: hipconsts.HelmInPodName}}},
+			}
+			applySecretEnv(&pod.Spec, operationSecretName(envSecretPurpose, "abc"), map[string]string{"TOKEN": "t"})
+			Expect(podReferencesOperationSecrets(pod)).To(BeTrue())
+		})
+	})
+
+	Describe("secretEnvScript", func() {
+		It("should export the variables read from stdin", func() {
+			env := map[string]string{
+				"DB_PASSWORD": `p@ss "word" $HOME`,
+				"MULTILINE":   "line 1\nline 2",
+				"EMPTY":       "",
+			}
+			script := "set -eu\n" + secretEnvScript + `printf '%s|%s|%s|%s' "${DB_PASSWORD}" "${MULTILINE}" "${EMPTY}" "$(cat)"`
+			cmd := exec.Command("sh", "-c", script)
+			cmd.Stdin = strings.NewReader(secretEnvInput(env) + "rest of stdin")
+			out, err := cmd.Output()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal("p@ss \"word\" $HOME|line 1\nline 2||rest of stdin"))
+		})
+
+		It("should encode the values as base64 lines", func() {
+			Expect(secretEnvInput(map[string]string{"TOKEN": "hunter22"})).To(Equal("TOKEN=aHVudGVyMjI=\n\n"))
+		})
+	})
+})
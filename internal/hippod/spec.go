@@ -2,7 +2,6 @@ package hippod
 
 import (
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 
@@ -94,13 +93,42 @@ func parseVolume(s string) (corev1.Volume, corev1.VolumeMount, error) {
 	return vol, mount, nil
 }
 
+// parseEnvFrom parses an --env-from source in format: type:name[:prefix]
+// Supported types:
+//
+//	secret:secret-name[:PREFIX_]
+//	configmap:cm-name[:PREFIX_]
+func parseEnvFrom(s string) (corev1.EnvFromSource, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) < 2 || parts[1] == "" {
+		return corev1.EnvFromSource{}, fmt.Errorf("expected format type:name[:prefix], got %q", s)
+	}
+	source := corev1.EnvFromSource{}
+	if len(parts) == 3 {
+		source.Prefix = parts[2]
+	}
+	ref := corev1.LocalObjectReference{Name: parts[1]}
+	switch strings.ToLower(parts[0]) {
+	case "secret":
+		source.SecretRef = &corev1.SecretEnvSource{LocalObjectReference: ref}
+	case "configmap":
+		source.ConfigMapRef = &corev1.ConfigMapEnvSource{LocalObjectReference: ref}
+	default:
+		return corev1.EnvFromSource{}, fmt.Errorf("unsupported source type %q, supported: secret, configmap", parts[0])
+	}
+	return source, nil
+}
+
 func buildPodSpec(opts cmdoptions.ExecOptions, daemon bool) (corev1.PodSpec, error) {
 	var envVars []corev1.EnvVar
-	for _, env := range opts.SubstEnv {
-		val := os.Getenv(env)
+	forwarded, err := opts.ForwardedEnv()
+	if err != nil {
+		return corev1.PodSpec{}, err
+	}
+	for _, env := range forwarded {
 		envVars = append(envVars, corev1.EnvVar{
-			Name:  env,
-			Value: val,
+			Name:  env.Name,
+			Value: env.Value,
 		})
 	}
 	for k, v := range opts.Env {
@@ -114,6 +142,20 @@ func buildPodSpec(opts cmdoptions.ExecOptions, daemon bool) (corev1.PodSpec, err
 		Value: strconv.Itoa(int(opts.Timeout.Seconds())),
 	})
 
+	if !daemon && opts.Attach {
+		envVars = append(envVars, corev1.EnvVar{
+			Name:  hipconsts.EnvAttach,
+			Value: "1",
+		})
+	}
+
+	if !daemon && opts.Detach {
+		envVars = append(envVars, corev1.EnvVar{
+			Name:  hipconsts.EnvDetach,
+			Value: "1",
+		})
+	}
+
 	if !daemon && len(opts.CopyFrom) > 0 {
 		envVars = append(envVars, corev1.EnvVar{
 			Name:  hipconsts.EnvWaitCopyDone,
@@ -121,6 +163,26 @@ func buildPodSpec(opts cmdoptions.ExecOptions, daemon bool) (corev1.PodSpec, err
 		})
 	}
 
+	// The pod script falls back to the default timeouts when these are not set
+	if !daemon && len(opts.CopyFrom) > 0 && opts.CopyFromWait > 0 {
+		envVars = append(envVars, corev1.EnvVar{
+			Name:  hipconsts.EnvCopyFromWait,
+			Value: strconv.Itoa(int(opts.CopyFromWait.Seconds())),
+		})
+	}
+	if !daemon && !opts.Detach && opts.ReadWait > 0 {
+		envVars = append(envVars, corev1.EnvVar{
+			Name:  hipconsts.EnvReadWait,
+			Value: strconv.Itoa(int(opts.ReadWait.Seconds())),
+		})
+	}
+	if !daemon && opts.TerminateTimeout > 0 {
+		envVars = append(envVars, corev1.EnvVar{
+			Name:  hipconsts.EnvTerminateTimeout,
+			Value: strconv.Itoa(int(opts.TerminateTimeout.Seconds())),
+		})
+	}
+
 	requests := corev1.ResourceList{}
 	limits := corev1.ResourceList{}
 
@@ -173,18 +235,28 @@ func buildPodSpec(opts cmdoptions.ExecOptions, daemon bool) (corev1.PodSpec, err
 		volumeMounts = append(volumeMounts, mount)
 	}
 
-	serviceAccountName := Namespace
+	var envFrom []corev1.EnvFromSource
+	for _, s := range opts.EnvFrom {
+		source, err := parseEnvFrom(s)
+		if err != nil {
+			return corev1.PodSpec{}, fmt.Errorf("invalid --env-from %q: %w", s, err)
+		}
+		envFrom = append(envFrom, source)
+	}
+
+	serviceAccountName := hipconsts.HelmInPodName
 	if opts.ServiceAccount != "" {
 		serviceAccountName = opts.ServiceAccount
 	}
 
 	podSpec := corev1.PodSpec{
 		Containers: []corev1.Container{{
-			Name:            Namespace,
+			Name:            hipconsts.HelmInPodName,
 			ImagePullPolicy: corev1.PullPolicy(opts.PullPolicy),
 			Image:           opts.Image,
 			Command:         []string{"sh", "-cue"},
 			Env:             envVars,
+			EnvFrom:         envFrom,
 			SecurityContext: securityContext,
 			Args:            []string{hipembedded.GetShScript()},
 			WorkingDir:      "/",
@@ -205,7 +277,7 @@ func buildPodSpec(opts cmdoptions.ExecOptions, daemon bool) (corev1.PodSpec, err
 		RestartPolicy:                 corev1.RestartPolicyNever,
 		ServiceAccountName:            serviceAccountName,
 		AutomountServiceAccountToken:  gopointer.NewOf(true),
-		TerminationGracePeriodSeconds: gopointer.NewOf[int64](300),
+		TerminationGracePeriodSeconds: gopointer.NewOf(int64(orDefault(opts.GracePeriod, hipconsts.DefaultGracePeriod).Seconds())),
 	}
 	if len(requests) > 0 || len(limits) > 0 {
 		podSpec.Containers[0].Resources = corev1.ResourceRequirements{
@@ -254,7 +326,7 @@ func buildPodSpec(opts cmdoptions.ExecOptions, daemon bool) (corev1.PodSpec, err
 			WhenUnsatisfiable: corev1.ScheduleAnyway,
 			LabelSelector: &metav1.LabelSelector{
 				MatchLabels: map[string]string{
-					hipconsts.LabelManagedBy: Namespace,
+					hipconsts.LabelManagedBy: hipconsts.HelmInPodName,
 				},
 			},
 		},
@@ -312,5 +384,6 @@ func buildDaemonPodSpec(opts cmdoptions.ExecOptions) (corev1.PodSpec, error) {
 	podSpec.Containers[0].Command = []string{"sh", "-c"}
 	podSpec.Containers[0].Args = []string{"touch /tmp/ready && trap 'exit 0' TERM INT; sleep infinity & wait"}
 	podSpec.Containers[0].Env = nil
+	podSpec.Containers[0].EnvFrom = nil
 	return podSpec, nil
 }
@@ -9,6 +9,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 
 	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
 )
 
 var _ = Describe("buildPodSpec", func() {
@@ -85,6 +86,29 @@ var _ = Describe("buildPodSpec", func() {
 			envNames := envVarNames(spec.Containers[0].Env)
 			Expect(envNames).To(ContainElements("MY_VAR", "HOME", "TIMEOUT"))
 		})
+
+		It("should forward --subst-env patterns and renames", func() {
+			GinkgoT().Setenv("HIP_TEST_FWD_A", "a")
+			GinkgoT().Setenv("HIP_TEST_FWD_B", "b")
+			GinkgoT().Setenv("HIP_TEST_FWD_SKIP", "skip")
+			opts := baseOpts()
+			opts.SubstEnv = []string{"HIP_TEST_FWD_*", "HIP_TEST_FWD_A=RENAMED"}
+			opts.SubstEnvExclude = []string{"*_SKIP"}
+			spec, err := buildPodSpec(opts, false)
+			Expect(err).NotTo(HaveOccurred())
+
+			envNames := envVarNames(spec.Containers[0].Env)
+			Expect(envNames).To(ContainElements("HIP_TEST_FWD_A", "HIP_TEST_FWD_B", "RENAMED"))
+			Expect(envNames).NotTo(ContainElement("HIP_TEST_FWD_SKIP"))
+			Expect(findEnvVar(spec.Containers[0].Env, "RENAMED")).To(Equal("a"))
+		})
+
+		It("should reject an invalid --subst-env rename", func() {
+			opts := baseOpts()
+			opts.SubstEnv = []string{"HOME=MY-HOME"}
+			_, err := buildPodSpec(opts, false)
+			Expect(err).To(MatchError(ContainSubstring("invalid --subst-env")))
+		})
 	})
 
 	Context("resource requests and limits", func() {
@@ -516,13 +540,13 @@ var _ = Describe("buildPodSpec", func() {
 			Expect(spec.ServiceAccountName).To(Equal("my-custom-sa"))
 		})
 
-		It("should default to Namespace when service account is empty", func() {
+		It("should default to helm-in-pod when service account is empty", func() {
 			opts := baseOpts()
 			opts.ServiceAccount = ""
 			spec, err := buildPodSpec(opts, false)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(spec.ServiceAccountName).To(Equal(Namespace))
+			Expect(spec.ServiceAccountName).To(Equal(hipconsts.HelmInPodName))
 		})
 	})
 
@@ -538,7 +562,7 @@ var _ = Describe("buildPodSpec", func() {
 			Expect(tsc.TopologyKey).To(Equal("kubernetes.io/hostname"))
 			Expect(tsc.WhenUnsatisfiable).To(Equal(corev1.ScheduleAnyway))
 			Expect(tsc.LabelSelector).NotTo(BeNil())
-			Expect(tsc.LabelSelector.MatchLabels).To(HaveKeyWithValue("app.kubernetes.io/managed-by", Namespace))
+			Expect(tsc.LabelSelector.MatchLabels).To(HaveKeyWithValue("app.kubernetes.io/managed-by", hipconsts.HelmInPodName))
 		})
 	})
 
@@ -571,6 +595,96 @@ var _ = Describe("buildPodSpec", func() {
 		})
 	})
 
+	Context("attach env injection", func() {
+		It("should set HIP_ATTACH when Attach is enabled", func() {
+			opts := baseOpts()
+			opts.Attach = true
+			spec, err := buildPodSpec(opts, false)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(findEnvVar(spec.Containers[0].Env, hipconsts.EnvAttach)).To(Equal("1"))
+		})
+
+		It("should not set HIP_ATTACH by default", func() {
+			spec, err := buildPodSpec(baseOpts(), false)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(envVarNames(spec.Containers[0].Env)).NotTo(ContainElement(hipconsts.EnvAttach))
+		})
+
+		It("should not set HIP_ATTACH in daemon mode", func() {
+			opts := baseOpts()
+			opts.Attach = true
+			spec, err := buildPodSpec(opts, true)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(envVarNames(spec.Containers[0].Env)).NotTo(ContainElement(hipconsts.EnvAttach))
+		})
+	})
+
+	Context("detach env injection", func() {
+		It("should set HIP_DETACH when Detach is enabled", func() {
+			opts := baseOpts()
+			opts.Detach = true
+			spec, err := buildPodSpec(opts, false)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(findEnvVar(spec.Containers[0].Env, hipconsts.EnvDetach)).To(Equal("1"))
+		})
+
+		It("should not set HIP_DETACH by default", func() {
+			spec, err := buildPodSpec(baseOpts(), false)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(envVarNames(spec.Containers[0].Env)).NotTo(ContainElement(hipconsts.EnvDetach))
+		})
+	})
+
+	Context("phase timeout env injection", func() {
+		It("should pass the terminate timeout and copy-from wait to the pod script", func() {
+			opts := baseOpts()
+			opts.CopyFrom = []string{"/tmp/out:/local/out"}
+			opts.TerminateTimeout = 90 * time.Second
+			opts.CopyFromWait = 10 * time.Minute
+			spec, err := buildPodSpec(opts, false)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(findEnvVar(spec.Containers[0].Env, hipconsts.EnvTerminateTimeout)).To(Equal("90"))
+			Expect(findEnvVar(spec.Containers[0].Env, hipconsts.EnvCopyFromWait)).To(Equal("600"))
+		})
+
+		It("should pass the read wait to the pod script unless detached", func() {
+			opts := baseOpts()
+			opts.ReadWait = 2 * time.Minute
+			spec, err := buildPodSpec(opts, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(findEnvVar(spec.Containers[0].Env, hipconsts.EnvReadWait)).To(Equal("120"))
+
+			opts.Detach = true
+			spec, err = buildPodSpec(opts, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(envVarNames(spec.Containers[0].Env)).NotTo(ContainElement(hipconsts.EnvReadWait))
+		})
+
+		It("should not pass the copy-from wait without copy-from", func() {
+			opts := baseOpts()
+			opts.CopyFromWait = 10 * time.Minute
+			spec, err := buildPodSpec(opts, false)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(envVarNames(spec.Containers[0].Env)).NotTo(ContainElement(hipconsts.EnvCopyFromWait))
+		})
+
+		It("should not pass the pod script timeouts to daemon pods", func() {
+			opts := baseOpts()
+			opts.TerminateTimeout = 90 * time.Second
+			spec, err := buildPodSpec(opts, true)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(envVarNames(spec.Containers[0].Env)).NotTo(ContainElement(hipconsts.EnvTerminateTimeout))
+		})
+	})
+
 	Context("pod defaults", func() {
 		It("should set restart policy to Never", func() {
 			opts := baseOpts()
@@ -585,7 +699,7 @@ var _ = Describe("buildPodSpec", func() {
 			spec, err := buildPodSpec(opts, false)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(spec.ServiceAccountName).To(Equal(Namespace))
+			Expect(spec.ServiceAccountName).To(Equal(hipconsts.HelmInPodName))
 		})
 
 		It("should enable automount service account token", func() {
@@ -606,6 +720,15 @@ var _ = Describe("buildPodSpec", func() {
 			Expect(*spec.TerminationGracePeriodSeconds).To(Equal(int64(300)))
 		})
 
+		It("should set termination grace period from GracePeriod", func() {
+			opts := baseOpts()
+			opts.GracePeriod = 10 * time.Minute
+			spec, err := buildPodSpec(opts, false)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(*spec.TerminationGracePeriodSeconds).To(Equal(int64(600)))
+		})
+
 		It("should have a startup probe", func() {
 			opts := baseOpts()
 			spec, err := buildPodSpec(opts, false)
@@ -0,0 +1,68 @@
+package hippod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+	"github.com/noksa/helm-in-pod/internal/hiperrors"
+)
+
+// streamExecOptions configures a long-running exec session.
+type streamExecOptions struct {
+	Command []string
+	Stdin   io.Reader
+	Stdout  io.Writer
+	Stderr  io.Writer
+	TTY     bool
+	// SizeQueue reports terminal size changes when TTY is set.
+	SizeQueue remotecommand.TerminalSizeQueue
+}
+
+// streamExec runs a command in the plugin container and streams its output to
+// the given writers as it is produced. Unlike ExecInPod, it neither buffers the
+// output in memory nor holds the per-pod exec lock, so short control commands
+// (e.g. signaling PID 1 on timeout) can run while the session is open.
+// A non-zero exit status is returned as *hiperrors.ExitCodeError.
+func (m *Manager) streamExec(ctx context.Context, pod *corev1.Pod, opts streamExecOptions) error {
+	req := m.client().ClientSet().CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pod.Name).
+		Namespace(pod.Namespace).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Command:   opts.Command,
+			Container: hipconsts.HelmInPodName,
+			Stdin:     opts.Stdin != nil,
+			Stdout:    opts.Stdout != nil,
+			Stderr:    opts.Stderr != nil && !opts.TTY,
+			TTY:       opts.TTY,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(m.client().Config(), "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("failed to create executor: %w", err)
+	}
+	streamOpts := remotecommand.StreamOptions{
+		Stdin:             opts.Stdin,
+		Stdout:            opts.Stdout,
+		Tty:               opts.TTY,
+		TerminalSizeQueue: opts.SizeQueue,
+	}
+	if !opts.TTY {
+		streamOpts.Stderr = opts.Stderr
+	}
+	err = executor.StreamWithContext(ctx, streamOpts)
+	var exitErr utilexec.CodeExitError
+	if errors.As(err, &exitErr) {
+		return &hiperrors.ExitCodeError{Code: int32(exitErr.Code)}
+	}
+	return err
+}
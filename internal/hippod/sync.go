@@ -0,0 +1,195 @@
+package hippod
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Noksa/operator-home/pkg/operatorkclient"
+	"github.com/fatih/color"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+	"github.com/noksa/helm-in-pod/internal/helmtar"
+	"github.com/noksa/helm-in-pod/internal/helpers"
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+	"github.com/noksa/helm-in-pod/internal/hipreport"
+	"github.com/noksa/helm-in-pod/internal/hipsync"
+	"github.com/noksa/helm-in-pod/internal/logz"
+)
+
+// SyncUserFiles syncs the --copy mappings to the daemon pod. Only new and
+// changed files are sent, in a single bundle, and files removed on the host
+// are deleted from the pod. The synced files are recorded in a manifest in
+// homeDirectory. cleanPaths are deleted in the pod before syncing.
+func (m *Manager) SyncUserFiles(pod *corev1.Pod, opts cmdoptions.ExecOptions, expandPath func(string) (string, error), homeDirectory string, cleanPaths []string) error {
+	log := m.phaseLog(pod.Name, logz.PhaseCopy)
+	defer m.report.Start(hipreport.PhaseBundleCopy)()
+	mappings := make([]helmtar.BundleEntry, 0, len(opts.FilesAsMap))
+	for k, v := range opts.FilesAsMap {
+		src, err := expandPath(k)
+		if err != nil {
+			return err
+		}
+		mappings = append(mappings, helmtar.BundleEntry{SrcPath: filepath.Clean(src), DestPath: v, Excludes: opts.CopyExclude})
+	}
+
+	manifestPath := path.Join(homeDirectory, hipconsts.SyncManifestName)
+	manifest, err := m.readSyncManifest(pod, manifestPath, opts)
+	if err != nil {
+		return err
+	}
+
+	// Delete specified paths first to ensure clean state
+	if len(cleanPaths) > 0 {
+		cmd := fmt.Sprintf("rm -rf -- %s", helpers.ShellQuoteAll(cleanPaths))
+		log.Pod().Debug().Msgf("Cleaning up files: %v", cmd)
+		stdOut, stdErr, err := m.client().ExecInPod(cmd, hipconsts.HelmInPodName, pod.Name, pod.Namespace)
+		if err != nil {
+			return fmt.Errorf("%v\n%v\n%v", err, stdErr, stdOut)
+		}
+		for _, p := range cleanPaths {
+			manifest.Forget(p)
+		}
+	}
+
+	if err := m.verifySyncManifest(pod, manifest, opts); err != nil {
+		return err
+	}
+
+	plan, err := hipsync.NewPlan(mappings, manifest)
+	if err != nil {
+		return err
+	}
+	if plan.Empty() {
+		log.HostPod().Info().Msg("Files are up to date")
+		return nil
+	}
+	return m.applySyncPlan(pod, plan, manifestPath, opts)
+}
+
+// readSyncManifest reads the manifest of the files synced to the pod. A missing
+// or unreadable manifest gives an empty one, so all files are sent.
+func (m *Manager) readSyncManifest(pod *corev1.Pod, manifestPath string, opts cmdoptions.ExecOptions) (*hipsync.Manifest, error) {
+	log := m.phaseLog(pod.Name, logz.PhaseCopy)
+	cmd := fmt.Sprintf("cat %s 2>/dev/null || true", helpers.ShellQuote(manifestPath))
+	var data string
+	err := m.report.Retry("read sync manifest", opts.CopyAttempts, func() error {
+		stdout, stderr, err := m.client().ExecInPod(cmd, hipconsts.HelmInPodName, pod.Name, pod.Namespace,
+			operatorkclient.WithContext(m.ctx))
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, stderr)
+		}
+		data = stdout
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.report.AddBytesReceived(int64(len(data)))
+	manifest, err := hipsync.ParseManifest([]byte(data))
+	if err != nil {
+		log.Pod().Warn().Msgf("Sending all files: %v", err)
+		return hipsync.NewManifest(), nil
+	}
+	return manifest, nil
+}
+
+// verifySyncManifest hashes the synced files in the pod and drops the ones that
+// were changed or deleted there since the last sync from manifest, so they are
+// sent again. Without sha256sum in the image all files are sent.
+func (m *Manager) verifySyncManifest(pod *corev1.Pod, manifest *hipsync.Manifest, opts cmdoptions.ExecOptions) error {
+	if len(manifest.Files) == 0 {
+		return nil
+	}
+	log := m.phaseLog(pod.Name, logz.PhaseCopy)
+	paths := strings.Join(manifest.Paths(), "\x00") + "\x00"
+	var sums string
+	err := m.report.Retry("verify synced files", opts.CopyAttempts, func() error {
+		stdout, stderr, err := m.client().ExecInPod("xargs -0 sha256sum -- 2>/dev/null || true", hipconsts.HelmInPodName, pod.Name, pod.Namespace,
+			operatorkclient.WithContext(m.ctx),
+			operatorkclient.WithTimeout(orDefault(opts.CopyTimeout, hipconsts.DefaultCopyTimeout)),
+			operatorkclient.WithStdin(strings.NewReader(paths)),
+		)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, stderr)
+		}
+		sums = stdout
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	m.report.AddBytesSent(int64(len(paths)))
+	m.report.AddBytesReceived(int64(len(sums)))
+	dropped := manifest.Verify(sums)
+	for _, p := range dropped {
+		log.Pod().Debug().Msgf("%v was changed in the pod", color.MagentaString(p))
+	}
+	if len(dropped) > 0 {
+		log.Pod().Info().Msgf("%v synced files were changed in the pod and will be sent again", color.CyanString("%d", len(dropped)))
+	}
+	return nil
+}
+
+// applySyncPlan deletes the removed files in the pod and extracts a bundle with
+// the changed files and the new manifest.
+func (m *Manager) applySyncPlan(pod *corev1.Pod, plan *hipsync.Plan, manifestPath string, opts cmdoptions.ExecOptions) error {
+	log := m.phaseLog(pod.Name, logz.PhaseCopy)
+	data, err := plan.Manifest.Marshal()
+	if err != nil {
+		return err
+	}
+	manifestFile, err := os.CreateTemp("", "hip-sync-manifest-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(manifestFile.Name()) }()
+	_, err = manifestFile.Write(data)
+	if closeErr := manifestFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	// The manifest goes last, so it is only updated once the files are in place
+	files := slices.Concat(plan.Upload, []helmtar.BundleEntry{{SrcPath: manifestFile.Name(), DestPath: manifestPath}})
+	buffer := &bytes.Buffer{}
+	if err := helmtar.CompressFiles(files, buffer); err != nil {
+		return err
+	}
+	for _, f := range plan.Upload {
+		log.HostPod().Debug().Msgf("%v will be copied to %v", color.CyanString(f.SrcPath), color.MagentaString(f.DestPath))
+	}
+	for _, p := range plan.Remove {
+		log.Pod().Debug().Msgf("%v will be deleted", color.MagentaString(p))
+	}
+
+	cmd := "tar zxf - -C /"
+	if len(plan.Remove) > 0 {
+		cmd = fmt.Sprintf("rm -f -- %s && %s", helpers.ShellQuoteAll(plan.Remove), cmd)
+	}
+
+	return m.report.Retry("sync files", opts.CopyAttempts, func() error {
+		log.HostPod().Info().Msgf("Syncing files: %v changed, %v removed",
+			color.CyanString("%d", len(plan.Upload)), color.CyanString("%d", len(plan.Remove)))
+
+		_, stderr, err := m.client().ExecInPod(cmd, hipconsts.HelmInPodName, pod.Name, pod.Namespace,
+			operatorkclient.WithContext(m.ctx),
+			operatorkclient.WithTimeout(orDefault(opts.CopyTimeout, hipconsts.DefaultCopyTimeout)),
+			operatorkclient.WithStdin(bytes.NewReader(buffer.Bytes())),
+		)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, stderr)
+		}
+
+		m.report.AddBytesSent(int64(buffer.Len()))
+		log.HostPod().Debug().Msg("Files have been synced")
+		return nil
+	})
+}
@@ -1,9 +1,14 @@
 package hippod
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"golang.org/x/term"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
 )
 
 // setupTerminal puts the terminal into raw mode for interactive shell
@@ -30,3 +35,82 @@ func restoreTerminal(oldState *term.State) error {
 	fd := int(os.Stdin.Fd())
 	return term.Restore(fd, oldState)
 }
+
+// defaultTerm is used for TERM in the pod when it is not set on the host.
+const defaultTerm = "xterm-256color"
+
+// terminalSizeQueue implements remotecommand.TerminalSizeQueue. It reports the
+// initial size of the local terminal and then every change of it.
+type terminalSizeQueue struct {
+	fd     int
+	last   remotecommand.TerminalSize
+	resize chan remotecommand.TerminalSize
+	done   chan struct{}
+}
+
+// newTerminalSizeQueue starts watching the terminal behind fd. It returns nil if
+// fd is not a terminal. Call stop when the session ends.
+func newTerminalSizeQueue(fd int) *terminalSizeQueue {
+	if !term.IsTerminal(fd) {
+		return nil
+	}
+	q := &terminalSizeQueue{
+		fd:     fd,
+		resize: make(chan remotecommand.TerminalSize, 1),
+		done:   make(chan struct{}),
+	}
+	q.update()
+	go watchTerminalResize(q.done, q.update)
+	return q
+}
+
+// update queues the current terminal size if it changed, replacing a size that
+// has not been consumed yet.
+func (q *terminalSizeQueue) update() {
+	width, height, err := term.GetSize(q.fd)
+	if err != nil || width <= 0 || height <= 0 {
+		return
+	}
+	size := remotecommand.TerminalSize{Width: uint16(width), Height: uint16(height)}
+	if size == q.last {
+		return
+	}
+	q.last = size
+	select {
+	case <-q.resize:
+	default:
+	}
+	q.resize <- size
+}
+
+// Next blocks until the terminal size changes. It returns nil once the queue is stopped.
+func (q *terminalSizeQueue) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-q.resize:
+		return &size
+	case <-q.done:
+		return nil
+	}
+}
+
+func (q *terminalSizeQueue) stop() {
+	close(q.done)
+}
+
+// shellCommand builds the command that starts shell in the pod with TERM set.
+// shell may contain arguments, e.g. "bash --norc"; -l is added after the binary
+// for a login shell.
+func shellCommand(opts cmdoptions.ShellOptions, termName string) []string {
+	if termName == "" {
+		termName = defaultTerm
+	}
+	fields := strings.Fields(opts.Shell)
+	if len(fields) == 0 {
+		fields = []string{"sh"}
+	}
+	command := []string{"env", fmt.Sprintf("TERM=%s", termName), fields[0]}
+	if opts.Login {
+		command = append(command, "-l")
+	}
+	return append(command, fields[1:]...)
+}
@@ -0,0 +1,55 @@
+package hippod
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/noksa/helm-in-pod/internal/cmdoptions"
+)
+
+var _ = Describe("Terminal", func() {
+	Describe("shellCommand", func() {
+		It("should start a login shell with TERM from the host", func() {
+			Expect(shellCommand(cmdoptions.ShellOptions{Shell: "bash", Login: true}, "screen")).
+				To(Equal([]string{"env", "TERM=screen", "bash", "-l"}))
+		})
+
+		It("should fall back to the default TERM", func() {
+			Expect(shellCommand(cmdoptions.ShellOptions{Shell: "sh"}, "")).
+				To(Equal([]string{"env", "TERM=" + defaultTerm, "sh"}))
+		})
+
+		It("should keep shell arguments after the login flag", func() {
+			Expect(shellCommand(cmdoptions.ShellOptions{Shell: "bash --norc", Login: true}, "xterm")).
+				To(Equal([]string{"env", "TERM=xterm", "bash", "-l", "--norc"}))
+		})
+
+		It("should default to sh for an empty shell", func() {
+			Expect(shellCommand(cmdoptions.ShellOptions{}, "xterm")).
+				To(Equal([]string{"env", "TERM=xterm", "sh"}))
+		})
+	})
+
+	Describe("terminalSizeQueue", func() {
+		It("should not be created for a non-terminal", func() {
+			f, err := os.CreateTemp(GinkgoT().TempDir(), "not-a-tty")
+			Expect(err).NotTo(HaveOccurred())
+			defer f.Close()
+			Expect(newTerminalSizeQueue(int(f.Fd()))).To(BeNil())
+		})
+
+		It("should return queued sizes and nil once stopped", func() {
+			q := &terminalSizeQueue{
+				resize: make(chan remotecommand.TerminalSize, 1),
+				done:   make(chan struct{}),
+			}
+			q.resize <- remotecommand.TerminalSize{Width: 120, Height: 40}
+			Expect(q.Next()).To(Equal(&remotecommand.TerminalSize{Width: 120, Height: 40}))
+			q.stop()
+			Expect(q.Next()).To(BeNil())
+		})
+	})
+})
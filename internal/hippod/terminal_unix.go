@@ -0,0 +1,24 @@
+//go:build !windows
+
+package hippod
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchTerminalResize calls onResize on every SIGWINCH until done is closed.
+func watchTerminalResize(done <-chan struct{}, onResize func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGWINCH)
+	defer signal.Stop(c)
+	for {
+		select {
+		case <-done:
+			return
+		case <-c:
+			onResize()
+		}
+	}
+}
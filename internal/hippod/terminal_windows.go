@@ -0,0 +1,20 @@
+//go:build windows
+
+package hippod
+
+import "time"
+
+// watchTerminalResize polls the terminal size until done is closed, as there is
+// no SIGWINCH on Windows.
+func watchTerminalResize(done <-chan struct{}, onResize func()) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			onResize()
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package hipredact
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Redaction", func() {
+	AfterEach(func() {
+		Reset()
+	})
+
+	Context("String", func() {
+		DescribeTable("should mask known sensitive values by default",
+			func(input, expected string) {
+				Expect(String(input)).To(Equal(expected))
+			},
+			Entry("password in a URL", "postgres://app:s3cr3t@db:5432/helm", "postgres://app:***@db:5432/helm"),
+			Entry("password without a user", "redis://:s3cr3t@cache", "redis://:***@cache"),
+			Entry("bearer token", "Authorization: Bearer abc.def-ghi", "Authorization: Bearer ***"),
+			Entry("key=value", "helm install x --set db.password=hunter22", "helm install x --set db.password=***"),
+			Entry("quoted env export", `export DB_PASSWORD="hunter22"`, `export DB_PASSWORD="***"`),
+			Entry("flag with =", "helm repo add x y --token=abc123", "helm repo add x y --token=***"),
+			Entry("yaml key", "api_key: abc123", "api_key: ***"),
+		)
+
+		DescribeTable("should leave text without secrets alone",
+			func(input string) {
+				Expect(String(input)).To(Equal(input))
+			},
+			Entry("url without password", "https://charts.example.com/stable"),
+			Entry("url with user only", "https://user@charts.example.com"),
+			Entry("words in a message", "Created 'helm-in-pod-creds-1' secret for operation 1"),
+			Entry("field ending in token", "automountServiceAccountToken: false"),
+			Entry("volume secret key", "secret:"),
+		)
+
+		It("should mask added values", func() {
+			AddValues("s3cr3t-value")
+			Expect(String("value is s3cr3t-value!")).To(Equal("value is ***!"))
+		})
+
+		It("should mask the JSON escaped form of added values", func() {
+			AddValues(`pa"ss\word`)
+			Expect(String(`{"message":"pa\"ss\\word"}`)).To(Equal(`{"message":"***"}`))
+		})
+
+		It("should ignore short values", func() {
+			AddValues("", "on", "123")
+			Expect(String("on 123")).To(Equal("on 123"))
+		})
+
+		It("should mask whole matches of patterns without groups", func() {
+			Expect(AddPatterns(`ghp_[A-Za-z0-9]+`)).To(Succeed())
+			Expect(String("token ghp_abc123 used")).To(Equal("token *** used"))
+		})
+
+		It("should mask only the groups of patterns with groups", func() {
+			Expect(AddPatterns(`user=(\w+)`)).To(Succeed())
+			Expect(String("user=alice user=bob")).To(Equal("user=*** user=***"))
+		})
+
+		It("should reject invalid patterns", func() {
+			Expect(AddPatterns(`(`)).To(MatchError(ContainSubstring("invalid redact pattern")))
+		})
+	})
+
+	Context("AddEnv", func() {
+		It("should mask values of variables with sensitive names only", func() {
+			AddEnv(map[string]string{
+				"HELM_DRIVER_SQL_CONNECTION_STRING": "host=db user=app",
+				"HELM_DRIVER":                       "sql-driver",
+			})
+			Expect(String("host=db user=app sql-driver")).To(Equal("*** sql-driver"))
+		})
+
+		DescribeTable("IsSensitiveName",
+			func(name string, expected bool) {
+				Expect(IsSensitiveName(name)).To(Equal(expected))
+			},
+			Entry("password", "DB_PASSWORD", true),
+			Entry("token", "GITHUB_TOKEN", true),
+			Entry("connection string", "HELM_DRIVER_SQL_CONNECTION_STRING", true),
+			Entry("api key", "DATADOG_API_KEY", true),
+			Entry("plain", "HELM_DRIVER", false),
+			Entry("namespace", "HELM_NAMESPACE", false),
+		)
+	})
+
+	Context("Writer", func() {
+		It("should mask values split across writes", func() {
+			AddValues("s3cr3t-value")
+			out := &bytes.Buffer{}
+			w := NewWriter(out)
+			_, _ = w.Write([]byte("first s3cr"))
+			Expect(out.String()).To(BeEmpty())
+			_, _ = w.Write([]byte("3t-value\nsecond"))
+			Expect(out.String()).To(Equal("first ***\n"))
+			w.Flush()
+			Expect(out.String()).To(Equal("first ***\nsecond"))
+		})
+	})
+
+	Context("RecordWriter", func() {
+		It("should mask each record", func() {
+			AddValues("s3cr3t-value")
+			out := &bytes.Buffer{}
+			n, err := NewRecordWriter(out).Write([]byte(`{"message":"s3cr3t-value"}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(26))
+			Expect(out.String()).To(Equal(`{"message":"***"}`))
+		})
+	})
+})
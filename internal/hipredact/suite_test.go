@@ -0,0 +1,13 @@
+package hipredact
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestHipredact(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Hipredact Suite")
+}
@@ -0,0 +1,194 @@
+package hipreport
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+	"github.com/noksa/helm-in-pod/internal/hiperrors"
+	"github.com/noksa/helm-in-pod/internal/hipredact"
+	"github.com/noksa/helm-in-pod/internal/hipretry"
+)
+
+// Phases of a run, in the order they happen.
+const (
+	PhaseNamespace  = "namespace"
+	PhaseScheduling = "scheduling"
+	PhaseImagePull  = "image-pull"
+	PhaseBundleCopy = "bundle-copy"
+	PhaseRepoSync   = "repo-sync"
+	PhaseRepoUpdate = "repo-update"
+	PhaseCommand    = "command"
+	PhaseCopyFrom   = "copy-from"
+	PhaseCleanup    = "cleanup"
+)
+
+// Phase is the time spent in one phase of a run. A phase entered several
+// times, e.g. once per Job pod, sums up all of them.
+type Phase struct {
+	Name            string    `json:"name"`
+	StartedAt       time.Time `json:"startedAt"`
+	DurationSeconds float64   `json:"durationSeconds"`
+}
+
+// BootInfo is the pod metadata collected before the command runs.
+type BootInfo struct {
+	User        string `json:"user,omitempty"`
+	ID          string `json:"id,omitempty"`
+	Home        string `json:"home,omitempty"`
+	HelmVersion string `json:"helmVersion,omitempty"`
+}
+
+// Report collects the timings and metadata of a run and writes them as a JSON
+// document. All methods are safe for concurrent use.
+type Report struct {
+	mu      sync.Mutex
+	retries *hipretry.Recorder
+
+	OperationID     string    `json:"operationId"`
+	StartedAt       time.Time `json:"startedAt"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	Pod             string    `json:"pod,omitempty"`
+	Node            string    `json:"node,omitempty"`
+	Image           string    `json:"image,omitempty"`
+	BootInfo        *BootInfo `json:"bootInfo,omitempty"`
+	// ExitCode is the exit code of the command, null if it didn't finish.
+	ExitCode      *int32             `json:"exitCode"`
+	Error         string             `json:"error,omitempty"`
+	Phases        []Phase            `json:"phases"`
+	BytesSent     int64              `json:"bytesSent"`
+	BytesReceived int64              `json:"bytesReceived"`
+	Retries       []hipretry.Attempt `json:"retries"`
+}
+
+// New returns a report of the operation starting now.
+func New(operationID string) *Report {
+	return &Report{
+		retries:     &hipretry.Recorder{},
+		OperationID: operationID,
+		StartedAt:   time.Now(),
+		Phases:      []Phase{},
+	}
+}
+
+// Retry retries fn like hipretry.Retry and records its failed attempts in the report.
+func (r *Report) Retry(operation string, maxAttempts int, fn hipretry.Func) error {
+	return r.retries.Retry(operation, maxAttempts, fn)
+}
+
+// Start starts timing phase and returns a function that stops it.
+func (r *Report) Start(phase string) func() {
+	start := time.Now()
+	return func() {
+		r.AddPhase(phase, start, time.Now())
+	}
+}
+
+// AddPhase records that phase ran from start to end.
+func (r *Report) AddPhase(phase string, start, end time.Time) {
+	if end.Before(start) {
+		end = start
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.Phases {
+		if r.Phases[i].Name == phase {
+			r.Phases[i].DurationSeconds += end.Sub(start).Seconds()
+			return
+		}
+	}
+	r.Phases = append(r.Phases, Phase{Name: phase, StartedAt: start, DurationSeconds: end.Sub(start).Seconds()})
+}
+
+// AddPodStartup records the pod, its node and image, and the scheduling and
+// image pull phases taken from the status of a running pod. Kubernetes records
+// these times with a precision of one second.
+func (r *Report) AddPodStartup(pod *corev1.Pod) {
+	r.SetPod(pod)
+	created := pod.CreationTimestamp.Time
+	var scheduled, started time.Time
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionTrue {
+			scheduled = cond.LastTransitionTime.Time
+		}
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == hipconsts.HelmInPodName && status.State.Running != nil {
+			started = status.State.Running.StartedAt.Time
+		}
+	}
+	if created.IsZero() || scheduled.IsZero() {
+		return
+	}
+	r.AddPhase(PhaseScheduling, created, scheduled)
+	if !started.IsZero() {
+		r.AddPhase(PhaseImagePull, scheduled, started)
+	}
+}
+
+// SetPod records the pod the command runs in, its node and image.
+func (r *Report) SetPod(pod *corev1.Pod) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Pod = pod.Name
+	r.Node = pod.Spec.NodeName
+	for _, container := range pod.Spec.Containers {
+		if container.Name == hipconsts.HelmInPodName {
+			r.Image = container.Image
+		}
+	}
+}
+
+// SetBootInfo records the pod metadata collected before the command runs.
+func (r *Report) SetBootInfo(info BootInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.BootInfo = &info
+}
+
+// AddBytesSent adds n bytes sent to the pod.
+func (r *Report) AddBytesSent(n int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.BytesSent += n
+}
+
+// AddBytesReceived adds n bytes received from the pod.
+func (r *Report) AddBytesReceived(n int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.BytesReceived += n
+}
+
+// Write finishes the report with the result of the run and writes it to path.
+// runErr is nil if the command succeeded.
+func (r *Report) Write(path string, runErr error) error {
+	r.mu.Lock()
+	r.DurationSeconds = time.Since(r.StartedAt).Seconds()
+	r.ExitCode = nil
+	r.Error = ""
+	var exitErr *hiperrors.ExitCodeError
+	switch {
+	case runErr == nil:
+		r.ExitCode = new(int32)
+	case errors.As(runErr, &exitErr):
+		r.ExitCode = &exitErr.Code
+	default:
+		r.Error = hipredact.String(runErr.Error())
+	}
+	r.Retries = r.retries.Attempts()
+	if r.Retries == nil {
+		r.Retries = []hipretry.Attempt{}
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
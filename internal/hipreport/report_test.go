@@ -0,0 +1,119 @@
+package hipreport_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+	"github.com/noksa/helm-in-pod/internal/hiperrors"
+	"github.com/noksa/helm-in-pod/internal/hipreport"
+)
+
+var _ = Describe("Report", func() {
+	var report *hipreport.Report
+
+	BeforeEach(func() {
+		report = hipreport.New("op")
+	})
+
+	read := func(runErr error) map[string]any {
+		path := filepath.Join(GinkgoT().TempDir(), "report.json")
+		Expect(report.Write(path, runErr)).To(Succeed())
+		data, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		doc := map[string]any{}
+		Expect(json.Unmarshal(data, &doc)).To(Succeed())
+		return doc
+	}
+
+	It("should sum up a phase entered several times", func() {
+		start := time.Now()
+		report.AddPhase(hipreport.PhaseCommand, start, start.Add(2*time.Second))
+		report.AddPhase(hipreport.PhaseCopyFrom, start, start.Add(time.Second))
+		report.AddPhase(hipreport.PhaseCommand, start, start.Add(3*time.Second))
+
+		Expect(report.Phases).To(HaveLen(2))
+		Expect(report.Phases[0].Name).To(Equal(hipreport.PhaseCommand))
+		Expect(report.Phases[0].DurationSeconds).To(BeNumerically("==", 5))
+	})
+
+	It("should take scheduling and image pull from the pod status", func() {
+		created := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+		report.AddPodStartup(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "helm-in-pod-abc", CreationTimestamp: metav1.NewTime(created)},
+			Spec: corev1.PodSpec{
+				NodeName:   "node-1",
+				Containers: []corev1.Container{{Name: hipconsts.HelmInPodName, Image: "alpine/helm"}},
+			},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{{
+					Type: corev1.PodScheduled, Status: corev1.ConditionTrue,
+					LastTransitionTime: metav1.NewTime(created.Add(2 * time.Second)),
+				}},
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  hipconsts.HelmInPodName,
+					State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: metav1.NewTime(created.Add(9 * time.Second))}},
+				}},
+			},
+		})
+
+		Expect(report.Pod).To(Equal("helm-in-pod-abc"))
+		Expect(report.Node).To(Equal("node-1"))
+		Expect(report.Image).To(Equal("alpine/helm"))
+		Expect(report.Phases).To(Equal([]hipreport.Phase{
+			{Name: hipreport.PhaseScheduling, StartedAt: created, DurationSeconds: 2},
+			{Name: hipreport.PhaseImagePull, StartedAt: created.Add(2 * time.Second), DurationSeconds: 7},
+		}))
+	})
+
+	It("should record the exit code of a failed command", func() {
+		doc := read(&hiperrors.ExitCodeError{Code: 3})
+		Expect(doc).To(HaveKeyWithValue("exitCode", BeNumerically("==", 3)))
+		Expect(doc).NotTo(HaveKey("error"))
+	})
+
+	It("should record exit code 0 of a successful command", func() {
+		doc := read(nil)
+		Expect(doc).To(HaveKeyWithValue("exitCode", BeNumerically("==", 0)))
+		Expect(doc).To(HaveKeyWithValue("retries", BeEmpty()))
+	})
+
+	It("should record an error that is not an exit code", func() {
+		doc := read(errors.New("timeout waiting pod readiness"))
+		Expect(doc).To(HaveKeyWithValue("exitCode", BeNil()))
+		Expect(doc).To(HaveKeyWithValue("error", "timeout waiting pod readiness"))
+	})
+
+	It("should include bytes, boot info and failed attempts", func() {
+		report.AddBytesSent(10)
+		report.AddBytesSent(5)
+		report.AddBytesReceived(7)
+		report.SetBootInfo(hipreport.BootInfo{User: "root", Home: "/root", HelmVersion: "v3.17.0"})
+		calls := 0
+		Expect(report.Retry("bundle copy", 2, func() error {
+			calls++
+			if calls == 1 {
+				return errors.New("connection reset")
+			}
+			return nil
+		})).To(Succeed())
+
+		doc := read(nil)
+		Expect(doc).To(HaveKeyWithValue("bytesSent", BeNumerically("==", 15)))
+		Expect(doc).To(HaveKeyWithValue("bytesReceived", BeNumerically("==", 7)))
+		Expect(doc).To(HaveKeyWithValue("bootInfo", HaveKeyWithValue("helmVersion", "v3.17.0")))
+		Expect(doc["retries"]).To(ConsistOf(And(
+			HaveKeyWithValue("operation", "bundle copy"),
+			HaveKeyWithValue("attempt", BeNumerically("==", 1)),
+			HaveKeyWithValue("error", "connection reset"),
+		)))
+	})
+})
@@ -0,0 +1,13 @@
+package hipreport_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestHipreport(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Hipreport Suite")
+}
@@ -1,6 +1,8 @@
 package hipretry
 
 import (
+	"slices"
+	"sync"
 	"time"
 
 	"go.uber.org/multierr"
@@ -24,3 +26,47 @@ func Retry(maxAttempts int, fn Func) error {
 	}
 	return mErr
 }
+
+// Attempt is a failed attempt of a retried function.
+type Attempt struct {
+	// Operation describes what was retried, e.g. "bundle copy".
+	Operation string    `json:"operation"`
+	Attempt   int       `json:"attempt"`
+	Error     string    `json:"error"`
+	Time      time.Time `json:"time"`
+}
+
+// Recorder retries functions like Retry and records their failed attempts.
+// A nil Recorder retries without recording.
+type Recorder struct {
+	mu       sync.Mutex
+	attempts []Attempt
+}
+
+// Retry executes fn up to maxAttempts times like Retry and records every failed attempt.
+func (r *Recorder) Retry(operation string, maxAttempts int, fn Func) error {
+	if r == nil {
+		return Retry(maxAttempts, fn)
+	}
+	attempt := 0
+	return Retry(maxAttempts, func() error {
+		attempt++
+		err := fn()
+		if err != nil {
+			r.mu.Lock()
+			r.attempts = append(r.attempts, Attempt{Operation: operation, Attempt: attempt, Error: err.Error(), Time: time.Now()})
+			r.mu.Unlock()
+		}
+		return err
+	})
+}
+
+// Attempts returns the failed attempts recorded so far.
+func (r *Recorder) Attempts() []Attempt {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.attempts)
+}
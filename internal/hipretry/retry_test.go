@@ -77,3 +77,43 @@ var _ = Describe("Retry", func() {
 		Expect(calls).To(Equal(5))
 	})
 })
+
+var _ = Describe("Recorder", func() {
+	It("should record every failed attempt", func() {
+		r := &Recorder{}
+		calls := 0
+		err := r.Retry("copy", 3, func() error {
+			calls++
+			if calls < 3 {
+				return fmt.Errorf("fail %d", calls)
+			}
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		attempts := r.Attempts()
+		Expect(attempts).To(HaveLen(2))
+		Expect(attempts[0].Operation).To(Equal("copy"))
+		Expect(attempts[0].Attempt).To(Equal(1))
+		Expect(attempts[0].Error).To(Equal("fail 1"))
+		Expect(attempts[1].Attempt).To(Equal(2))
+	})
+
+	It("should record nothing when the first attempt succeeds", func() {
+		r := &Recorder{}
+		Expect(r.Retry("copy", 3, func() error { return nil })).To(Succeed())
+		Expect(r.Attempts()).To(BeEmpty())
+	})
+
+	It("should retry without recording when nil", func() {
+		var r *Recorder
+		calls := 0
+		err := r.Retry("copy", 2, func() error {
+			calls++
+			return fmt.Errorf("fail")
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(calls).To(Equal(2))
+		Expect(r.Attempts()).To(BeNil())
+	})
+})
@@ -0,0 +1,252 @@
+package hipsync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/noksa/helm-in-pod/internal/helmtar"
+	"github.com/noksa/helm-in-pod/internal/logz"
+)
+
+// manifestVersion is the version of the manifest format. Manifests of other
+// versions are ignored, so the next sync sends all files.
+const manifestVersion = 1
+
+// File is a synced file recorded in a Manifest.
+type File struct {
+	SHA256 string      `json:"sha256"`
+	Mode   fs.FileMode `json:"mode"`
+}
+
+// Manifest records the files synced to a pod by their absolute path in the pod.
+type Manifest struct {
+	Version int             `json:"version"`
+	Files   map[string]File `json:"files"`
+}
+
+// NewManifest returns an empty manifest.
+func NewManifest() *Manifest {
+	return &Manifest{Version: manifestVersion, Files: map[string]File{}}
+}
+
+// ParseManifest parses a manifest read from the pod. Empty data, e.g. because
+// nothing was synced yet, gives an empty manifest.
+func ParseManifest(data []byte) (*Manifest, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return NewManifest(), nil
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid sync manifest: %w", err)
+	}
+	if manifest.Version != manifestVersion {
+		return NewManifest(), nil
+	}
+	if manifest.Files == nil {
+		manifest.Files = map[string]File{}
+	}
+	return manifest, nil
+}
+
+// Marshal encodes the manifest for storing it in the pod.
+func (m *Manifest) Marshal() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// Forget drops the files under podPath, e.g. after they were deleted in the pod.
+func (m *Manifest) Forget(podPath string) {
+	podPath = path.Clean(podPath)
+	for name := range m.Files {
+		if isUnder(name, podPath) {
+			delete(m.Files, name)
+		}
+	}
+}
+
+// Paths returns the pod paths of the synced files in sorted order.
+func (m *Manifest) Paths() []string {
+	return slices.Sorted(maps.Keys(m.Files))
+}
+
+// Verify compares the manifest with the files in the pod, given as the output
+// of sha256sum for its paths, and drops the files that were changed or deleted
+// in the pod, so the next sync sends them again. It returns the dropped paths.
+func (m *Manifest) Verify(sums string) []string {
+	actual := make(map[string]string, len(m.Files))
+	for _, line := range strings.Split(sums, "\n") {
+		// Lines of escaped names start with a backslash; such files are sent again
+		hash, name, ok := strings.Cut(line, " ")
+		if !ok || strings.HasPrefix(hash, "\\") || len(name) < 2 {
+			continue
+		}
+		// sha256sum separates the name with " " in text mode and "*" in binary mode
+		actual[name[1:]] = hash
+	}
+	var dropped []string
+	for _, name := range m.Paths() {
+		if actual[name] != m.Files[name].SHA256 {
+			delete(m.Files, name)
+			dropped = append(dropped, name)
+		}
+	}
+	return dropped
+}
+
+// Plan is what a sync does to make the pod match the host.
+type Plan struct {
+	// Upload holds the new and changed files.
+	Upload []helmtar.BundleEntry
+	// Remove holds the pod paths of synced files that no longer exist on the host.
+	Remove []string
+	// Manifest is the manifest of the pod after the sync.
+	Manifest *Manifest
+}
+
+// Empty reports whether the pod is already in sync.
+func (p *Plan) Empty() bool {
+	return len(p.Upload) == 0 && len(p.Remove) == 0
+}
+
+// NewPlan compares the files of mappings on the host with the manifest of the
+// pod. A mapping copies the host path SrcPath to the pod path DestPath, and
+// directories recursively, skipping the files excluded by helmtar.Filter. Only
+// files under the DestPath of a mapping are removed, so files synced by other
+// mappings are kept. Symlinks to files and directories are followed; empty
+// directories and special files are not synced.
+func NewPlan(mappings []helmtar.BundleEntry, current *Manifest) (*Plan, error) {
+	files := map[string]File{}
+	sources := map[string]string{}
+	roots := make([]string, 0, len(mappings))
+	for _, mapping := range mappings {
+		root := path.Clean(filepath.ToSlash(mapping.DestPath))
+		if err := scan(mapping.SrcPath, root, mapping.Excludes, files, sources); err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+	}
+
+	plan := &Plan{Manifest: NewManifest()}
+	for name, file := range current.Files {
+		if _, ok := files[name]; !ok && slices.ContainsFunc(roots, func(root string) bool { return isUnder(name, root) }) {
+			plan.Remove = append(plan.Remove, name)
+			continue
+		}
+		plan.Manifest.Files[name] = file
+	}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		if old, ok := current.Files[name]; !ok || old != files[name] {
+			plan.Upload = append(plan.Upload, helmtar.BundleEntry{SrcPath: sources[name], DestPath: name})
+		}
+		plan.Manifest.Files[name] = files[name]
+	}
+	slices.Sort(plan.Remove)
+	return plan, nil
+}
+
+// scan adds the files of the host path src, synced to the pod path dest, to
+// files and their host paths to sources.
+func scan(src, dest string, excludes []string, files map[string]File, sources map[string]string) error {
+	stat, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !stat.IsDir() {
+		return add(src, dest, stat, files, sources)
+	}
+	filter, err := helmtar.NewFilter(src, excludes)
+	if err != nil {
+		return err
+	}
+	defer filter.Report()
+	return scanDir(src, dest, filter, nil, files, sources)
+}
+
+// scanDir adds the files under the host directory dir, synced to the pod path
+// dest. Symlinks are followed, except to a directory that is already being
+// scanned. ancestors are the resolved paths of the directories being scanned.
+func scanDir(dir, dest string, filter *helmtar.Filter, ancestors []string, files map[string]File, sources map[string]string) error {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(ancestors, resolved) {
+		logz.Host().Warn().Msgf("Skipping %v: it links to a directory containing it", color.CyanString(dir))
+		return nil
+	}
+	ancestors = append(ancestors, resolved)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		file := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(file)
+		if err != nil {
+			if entry.Type()&fs.ModeSymlink == 0 {
+				return err
+			}
+			logz.Host().Warn().Msgf("Skipping broken symlink %v", color.CyanString(file))
+			continue
+		}
+		if filter.Skip(file, info) {
+			continue
+		}
+		target := path.Join(dest, entry.Name())
+		switch {
+		case info.IsDir():
+			err = scanDir(file, target, filter, ancestors, files, sources)
+		case info.Mode().IsRegular():
+			err = add(file, target, info, files, sources)
+		default:
+			logz.Host().Debug().Msgf("Skipping %v: not a regular file", color.CyanString(file))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func add(src, dest string, info fs.FileInfo, files map[string]File, sources map[string]string) error {
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", src)
+	}
+	sum, err := hashFile(src)
+	if err != nil {
+		return err
+	}
+	files[dest] = File{SHA256: sum, Mode: info.Mode().Perm()}
+	sources[dest] = src
+	return nil
+}
+
+func hashFile(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// isUnder reports whether the pod path name is root or inside it.
+func isUnder(name, root string) bool {
+	return root == "/" || name == root || strings.HasPrefix(name, root+"/")
+}
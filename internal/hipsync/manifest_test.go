@@ -0,0 +1,201 @@
+package hipsync
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/noksa/helm-in-pod/internal/helmtar"
+)
+
+var _ = Describe("ParseManifest", func() {
+	It("should return an empty manifest for empty data", func() {
+		manifest, err := ParseManifest([]byte("\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.Files).To(BeEmpty())
+	})
+
+	It("should round-trip a manifest", func() {
+		manifest := NewManifest()
+		manifest.Files["/tmp/a"] = File{SHA256: "abc", Mode: 0o644}
+		data, err := manifest.Marshal()
+		Expect(err).NotTo(HaveOccurred())
+		parsed, err := ParseManifest(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed).To(Equal(manifest))
+	})
+
+	It("should ignore manifests of other versions", func() {
+		manifest, err := ParseManifest([]byte(`{"version":99,"files":{"/tmp/a":{"sha256":"abc","mode":420}}}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.Files).To(BeEmpty())
+	})
+
+	It("should fail for invalid data", func() {
+		_, err := ParseManifest([]byte("not json"))
+		Expect(err).To(MatchError(ContainSubstring("invalid sync manifest")))
+	})
+})
+
+var _ = Describe("Manifest.Forget", func() {
+	It("should drop the files under a path", func() {
+		manifest := NewManifest()
+		manifest.Files["/tmp/chart/Chart.yaml"] = File{}
+		manifest.Files["/tmp/chart/templates/a.yaml"] = File{}
+		manifest.Files["/tmp/chart-other/Chart.yaml"] = File{}
+		manifest.Forget("/tmp/chart/")
+		Expect(manifest.Files).To(HaveLen(1))
+		Expect(manifest.Files).To(HaveKey("/tmp/chart-other/Chart.yaml"))
+	})
+})
+
+var _ = Describe("Manifest.Verify", func() {
+	const (
+		hashA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		hashB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	)
+
+	It("should drop files changed or deleted in the pod", func() {
+		manifest := NewManifest()
+		manifest.Files["/tmp/chart/Chart.yaml"] = File{SHA256: hashA}
+		manifest.Files["/tmp/chart/Chart.lock"] = File{SHA256: hashA}
+		manifest.Files["/tmp/chart/my values.yaml"] = File{SHA256: hashA}
+		manifest.Files["/tmp/chart/deleted.yaml"] = File{SHA256: hashA}
+
+		dropped := manifest.Verify(hashA + "  /tmp/chart/Chart.yaml\n" +
+			hashB + "  /tmp/chart/Chart.lock\n" +
+			hashA + " */tmp/chart/my values.yaml\n")
+		Expect(dropped).To(Equal([]string{"/tmp/chart/Chart.lock", "/tmp/chart/deleted.yaml"}))
+		Expect(manifest.Paths()).To(Equal([]string{"/tmp/chart/Chart.yaml", "/tmp/chart/my values.yaml"}))
+	})
+
+	It("should drop all files when nothing could be hashed", func() {
+		manifest := NewManifest()
+		manifest.Files["/tmp/a"] = File{SHA256: hashA}
+		Expect(manifest.Verify("")).To(Equal([]string{"/tmp/a"}))
+		Expect(manifest.Files).To(BeEmpty())
+	})
+})
+
+var _ = Describe("NewPlan", func() {
+	var srcDir string
+
+	BeforeEach(func() {
+		srcDir = GinkgoT().TempDir()
+		Expect(os.MkdirAll(filepath.Join(srcDir, "templates"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(srcDir, "Chart.yaml"), []byte("name: chart"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(srcDir, "templates", "cm.yaml"), []byte("kind: ConfigMap"), 0o644)).To(Succeed())
+	})
+
+	mappings := func() []helmtar.BundleEntry {
+		return []helmtar.BundleEntry{{SrcPath: srcDir, DestPath: "/tmp/chart"}}
+	}
+
+	destPaths := func(entries []helmtar.BundleEntry) []string {
+		var paths []string
+		for _, e := range entries {
+			paths = append(paths, e.DestPath)
+		}
+		return paths
+	}
+
+	It("should upload all files to an empty pod", func() {
+		plan, err := NewPlan(mappings(), NewManifest())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(destPaths(plan.Upload)).To(Equal([]string{"/tmp/chart/Chart.yaml", "/tmp/chart/templates/cm.yaml"}))
+		Expect(plan.Upload[0].SrcPath).To(Equal(filepath.Join(srcDir, "Chart.yaml")))
+		Expect(plan.Remove).To(BeEmpty())
+		Expect(plan.Manifest.Files).To(HaveLen(2))
+	})
+
+	It("should do nothing when the pod is in sync", func() {
+		first, err := NewPlan(mappings(), NewManifest())
+		Expect(err).NotTo(HaveOccurred())
+		plan, err := NewPlan(mappings(), first.Manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plan.Empty()).To(BeTrue())
+		Expect(plan.Manifest).To(Equal(first.Manifest))
+	})
+
+	It("should upload changed and new files and remove deleted ones", func() {
+		first, err := NewPlan(mappings(), NewManifest())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(srcDir, "Chart.yaml"), []byte("name: changed"), 0o644)).To(Succeed())
+		Expect(os.Remove(filepath.Join(srcDir, "templates", "cm.yaml"))).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(srcDir, "values.yaml"), []byte("replicas: 1"), 0o644)).To(Succeed())
+
+		plan, err := NewPlan(mappings(), first.Manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(destPaths(plan.Upload)).To(Equal([]string{"/tmp/chart/Chart.yaml", "/tmp/chart/values.yaml"}))
+		Expect(plan.Remove).To(Equal([]string{"/tmp/chart/templates/cm.yaml"}))
+		Expect(plan.Manifest.Files).To(HaveKey("/tmp/chart/values.yaml"))
+		Expect(plan.Manifest.Files).NotTo(HaveKey("/tmp/chart/templates/cm.yaml"))
+	})
+
+	It("should upload files whose mode changed", func() {
+		first, err := NewPlan(mappings(), NewManifest())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chmod(filepath.Join(srcDir, "Chart.yaml"), 0o600)).To(Succeed())
+
+		plan, err := NewPlan(mappings(), first.Manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(destPaths(plan.Upload)).To(Equal([]string{"/tmp/chart/Chart.yaml"}))
+	})
+
+	It("should keep files synced by other mappings", func() {
+		current := NewManifest()
+		current.Files["/tmp/other/file.txt"] = File{SHA256: "abc", Mode: 0o644}
+		plan, err := NewPlan(mappings(), current)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plan.Remove).To(BeEmpty())
+		Expect(plan.Manifest.Files).To(HaveKey("/tmp/other/file.txt"))
+	})
+
+	It("should skip excluded files and remove them from the pod", func() {
+		first, err := NewPlan(mappings(), NewManifest())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(srcDir, helmtar.IgnoreFile), []byte("templates/\n"), 0o644)).To(Succeed())
+
+		plan, err := NewPlan(mappings(), first.Manifest)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(destPaths(plan.Upload)).To(Equal([]string{"/tmp/chart/" + helmtar.IgnoreFile}))
+		Expect(plan.Remove).To(Equal([]string{"/tmp/chart/templates/cm.yaml"}))
+	})
+
+	It("should sync a single file mapping", func() {
+		plan, err := NewPlan([]helmtar.BundleEntry{{SrcPath: filepath.Join(srcDir, "Chart.yaml"), DestPath: "/tmp/Chart.yaml"}}, NewManifest())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(destPaths(plan.Upload)).To(Equal([]string{"/tmp/Chart.yaml"}))
+	})
+
+	It("should follow symlinks and skip broken ones", func() {
+		Expect(os.Symlink(filepath.Join(srcDir, "Chart.yaml"), filepath.Join(srcDir, "link.yaml"))).To(Succeed())
+		Expect(os.Symlink(filepath.Join(srcDir, "missing"), filepath.Join(srcDir, "broken.yaml"))).To(Succeed())
+		plan, err := NewPlan(mappings(), NewManifest())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(destPaths(plan.Upload)).To(ContainElement("/tmp/chart/link.yaml"))
+		Expect(destPaths(plan.Upload)).NotTo(ContainElement("/tmp/chart/broken.yaml"))
+		Expect(plan.Manifest.Files["/tmp/chart/link.yaml"]).To(Equal(plan.Manifest.Files["/tmp/chart/Chart.yaml"]))
+	})
+
+	It("should follow symlinked directories and skip loops", func() {
+		shared := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(shared, "_helpers.tpl"), []byte("{{/* helpers */}}"), 0o644)).To(Succeed())
+		Expect(os.Symlink(shared, filepath.Join(srcDir, "templates", "shared"))).To(Succeed())
+		Expect(os.Symlink(srcDir, filepath.Join(srcDir, "templates", "loop"))).To(Succeed())
+		plan, err := NewPlan(mappings(), NewManifest())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(destPaths(plan.Upload)).To(Equal([]string{
+			"/tmp/chart/Chart.yaml",
+			"/tmp/chart/templates/cm.yaml",
+			"/tmp/chart/templates/shared/_helpers.tpl",
+		}))
+	})
+
+	It("should fail for a missing source", func() {
+		_, err := NewPlan([]helmtar.BundleEntry{{SrcPath: filepath.Join(srcDir, "missing"), DestPath: "/tmp/missing"}}, NewManifest())
+		Expect(err).To(HaveOccurred())
+	})
+})
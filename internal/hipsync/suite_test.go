@@ -0,0 +1,13 @@
+package hipsync
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestHipsync(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Hipsync Suite")
+}
@@ -0,0 +1,13 @@
+package hiptrace
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestHiptrace(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Hiptrace Suite")
+}
@@ -0,0 +1,168 @@
+package hiptrace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/noksa/helm-in-pod/internal/hipconsts"
+	"github.com/noksa/helm-in-pod/internal/logz"
+)
+
+// EnvTraceParent is the W3C trace context variable the trace is continued from
+// on the host and propagated to the command in the pod.
+const EnvTraceParent = "TRACEPARENT"
+
+const tracerName = "github.com/noksa/helm-in-pod"
+
+// shutdownTimeout bounds how long Finish waits for spans to be exported.
+const shutdownTimeout = 5 * time.Second
+
+var (
+	mu       sync.Mutex
+	provider *sdktrace.TracerProvider
+	file     *os.File
+	rootSpan trace.Span
+)
+
+// Enabled reports whether spans are exported with endpoint or filePath, or
+// because the standard OTEL_EXPORTER_OTLP_* variables are set.
+func Enabled(endpoint, filePath string) bool {
+	return endpoint != "" || filePath != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Init sets up the global tracer provider. Spans are written as JSON lines to
+// filePath if it is set, otherwise they are sent over OTLP/HTTP to endpoint or to
+// the endpoint configured with the OTEL_EXPORTER_OTLP_* variables. Without any
+// of them tracing stays disabled and spans are not recorded.
+func Init(ctx context.Context, endpoint, filePath string) error {
+	if !Enabled(endpoint, filePath) {
+		return nil
+	}
+	var exporter sdktrace.SpanExporter
+	var traceFile *os.File
+	if filePath != "" {
+		f, err := os.Create(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to create trace file: %w", err)
+		}
+		fileExporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		exporter, traceFile = fileExporter, f
+	} else {
+		var opts []otlptracehttp.Option
+		if endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+		}
+		otlpExporter, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		exporter = otlpExporter
+	}
+
+	// Variables like OTEL_SERVICE_NAME override the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(hipconsts.HelmInPodName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	provider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	file = traceFile
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return nil
+}
+
+// StartRoot starts the span covering the whole invocation, continuing the trace
+// of the TRACEPARENT variable if it is set, e.g. by an instrumented CI pipeline.
+// The span is ended by Finish.
+func StartRoot(ctx context.Context, name string, attrs ...attribute.KeyValue) context.Context {
+	if traceParent := os.Getenv(EnvTraceParent); traceParent != "" {
+		ctx = propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": traceParent})
+	}
+	ctx, span := Start(ctx, name, attrs...)
+	mu.Lock()
+	rootSpan = span
+	mu.Unlock()
+	return ctx
+}
+
+// Finish ends the root span with the result of the invocation and flushes the
+// spans that were not exported yet.
+func Finish(err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if rootSpan != nil {
+		End(rootSpan, err)
+		rootSpan = nil
+	}
+	if provider == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if shutdownErr := provider.Shutdown(ctx); shutdownErr != nil {
+		logz.Host().Warn().Msgf("Failed to export traces: %v", shutdownErr)
+	}
+	provider = nil
+	if file != nil {
+		_ = file.Close()
+		file = nil
+	}
+}
+
+// Start starts a span that is a child of the span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span and marks it as failed if err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceParent returns the W3C traceparent of the span in ctx, or an empty
+// string if ctx has no valid span context.
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// Namespace returns the attribute of the Kubernetes namespace a span operates in.
+func Namespace(name string) attribute.KeyValue {
+	return semconv.K8SNamespaceName(name)
+}
+
+// Pod returns the attribute of the pod a span operates on.
+func Pod(name string) attribute.KeyValue {
+	return semconv.K8SPodName(name)
+}
@@ -0,0 +1,90 @@
+package hiptrace
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Enabled", func() {
+	BeforeEach(func() {
+		GinkgoT().Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+		GinkgoT().Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	})
+
+	It("should be disabled without an endpoint or a file", func() {
+		Expect(Enabled("", "")).To(BeFalse())
+	})
+
+	It("should be enabled by the flags", func() {
+		Expect(Enabled("http://localhost:4318", "")).To(BeTrue())
+		Expect(Enabled("", "traces.json")).To(BeTrue())
+	})
+
+	It("should be enabled by the standard variables", func() {
+		GinkgoT().Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://collector:4318/v1/traces")
+		Expect(Enabled("", "")).To(BeTrue())
+	})
+})
+
+var _ = Describe("file exporter", func() {
+	var path string
+
+	BeforeEach(func() {
+		GinkgoT().Setenv(EnvTraceParent, "")
+		path = filepath.Join(GinkgoT().TempDir(), "traces.json")
+		Expect(Init(context.Background(), "", path)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Finish(nil)
+	})
+
+	readSpans := func() []map[string]any {
+		f, err := os.Open(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer func() { _ = f.Close() }()
+		var spans []map[string]any
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			span := map[string]any{}
+			Expect(json.Unmarshal(scanner.Bytes(), &span)).To(Succeed())
+			spans = append(spans, span)
+		}
+		return spans
+	}
+
+	It("should write the spans with their parents and errors", func() {
+		ctx := StartRoot(context.Background(), "in-pod exec")
+		_, span := Start(ctx, "ExecuteCommand", Pod("helm-in-pod-abc"))
+		End(span, errors.New("exit code 1"))
+		Finish(nil)
+
+		spans := readSpans()
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0]).To(HaveKeyWithValue("Name", "ExecuteCommand"))
+		Expect(spans[0]).To(HaveKeyWithValue("Status", HaveKeyWithValue("Code", "Error")))
+		Expect(spans[1]).To(HaveKeyWithValue("Name", "in-pod exec"))
+		Expect(spans[0]["Parent"]).To(HaveKeyWithValue("SpanID", spans[1]["SpanContext"].(map[string]any)["SpanID"]))
+	})
+
+	It("should continue the trace of TRACEPARENT", func() {
+		GinkgoT().Setenv(EnvTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		ctx := StartRoot(context.Background(), "in-pod exec")
+		Expect(TraceParent(ctx)).To(MatchRegexp(`^00-4bf92f3577b34da6a3ce929d0e0e4736-[0-9a-f]{16}-01$`))
+		Expect(TraceParent(ctx)).NotTo(ContainSubstring("00f067aa0ba902b7"))
+	})
+})
+
+var _ = Describe("TraceParent", func() {
+	It("should be empty without a trace", func() {
+		Expect(TraceParent(context.Background())).To(BeEmpty())
+	})
+})
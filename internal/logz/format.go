@@ -0,0 +1,231 @@
+package logz
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/noksa/helm-in-pod/internal/hipredact"
+)
+
+// Log formats accepted by Configure.
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+	FormatLogfmt  = "logfmt"
+)
+
+// Fields added to log records for log aggregation. The console format doesn't show them.
+const (
+	FieldSource    = "source"
+	FieldOperation = "operation"
+	FieldPod       = "pod"
+	FieldPhase     = "phase"
+	FieldStream    = "stream"
+)
+
+// Phases of a one-shot pod's lifecycle, used as values of the phase field.
+const (
+	PhaseStartup  = "startup"
+	PhaseCopy     = "copy"
+	PhaseRepos    = "repos"
+	PhaseExec     = "exec"
+	PhaseCopyFrom = "copy-from"
+	PhaseCleanup  = "cleanup"
+)
+
+// Formats returns the log formats accepted by Configure.
+func Formats() []string {
+	return []string{FormatConsole, FormatJSON, FormatLogfmt}
+}
+
+// Configure replaces the global logger with one writing records to w in format
+// and resets Host, Pod and HostPod. Secrets known to hipredact are masked. Colors are disabled with noColor and for the
+// structured formats, so messages don't carry escape codes.
+func Configure(w io.Writer, format string, noColor bool) error {
+	w = hipredact.NewRecordWriter(w)
+	var writer io.Writer
+	switch format {
+	case FormatConsole:
+		zerolog.TimeFieldFormat = zerolog.TimeFormatUnixMs
+		writer = consoleWriter(w, noColor)
+	case FormatJSON:
+		zerolog.TimeFieldFormat = time.RFC3339Nano
+		writer = w
+		noColor = true
+	case FormatLogfmt:
+		zerolog.TimeFieldFormat = time.RFC3339Nano
+		writer = &logfmtWriter{w: w}
+		noColor = true
+	default:
+		return fmt.Errorf("invalid log format %q, must be one of: %s", format, strings.Join(Formats(), ", "))
+	}
+	if noColor {
+		color.NoColor = true
+	}
+	log.Logger = zerolog.New(writer).With().Timestamp().Logger()
+	// Host, Pod and HostPod must not be derived from the previous logger later
+	once.Do(func() {})
+	initLoggers()
+	return nil
+}
+
+func consoleWriter(w io.Writer, noColor bool) zerolog.ConsoleWriter {
+	return zerolog.ConsoleWriter{
+		Out:           w,
+		NoColor:       noColor,
+		TimeFormat:    "15:04:05.000",
+		PartsOrder:    []string{"time", "level", "message", FieldSource},
+		FieldsExclude: []string{FieldSource, FieldOperation, FieldPod, FieldPhase, FieldStream},
+		FormatPartValueByName: func(i any, name string) string {
+			if name != FieldSource {
+				return fmt.Sprintf("%s", i)
+			}
+			s := fmt.Sprintf("%s", i)
+			switch s {
+			case "host":
+				return fmt.Sprintf("[%s]", color.CyanString("host"))
+			case "pod":
+				return fmt.Sprintf("[%s]", color.MagentaString("pod"))
+			case "host+pod":
+				return fmt.Sprintf("[%s+%s]", color.CyanString("host"), color.MagentaString("pod"))
+			default:
+				return fmt.Sprintf("[%s]", s)
+			}
+		},
+	}
+}
+
+// logfmtLeadingKeys are written first, in this order. The other fields follow
+// in the order zerolog wrote them.
+var logfmtLeadingKeys = []string{zerolog.TimestampFieldName, zerolog.LevelFieldName, FieldSource, zerolog.MessageFieldName}
+
+// logfmtWriter converts the JSON records written by zerolog to logfmt lines.
+type logfmtWriter struct {
+	w io.Writer
+}
+
+func (l *logfmtWriter) Write(p []byte) (int, error) {
+	line, err := logfmtLine(p)
+	if err != nil {
+		// Keep the record rather than losing it
+		line = p
+	}
+	if _, err := l.w.Write(line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// logfmtLine converts a JSON record to a logfmt line ending with a newline.
+func logfmtLine(record []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(record))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("log record is not a JSON object")
+	}
+	var keys []string
+	values := map[string]string{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		var s string
+		if json.Unmarshal(raw, &s) != nil {
+			s = string(raw)
+		}
+		if _, seen := values[key]; !seen {
+			keys = append(keys, key)
+		}
+		values[key] = s
+	}
+
+	buf := &bytes.Buffer{}
+	write := func(key string) {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		name := key
+		if key == zerolog.MessageFieldName {
+			name = "msg"
+		}
+		buf.WriteString(name)
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(values[key]))
+	}
+	for _, key := range logfmtLeadingKeys {
+		if _, ok := values[key]; ok {
+			write(key)
+		}
+	}
+	for _, key := range keys {
+		if !slices.Contains(logfmtLeadingKeys, key) {
+			write(key)
+		}
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// logfmtValue quotes v if it is empty or contains spaces, quotes, equal signs or control characters.
+func logfmtValue(v string) string {
+	if v == "" || strings.ContainsFunc(v, func(r rune) bool {
+		return r <= ' ' || r == '"' || r == '=' || r == 0x7f
+	}) {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// LineWriter writes every line written to it as a log record, e.g. to emit
+// the output of a command as structured records.
+type LineWriter struct {
+	log *zerolog.Logger
+	mu  sync.Mutex
+	buf []byte
+}
+
+// NewLineWriter returns a LineWriter logging lines at info level to l.
+func NewLineWriter(l zerolog.Logger) *LineWriter {
+	return &LineWriter{log: &l}
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.log.Info().Msg(strings.TrimSuffix(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush logs a trailing line that did not end with a newline.
+func (w *LineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.log.Info().Msg(strings.TrimSuffix(string(w.buf), "\r"))
+		w.buf = nil
+	}
+}
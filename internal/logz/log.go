@@ -8,35 +8,76 @@ import (
 )
 
 var (
-	hostOnce    sync.Once
-	podOnce     sync.Once
-	hostPodOnce sync.Once
+	once sync.Once
 
 	hostLogger    zerolog.Logger
 	podLogger     zerolog.Logger
 	hostPodLogger zerolog.Logger
 )
 
+// initLoggers derives the host, pod and host+pod loggers from the global logger.
+func initLoggers() {
+	hostLogger = log.With().Str("source", "host").Logger()
+	podLogger = log.With().Str("source", "pod").Logger()
+	hostPodLogger = log.With().Str("source", "host+pod").Logger()
+}
+
 // Host returns a reusable zerolog.Logger with source=host field.
 func Host() *zerolog.Logger {
-	hostOnce.Do(func() {
-		hostLogger = log.With().Str("source", "host").Logger()
-	})
+	once.Do(initLoggers)
 	return &hostLogger
 }
 
 // Pod returns a reusable zerolog.Logger with source=pod field.
 func Pod() *zerolog.Logger {
-	podOnce.Do(func() {
-		podLogger = log.With().Str("source", "pod").Logger()
-	})
+	once.Do(initLoggers)
 	return &podLogger
 }
 
 // HostPod returns a reusable zerolog.Logger with source=host+pod field.
 func HostPod() *zerolog.Logger {
-	hostPodOnce.Do(func() {
-		hostPodLogger = log.With().Str("source", "host+pod").Logger()
-	})
+	once.Do(initLoggers)
 	return &hostPodLogger
 }
+
+// Loggers holds host, pod and host+pod loggers that share extra fields,
+// e.g. the kube context a manager operates on.
+type Loggers struct {
+	host    zerolog.Logger
+	pod     zerolog.Logger
+	hostPod zerolog.Logger
+}
+
+// Default returns Loggers backed by Host, Pod and HostPod.
+func Default() *Loggers {
+	return &Loggers{host: *Host(), pod: *Pod(), hostPod: *HostPod()}
+}
+
+// With returns Loggers that add the key=value field to every message.
+func With(key, value string) *Loggers {
+	return Default().With(key, value)
+}
+
+// With returns a copy of l that adds the key=value field to every message.
+func (l *Loggers) With(key, value string) *Loggers {
+	return &Loggers{
+		host:    l.host.With().Str(key, value).Logger(),
+		pod:     l.pod.With().Str(key, value).Logger(),
+		hostPod: l.hostPod.With().Str(key, value).Logger(),
+	}
+}
+
+// Host returns the logger with source=host field.
+func (l *Loggers) Host() *zerolog.Logger {
+	return &l.host
+}
+
+// Pod returns the logger with source=pod field.
+func (l *Loggers) Pod() *zerolog.Logger {
+	return &l.pod
+}
+
+// HostPod returns the logger with source=host+pod field.
+func (l *Loggers) HostPod() *zerolog.Logger {
+	return &l.hostPod
+}
@@ -0,0 +1,13 @@
+package logz_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestLogz(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Logz Suite")
+}
@@ -3,18 +3,24 @@ package internal
 import (
 	"context"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"slices"
 
 	"github.com/Noksa/operator-home/pkg/operatorkclient"
 	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/noksa/helm-in-pod/internal/hipns"
 	"github.com/noksa/helm-in-pod/internal/hippod"
+	"github.com/noksa/helm-in-pod/internal/logz"
 )
 
 var (
 	namespace *hipns.Manager
 	pod       *hippod.Manager
+	// outputAsLogs is set by InitManagers and applies to the managers of every context
+	outputAsLogs bool
 )
 
 // buildConfigOverrides returns clientcmd.ConfigOverrides respecting HELM_KUBECONTEXT.
@@ -27,12 +33,30 @@ func buildConfigOverrides() *clientcmd.ConfigOverrides {
 	return overrides
 }
 
-func InitManagers() error {
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+func loadKubeConfig() clientcmd.ClientConfig {
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
 		clientcmd.NewDefaultClientConfigLoadingRules(),
 		buildConfigOverrides(),
 	)
-	config, err := kubeConfig.ClientConfig()
+}
+
+// CurrentKubeContext returns the kube context the plugin will connect to.
+func CurrentKubeContext() (string, error) {
+	rawConfig, err := loadKubeConfig().RawConfig()
+	if err != nil {
+		return "", err
+	}
+	if ctx := buildConfigOverrides().CurrentContext; ctx != "" {
+		return ctx, nil
+	}
+	return rawConfig.CurrentContext, nil
+}
+
+// InitManagers sets up the Kubernetes client and managers operating in the given plugin namespace.
+// The managers' spans are children of the span in ctx, its cancellation is ignored.
+// With logOutput the command's output is written as log records.
+func InitManagers(ctx context.Context, pluginNamespace string, logOutput bool) error {
+	config, err := loadKubeConfig().ClientConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load kubeconfig: %w", err)
 	}
@@ -40,10 +64,56 @@ func InitManagers() error {
 	operatorkclient.SetDefaultConfig(config)
 
 	hostname, _ := os.Hostname()
-	ctx := context.Background()
+	ctx = context.WithoutCancel(ctx)
 
-	namespace = hipns.NewManager(ctx)
-	pod = hippod.NewManager(ctx, hostname)
+	outputAsLogs = logOutput
+	namespace = hipns.NewManager(ctx, pluginNamespace)
+	pod = hippod.NewManager(ctx, hostname, pluginNamespace, config, podManagerOptions()...)
+	return nil
+}
+
+// KubeContexts returns the names of all contexts in the kubeconfig, sorted.
+func KubeContexts() ([]string, error) {
+	rawConfig, err := loadKubeConfig().RawConfig()
+	if err != nil {
+		return nil, err
+	}
+	contexts := slices.Collect(maps.Keys(rawConfig.Contexts))
+	slices.Sort(contexts)
+	return contexts, nil
+}
+
+// NewContextManagers builds managers that operate on kubeContext instead of the
+// current context. Their logs carry a context field, their spans are children of
+// the span in ctx and the command output goes to stdout and stderr.
+func NewContextManagers(ctx context.Context, kubeContext, pluginNamespace string, stdout, stderr io.Writer) (*hipns.Manager, *hippod.Manager, error) {
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(),
+		&clientcmd.ConfigOverrides{CurrentContext: kubeContext},
+	).ClientConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load kubeconfig for context %q: %w", kubeContext, err)
+	}
+	kclient, err := operatorkclient.NewClientFromConfig(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create client for context %q: %w", kubeContext, err)
+	}
+
+	hostname, _ := os.Hostname()
+	ctx = context.WithoutCancel(ctx)
+	loggers := logz.With("context", kubeContext)
+
+	nsManager := hipns.NewManager(ctx, pluginNamespace, hipns.WithClient(kclient), hipns.WithLoggers(loggers))
+	podManager := hippod.NewManager(ctx, hostname, pluginNamespace, config, append([]hippod.ManagerOption{
+		hippod.WithClient(kclient), hippod.WithLoggers(loggers), hippod.WithOutput(stdout, stderr)}, podManagerOptions()...)...)
+	return nsManager, podManager, nil
+}
+
+// podManagerOptions returns the options shared by the pod managers of all contexts.
+func podManagerOptions() []hippod.ManagerOption {
+	if outputAsLogs {
+		return []hippod.ManagerOption{hippod.WithOutputAsLogs()}
+	}
 	return nil
 }
 
@@ -32,3 +32,32 @@ var _ = Describe("buildConfigOverrides", func() {
 		Expect(buildConfigOverrides().CurrentContext).To(Equal("cluster-b"))
 	})
 })
+
+var _ = Describe("KubeContexts", func() {
+	AfterEach(func() {
+		_ = os.Unsetenv("KUBECONFIG")
+	})
+
+	It("returns all contexts sorted", func() {
+		path := GinkgoT().TempDir() + "/config"
+		Expect(os.WriteFile(path, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: c
+  cluster: {server: https://127.0.0.1}
+users:
+- name: u
+  user: {token: t}
+contexts:
+- name: us-east
+  context: {cluster: c, user: u}
+- name: eu-west
+  context: {cluster: c, user: u}
+current-context: us-east
+`), 0o600)).To(Succeed())
+		_ = os.Setenv("KUBECONFIG", path)
+		contexts, err := KubeContexts()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(contexts).To(Equal([]string{"eu-west", "us-east"}))
+	})
+})